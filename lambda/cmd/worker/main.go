@@ -0,0 +1,184 @@
+// Command worker generates episodes from the Postgres generation queue. It runs
+// the same pipeline as the Lambda, so the whole system can run on a single
+// machine without AWS Lambda.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ShavaizKhan/DailyNewsPodcast/lambda/utils"
+)
+
+const (
+	// defaultConcurrency is how many episodes are generated at once unless
+	// WORKER_CONCURRENCY says otherwise.
+	defaultConcurrency = 2
+
+	// pollInterval is how often an idle worker checks the queue.
+	pollInterval = 5 * time.Second
+
+	// jobTimeout bounds one attempt. Requests held for longer are assumed
+	// abandoned and taken over by another worker.
+	jobTimeout = 30 * time.Minute
+
+	// Failed attempts are retried after a delay that doubles each time, up
+	// to retryMaxDelay.
+	retryBaseDelay = time.Minute
+	retryMaxDelay  = time.Hour
+)
+
+func main() {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		log.Fatal("DATABASE_URL environment variable is not set")
+	}
+
+	concurrency := defaultConcurrency
+	if value := os.Getenv("WORKER_CONCURRENCY"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			log.Fatalf("invalid WORKER_CONCURRENCY %q", value)
+		}
+		concurrency = n
+	}
+
+	// Stop claiming work on SIGINT or SIGTERM, letting running attempts finish.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	catalogue, err := utils.OpenCatalogue(ctx, databaseURL)
+	if err != nil {
+		log.Fatalf("failed to connect to catalogue: %v", err)
+	}
+	defer catalogue.Close()
+
+	storage, err := utils.NewStorage(ctx, os.Getenv("STORAGE_BACKEND"), os.Getenv("S3_BUCKET"), os.Getenv("LOCAL_STORAGE_DIR"))
+	if err != nil {
+		log.Fatalf("failed to configure storage: %v", err)
+	}
+
+	hostname, _ := os.Hostname()
+	w := &worker{
+		name:      fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		catalogue: catalogue,
+		pipeline: &utils.Pipeline{
+			NewsAPIKey: os.Getenv("NEWS_KEY"),
+			GroqToken:  os.Getenv("GROQ_KEY"),
+			Storage:    storage,
+			Catalogue:  catalogue,
+		},
+	}
+
+	log.Printf("worker %s generating up to %d episodes at once", w.name, concurrency)
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Go(func() { w.run(ctx) })
+	}
+	wg.Wait()
+	log.Printf("worker %s stopped", w.name)
+}
+
+// worker takes generation requests from the queue and runs them.
+type worker struct {
+	name      string
+	catalogue *utils.Catalogue
+	pipeline  *utils.Pipeline
+}
+
+// run works through the queue until ctx is done.
+func (w *worker) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		item, err := w.catalogue.ClaimQueued(ctx, w.name, jobTimeout)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("could not claim queued event: %v", err)
+		}
+		if item != nil {
+			w.process(ctx, item)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// process runs one attempt at a request and records the outcome: done, due
+// for another attempt after a backoff, or dead-lettered once it is out of
+// attempts.
+func (w *worker) process(ctx context.Context, item *utils.QueuedEvent) {
+	// Let the attempt finish even if the worker is asked to stop.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jobTimeout)
+	defer cancel()
+
+	// A request out of attempts is dead-lettered straight away, so its job
+	// isn't shown as queued again before it fails.
+	if item.Attempts > item.MaxAttempts {
+		w.deadLetter(ctx, item, errors.New("abandoned by its workers too many times"))
+		return
+	}
+	if item.Abandoned {
+		log.Printf("taking over queued event %s after its worker stopped", item.ID)
+		if item.Event.JobID != "" {
+			if err := w.catalogue.RequeueJob(ctx, item.Event.JobID); err != nil {
+				log.Printf("could not requeue job %s: %v", item.Event.JobID, err)
+			}
+		}
+	}
+
+	final := item.Attempts >= item.MaxAttempts
+	fileName, err := w.pipeline.Run(ctx, item.Event, final)
+	switch {
+	case err == nil:
+		log.Printf("queued event %s generated %s", item.ID, fileName)
+	case errors.Is(err, utils.ErrJobNotQueued):
+		log.Printf("queued event %s: job %s was already started", item.ID, item.Event.JobID)
+	case final:
+		w.deadLetter(ctx, item, err)
+		return
+	default:
+		delay := retryDelay(item.Attempts)
+		log.Printf("queued event %s failed on attempt %d of %d, retrying in %s: %v", item.ID, item.Attempts, item.MaxAttempts, delay, err)
+		if err := w.catalogue.RetryQueued(ctx, item.ID, delay, err.Error()); err != nil {
+			log.Printf("could not retry queued event %s: %v", item.ID, err)
+		}
+		return
+	}
+
+	if err := w.catalogue.CompleteQueued(ctx, item.ID); err != nil {
+		log.Printf("could not complete queued event %s: %v", item.ID, err)
+	}
+}
+
+// deadLetter sets aside a request that won't be tried again, failing its job
+// if the pipeline hasn't already.
+func (w *worker) deadLetter(ctx context.Context, item *utils.QueuedEvent, cause error) {
+	log.Printf("queued event %s failed after %d attempts: %v", item.ID, item.Attempts, cause)
+	if item.Event.JobID != "" {
+		if err := w.catalogue.FailJob(ctx, item.Event.JobID, "your episode could not be generated"); err != nil {
+			log.Printf("could not fail job %s: %v", item.Event.JobID, err)
+		}
+	}
+	if err := w.catalogue.DeadLetterQueued(ctx, item.ID, cause.Error()); err != nil {
+		log.Printf("could not dead-letter queued event %s: %v", item.ID, err)
+	}
+}
+
+// retryDelay is how long to wait before the attempt after the given one.
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempt && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, retryMaxDelay)
+}
@@ -2,113 +2,65 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
-	"time"
 
 	"github.com/ShavaizKhan/DailyNewsPodcast/lambda/utils"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
-type Event struct{}
-
 type Response struct {
 	StatusCode int    `json:"statusCode"`
 	Body       string `json:"body"`
 }
 
-func HandleRequest(ctx context.Context, event Event) (Response, error) {
-	newsAPIKey := os.Getenv("NEWS_KEY")
-	groqToken := os.Getenv("GROQ_KEY")
-	s3Bucket := os.Getenv("S3_BUCKET")
-
-	// Get news articles
-	articles, err := utils.FetchNews(newsAPIKey)
+func HandleRequest(ctx context.Context, event utils.Event) (Response, error) {
+	storage, err := utils.NewStorage(ctx, os.Getenv("STORAGE_BACKEND"), os.Getenv("S3_BUCKET"), os.Getenv("LOCAL_STORAGE_DIR"))
 	if err != nil {
 		return Response{
 			StatusCode: 500,
-			Body:       fmt.Sprintf("Error fetching news: %v", err),
+			Body:       fmt.Sprintf("Error configuring storage: %v", err),
 		}, err
 	}
 
-	// Generate podcast script
-	var podcastScript string
-	for i, article := range articles {
-		dialogue, err := utils.GenerateDialogue(article, groqToken)
+	pipeline := &utils.Pipeline{
+		NewsAPIKey: os.Getenv("NEWS_KEY"),
+		GroqToken:  os.Getenv("GROQ_KEY"),
+		Storage:    storage,
+	}
+
+	// Record the episode in the catalogue, if one is configured
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		catalogue, err := utils.OpenCatalogue(ctx, databaseURL)
 		if err != nil {
 			return Response{
 				StatusCode: 500,
-				Body:       fmt.Sprintf("Error generating dialogue: %v", err),
+				Body:       fmt.Sprintf("Error connecting to catalogue: %v", err),
 			}, err
 		}
-
-		if i == 0 {
-			podcastScript += fmt.Sprintf("\nWelcome back to your daily news update!\n%s", dialogue)
-		} else if i < len(articles)-1 {
-			podcastScript += fmt.Sprintf("\nMoving on to our next discussion.\n%s", dialogue)
-		} else {
-			podcastScript += fmt.Sprintf("\nNow to our final story.\n%s", dialogue)
-		}
+		defer catalogue.Close()
+		pipeline.Catalogue = catalogue
 	}
-	podcastScript += "\n\nThank you for tuning in! We'll be back with more news coverage for you tomorrow!"
 
-	// Create temporary file for audio
-	fileName := fmt.Sprintf("general_podcast_%s.mp3", time.Now().Format("2006-01-02"))
-	tmpFile := "/tmp/" + fileName
-
-	// Generate audio
-	err = utils.SynthesizePodcast(podcastScript, tmpFile)
-	if err != nil {
-		return Response{
-			StatusCode: 500,
-			Body:       fmt.Sprintf("Error synthesizing podcast: %v", err),
-		}, err
+	// Lambda's own retries of an invocation find the job already started
+	fileName, err := pipeline.Run(ctx, event, true)
+	if errors.Is(err, utils.ErrJobNotQueued) {
+		return Response{StatusCode: 200, Body: fmt.Sprintf("Job %s was already started", event.JobID)}, nil
 	}
-
-	// Upload to S3
-	err = uploadToS3(tmpFile, s3Bucket, fileName)
 	if err != nil {
 		return Response{
 			StatusCode: 500,
-			Body:       fmt.Sprintf("Error uploading to S3: %v", err),
+			Body:       fmt.Sprintf("Error generating podcast: %v", err),
 		}, err
 	}
 
-	// Clean up temp file
-	os.Remove(tmpFile)
-
 	return Response{
 		StatusCode: 200,
 		Body:       fmt.Sprintf("Podcast generated successfully: %s", fileName),
 	}, nil
 }
 
-func uploadToS3(filePath, bucket, key string) error {
-	ctx := context.Background()
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		return err
-	}
-
-	client := s3.NewFromConfig(cfg)
-
-	file, err := os.Open(filePath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	_, err = client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket: &bucket,
-		Key:    &key,
-		Body:   file,
-	})
-
-	return err
-}
-
 func main() {
 	lambda.Start(HandleRequest)
 }
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+)
+
+// DefaultStories is how many stories an episode covers unless the event asks
+// for a different number.
+const DefaultStories = 10
+
+// TopicWeight is a topic and how much it matters relative to the others.
+type TopicWeight struct {
+	Topic  string `json:"topic"`
+	Weight int    `json:"weight"`
+}
+
+// CountryWeight is a country and how much it matters relative to the others.
+type CountryWeight struct {
+	Country string `json:"country"`
+	Weight  int    `json:"weight"`
+}
+
+// StoryQuota is how many stories to fetch for a country and topic.
+type StoryQuota struct {
+	Country string
+	Topic   string
+	Count   int
+}
+
+// Allocate splits total between weights in proportion to them using the
+// largest remainder method, so the counts always add up to total. Ties in
+// the remainder go to the earlier weight.
+func Allocate(weights []int, total int) []int {
+	counts := make([]int, len(weights))
+	sum := 0
+	for _, weight := range weights {
+		sum += max(weight, 0)
+	}
+	if sum == 0 || total <= 0 {
+		return counts
+	}
+
+	remainders := make([]int, len(weights))
+	allocated := 0
+	for i, weight := range weights {
+		share := max(weight, 0) * total
+		counts[i] = share / sum
+		remainders[i] = share % sum
+		allocated += counts[i]
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	for _, i := range order[:total-allocated] {
+		counts[i]++
+	}
+	return counts
+}
+
+// PlanStories divides an episode's stories between topics by weight, then
+// divides each topic's stories between countries by weight. Pairs that get
+// no stories are left out.
+func PlanStories(topics []TopicWeight, countries []CountryWeight, total int) []StoryQuota {
+	topicWeights := make([]int, len(topics))
+	for i, topic := range topics {
+		topicWeights[i] = topic.Weight
+	}
+	countryWeights := make([]int, len(countries))
+	for i, country := range countries {
+		countryWeights[i] = country.Weight
+	}
+
+	var quotas []StoryQuota
+	for i, topicCount := range Allocate(topicWeights, total) {
+		for j, count := range Allocate(countryWeights, topicCount) {
+			if count > 0 {
+				quotas = append(quotas, StoryQuota{Country: countries[j].Country, Topic: topics[i].Topic, Count: count})
+			}
+		}
+	}
+	return quotas
+}
+
+// PreferenceKey joins preference names into the key an episode is catalogued
+// under, for example "business+technology".
+func PreferenceKey(names []string) string {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, "+")
+}
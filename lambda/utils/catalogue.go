@@ -0,0 +1,267 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// Episode statuses shared with the backend's episodes table.
+const (
+	EpisodeStatusGenerating = "generating"
+	EpisodeStatusPublished  = "published"
+	EpisodeStatusFailed     = "failed"
+)
+
+// Episode job statuses shared with the backend's episode_jobs table.
+const (
+	JobStatusQueued       = "queued"
+	JobStatusFetching     = "fetching"
+	JobStatusScripting    = "scripting"
+	JobStatusSynthesizing = "synthesizing"
+	JobStatusPublished    = "published"
+	JobStatusFailed       = "failed"
+)
+
+// jobStages is the share of a job's progress each step covers, as the
+// percentage it starts and ends at.
+var jobStages = map[string][2]int{
+	JobStatusFetching:     {0, 10},
+	JobStatusScripting:    {10, 50},
+	JobStatusSynthesizing: {50, 95},
+}
+
+// JobProgress returns how far along a job is, from 0 to 100, when it has
+// finished done of the total pieces of work in the given step.
+func JobProgress(status string, done, total int) int {
+	stage, ok := jobStages[status]
+	if !ok {
+		if status == JobStatusPublished {
+			return 100
+		}
+		return 0
+	}
+	if total <= 0 {
+		return stage[0]
+	}
+	return stage[0] + (stage[1]-stage[0])*min(done, total)/total
+}
+
+// ErrJobNotQueued is returned when claiming a job that has already been
+// started, for example when an invocation is retried.
+var ErrJobNotQueued = errors.New("episode job is not queued")
+
+// Manifest describes how an episode was produced. It is stored as JSON in the
+// episodes table and read back by the backend.
+type Manifest struct {
+	Model         string          `json:"model,omitempty"`
+	Title         string          `json:"title,omitempty"`
+	Description   string          `json:"description,omitempty"`
+	Summary       string          `json:"summary,omitempty"`
+	CoverURL      string          `json:"coverUrl,omitempty"`
+	TranscriptKey string          `json:"transcriptKey,omitempty"`
+	Topics        []TopicWeight   `json:"topics,omitempty"`
+	Countries     []CountryWeight `json:"countries,omitempty"`
+	Filters       *ArticleFilters `json:"filters,omitempty"`
+	Articles      []Article       `json:"articles,omitempty"`
+	Exclusions    []Exclusion     `json:"exclusions,omitempty"`
+	Chapters      []Chapter       `json:"chapters,omitempty"`
+}
+
+// Chapter marks where the discussion of an article starts in the audio.
+type Chapter struct {
+	Title        string `json:"title"`
+	StartSeconds int    `json:"startSeconds"`
+	URL          string `json:"url,omitempty"`
+}
+
+// NewManifest builds the manifest for an episode whose articles start at the
+// given offsets in the audio.
+func NewManifest(date time.Time, articles []Article, offsets []int) Manifest {
+	var coverURL string
+	headlines := make([]string, len(articles))
+	chapters := make([]Chapter, len(articles))
+	for i, article := range articles {
+		if coverURL == "" {
+			coverURL = article.ImageURL
+		}
+		headlines[i] = article.Title
+		chapters[i] = Chapter{
+			Title:        article.Title,
+			StartSeconds: offsets[i],
+			URL:          article.URL,
+		}
+	}
+
+	return Manifest{
+		Model:       DialogueModel,
+		Title:       fmt.Sprintf("Daily News Podcast - %s", date.Format("January 2, 2006")),
+		Description: "In this episode: " + strings.Join(headlines, "; "),
+		CoverURL:    coverURL,
+		Articles:    articles,
+		Chapters:    chapters,
+	}
+}
+
+// Catalogue records published episodes in the backend's Postgres database.
+type Catalogue struct {
+	db *sql.DB
+}
+
+// OpenCatalogue connects to the episode catalogue.
+func OpenCatalogue(ctx context.Context, connStr string) (*Catalogue, error) {
+	db, err := sql.Open("pgx", connStr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open database connection: %w", err)
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not ping database: %w", err)
+	}
+
+	return &Catalogue{db: db}, nil
+}
+
+// Close closes the database connection pool.
+func (c *Catalogue) Close() {
+	c.db.Close()
+}
+
+// StartEpisode marks the episode for a date, country and topic as generating
+// and returns its ID. Re-running a day replaces the previous attempt.
+func (c *Catalogue) StartEpisode(ctx context.Context, date time.Time, country, topic, storageKey string) (string, error) {
+	var id string
+	query := `
+		INSERT INTO episodes (episode_date, country, topic, storage_key, status)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (episode_date, country, topic) WHERE user_id IS NULL DO UPDATE
+		SET storage_key = EXCLUDED.storage_key,
+			status = EXCLUDED.status,
+			updated_at = now()
+		RETURNING id`
+
+	err := c.db.QueryRowContext(ctx, query, date, country, topic, storageKey, EpisodeStatusGenerating).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("failed to start episode: %w", err)
+	}
+	return id, nil
+}
+
+// StartPersonalEpisode records a new generating episode owned by a user and
+// returns its ID.
+func (c *Catalogue) StartPersonalEpisode(ctx context.Context, userID string, date time.Time, country, topic, storageKey string) (string, error) {
+	var id string
+	query := `
+		INSERT INTO episodes (user_id, episode_date, country, topic, storage_key, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+
+	err := c.db.QueryRowContext(ctx, query, userID, date, country, topic, storageKey, EpisodeStatusGenerating).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("failed to start episode: %w", err)
+	}
+	return id, nil
+}
+
+// PublishEpisode marks an episode as published once its audio is uploaded.
+func (c *Catalogue) PublishEpisode(ctx context.Context, id string, durationSeconds int, manifest Manifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	query := `
+		UPDATE episodes
+		SET status = $1, duration_seconds = $2, manifest = $3, updated_at = now()
+		WHERE id = $4`
+
+	if _, err := c.db.ExecContext(ctx, query, EpisodeStatusPublished, durationSeconds, data, id); err != nil {
+		return fmt.Errorf("failed to publish episode: %w", err)
+	}
+	return nil
+}
+
+// FailEpisode marks an episode as failed.
+func (c *Catalogue) FailEpisode(ctx context.Context, id string) error {
+	query := `UPDATE episodes SET status = $1, updated_at = now() WHERE id = $2`
+
+	if _, err := c.db.ExecContext(ctx, query, EpisodeStatusFailed, id); err != nil {
+		return fmt.Errorf("failed to mark episode as failed: %w", err)
+	}
+	return nil
+}
+
+// ClaimJob moves a queued job to fetching and returns its user and request.
+// It returns ErrJobNotQueued if the job was already claimed.
+func (c *Catalogue) ClaimJob(ctx context.Context, id string) (userID string, request []byte, err error) {
+	query := `
+		UPDATE episode_jobs
+		SET status = $1, progress = $2, updated_at = now()
+		WHERE id = $3 AND status = $4
+		RETURNING user_id, request`
+
+	err = c.db.QueryRowContext(ctx, query, JobStatusFetching, JobProgress(JobStatusFetching, 0, 1), id, JobStatusQueued).Scan(&userID, &request)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil, ErrJobNotQueued
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to claim episode job: %w", err)
+	}
+	return userID, request, nil
+}
+
+// SetJobStatus records the step a job has reached and how far along it is,
+// from 0 to 100.
+func (c *Catalogue) SetJobStatus(ctx context.Context, id, status string, progress int) error {
+	query := `UPDATE episode_jobs SET status = $1, progress = $2, updated_at = now() WHERE id = $3`
+
+	if _, err := c.db.ExecContext(ctx, query, status, progress, id); err != nil {
+		return fmt.Errorf("failed to update episode job: %w", err)
+	}
+	return nil
+}
+
+// CompleteJob marks a job as published with its episode.
+func (c *Catalogue) CompleteJob(ctx context.Context, id, episodeID string) error {
+	query := `UPDATE episode_jobs SET status = $1, episode_id = $2, progress = 100, updated_at = now() WHERE id = $3`
+
+	if _, err := c.db.ExecContext(ctx, query, JobStatusPublished, episodeID, id); err != nil {
+		return fmt.Errorf("failed to complete episode job: %w", err)
+	}
+	return nil
+}
+
+// FailJob marks a job as failed with a message for the user, unless it has
+// already finished.
+func (c *Catalogue) FailJob(ctx context.Context, id, message string) error {
+	query := `
+		UPDATE episode_jobs
+		SET status = $1, error = $2, updated_at = now()
+		WHERE id = $3 AND status NOT IN ($4, $1)`
+
+	if _, err := c.db.ExecContext(ctx, query, JobStatusFailed, message, id, JobStatusPublished); err != nil {
+		return fmt.Errorf("failed to mark episode job as failed: %w", err)
+	}
+	return nil
+}
+
+// RequeueJob returns an unfinished job to the queue so another attempt can
+// claim it.
+func (c *Catalogue) RequeueJob(ctx context.Context, id string) error {
+	query := `
+		UPDATE episode_jobs
+		SET status = $1, progress = 0, error = NULL, updated_at = now()
+		WHERE id = $2 AND status NOT IN ($3, $4)`
+
+	if _, err := c.db.ExecContext(ctx, query, JobStatusQueued, id, JobStatusPublished, JobStatusFailed); err != nil {
+		return fmt.Errorf("failed to requeue episode job: %w", err)
+	}
+	return nil
+}
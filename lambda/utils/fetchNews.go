@@ -4,21 +4,77 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 )
 
+// newsAPICategories are the topics NewsAPI can filter top headlines by. Other
+// topics are searched for as keywords.
+var newsAPICategories = map[string]bool{
+	"business":      true,
+	"entertainment": true,
+	"general":       true,
+	"health":        true,
+	"science":       true,
+	"sports":        true,
+	"technology":    true,
+}
+
+// maxPageSize is the most headlines NewsAPI returns in one request.
+const maxPageSize = 100
+
+// newsAPICountries maps country codes used by the app to NewsAPI's where
+// they differ.
+var newsAPICountries = map[string]string{
+	"uk": "gb",
+}
+
 type NewsAPIResponse struct {
 	Articles []struct {
+		Source struct {
+			Name string `json:"name"`
+		} `json:"source"`
 		Title       string `json:"title"`
 		Description string `json:"description"`
 		Content     string `json:"content"`
 		URL         string `json:"url"`
+		URLToImage  string `json:"urlToImage"`
 	} `json:"articles"`
 }
 
-func FetchNews(apiKey string) ([]string, error) {
-	url := fmt.Sprintf("https://newsapi.org/v2/top-headlines?country=us&pageSize=10&apiKey=%s", apiKey)
+// Article is a news headline returned by NewsAPI.
+type Article struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Source      string `json:"source,omitempty"`
+	ImageURL    string `json:"imageUrl,omitempty"`
+	Country     string `json:"country,omitempty"`
+	Topic       string `json:"topic,omitempty"`
+}
+
+// String formats the article the way it is passed to the dialogue model.
+func (a Article) String() string {
+	return fmt.Sprintf("%s - %s", a.Title, a.Description)
+}
+
+// FetchNews returns up to count top headlines for a country and topic.
+func FetchNews(apiKey, country, topic string, count int) ([]Article, error) {
+	params := url.Values{}
+	params.Set("apiKey", apiKey)
+	params.Set("pageSize", strconv.Itoa(count))
+	if code, ok := newsAPICountries[country]; ok {
+		params.Set("country", code)
+	} else {
+		params.Set("country", country)
+	}
+	if newsAPICategories[topic] {
+		params.Set("category", topic)
+	} else {
+		params.Set("q", topic)
+	}
 
-	resp, err := http.Get(url)
+	resp, err := http.Get("https://newsapi.org/v2/top-headlines?" + params.Encode())
 	if err != nil {
 		return nil, err
 	}
@@ -29,9 +85,59 @@ func FetchNews(apiKey string) ([]string, error) {
 		return nil, err
 	}
 
-	var articles []string
+	var articles []Article
 	for _, a := range data.Articles {
-		articles = append(articles, fmt.Sprintf("%s - %s", a.Title, a.Description))
+		articles = append(articles, Article{
+			Title:       a.Title,
+			Description: a.Description,
+			URL:         a.URL,
+			Source:      a.Source.Name,
+			ImageURL:    a.URLToImage,
+			Country:     country,
+			Topic:       topic,
+		})
 	}
 	return articles, nil
 }
+
+// FetchStories fetches the headlines for each quota in turn, applying the
+// filters to pick which to use. A story that matches more than one quota is
+// only used once, so a quota can come up short when headlines overlap or the
+// filters are strict. Articles the filters kept out are returned alongside.
+func FetchStories(apiKey string, quotas []StoryQuota, filters ArticleFilters) ([]Article, []Exclusion, error) {
+	// Ask for extra headlines to choose from, more so when filters drop some
+	extra := 5
+	if !filters.Empty() {
+		extra = 20
+	}
+
+	seen := make(map[string]bool)
+	var articles []Article
+	var exclusions []Exclusion
+	for _, quota := range quotas {
+		candidates, err := FetchNews(apiKey, quota.Country, quota.Topic, min(quota.Count+extra, maxPageSize))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch %s news for %s: %w", quota.Topic, quota.Country, err)
+		}
+		filters.Rank(candidates)
+
+		taken := 0
+		for _, article := range candidates {
+			if taken == quota.Count {
+				break
+			}
+			if article.URL != "" && seen[article.URL] {
+				continue
+			}
+			seen[article.URL] = true
+
+			if exclusion := filters.Check(article); exclusion != nil {
+				exclusions = append(exclusions, *exclusion)
+				continue
+			}
+			articles = append(articles, article)
+			taken++
+		}
+	}
+	return articles, exclusions, nil
+}
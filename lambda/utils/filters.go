@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Kinds of article filter, matching the backend's user_filters table.
+const (
+	FilterIncludeKeyword = "include_keyword"
+	FilterExcludeKeyword = "exclude_keyword"
+	FilterBlockedSource  = "blocked_source"
+)
+
+// ArticleFilters are a listener's rules for which articles go into their
+// episodes. Keywords are lower-case and sources are bare domains. Demoted
+// sources are the ones the listener gave a thumbs-down to; their articles
+// are only used when nothing else is left.
+type ArticleFilters struct {
+	IncludeKeywords  []string `json:"includeKeywords,omitempty"`
+	ExcludeKeywords  []string `json:"excludeKeywords,omitempty"`
+	BlockedSources   []string `json:"blockedSources,omitempty"`
+	PreferredSources []string `json:"preferredSources,omitempty"`
+	DemotedSources   []string `json:"demotedSources,omitempty"`
+}
+
+// Exclusion records an article a filter kept out of an episode.
+type Exclusion struct {
+	Title  string `json:"title"`
+	URL    string `json:"url,omitempty"`
+	Filter string `json:"filter"`
+	Value  string `json:"value,omitempty"`
+}
+
+// Empty reports whether the filters let every article through in fetch order.
+func (f ArticleFilters) Empty() bool {
+	return len(f.IncludeKeywords) == 0 && len(f.ExcludeKeywords) == 0 &&
+		len(f.BlockedSources) == 0 && len(f.PreferredSources) == 0 && len(f.DemotedSources) == 0
+}
+
+// Check returns nil if the article may be used, or the exclusion that
+// keeps it out.
+func (f ArticleFilters) Check(article Article) *Exclusion {
+	exclude := func(filter, value string) *Exclusion {
+		return &Exclusion{Title: article.Title, URL: article.URL, Filter: filter, Value: value}
+	}
+
+	if source := matchSource(article, f.BlockedSources); source != "" {
+		return exclude(FilterBlockedSource, source)
+	}
+
+	text := strings.ToLower(article.Title + " " + article.Description)
+	for _, keyword := range f.ExcludeKeywords {
+		if strings.Contains(text, keyword) {
+			return exclude(FilterExcludeKeyword, keyword)
+		}
+	}
+	if len(f.IncludeKeywords) > 0 && !slices.ContainsFunc(f.IncludeKeywords, func(keyword string) bool {
+		return strings.Contains(text, keyword)
+	}) {
+		return exclude(FilterIncludeKeyword, "")
+	}
+	return nil
+}
+
+// Rank moves articles from preferred sources to the front and articles from
+// demoted sources to the back, keeping the order within each group.
+func (f ArticleFilters) Rank(articles []Article) {
+	if len(f.PreferredSources) == 0 && len(f.DemotedSources) == 0 {
+		return
+	}
+	rank := func(article Article) int {
+		switch {
+		case matchSource(article, f.PreferredSources) != "":
+			return 0
+		case matchSource(article, f.DemotedSources) != "":
+			return 2
+		}
+		return 1
+	}
+	slices.SortStableFunc(articles, func(a, b Article) int {
+		return rank(a) - rank(b)
+	})
+}
+
+// matchSource returns the domain from sources that the article was
+// published on, including its subdomains, or "" if there is none.
+func matchSource(article Article, sources []string) string {
+	u, err := url.Parse(article.URL)
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	for _, source := range sources {
+		if host == source || strings.HasSuffix(host, "."+source) {
+			return source
+		}
+	}
+	return ""
+}
@@ -2,10 +2,15 @@ package utils
 
 import (
 	"fmt"
+	"strings"
+
 	// Simple HTTP client to call API
 	"github.com/go-resty/resty/v2"
 )
 
+// DialogueModel is the Groq model used to write the podcast script.
+const DialogueModel = "llama-3.1-8b-instant"
+
 type ChatMessage struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
@@ -30,20 +35,38 @@ type ChatResponse struct {
 	Choices []ChatChoice `json:"choices"`
 }
 
-func GenerateDialogue(article, groqToken string) (string, error) {
+// GenerateDialogue writes the hosts' conversation about an article in the
+// given language.
+func GenerateDialogue(article string, language Language, groqToken string) (string, error) {
 	prompt := fmt.Sprintf("Turn this article into a short podcast-style conversation between two hosts, Alice and Bob without any intro and outro. Keep it engaging but concise, and sounding natural. Keep it within 1000 characters and make a new line for each speaker with the prefix 'Bob:' or 'Alice:'. Ensure there's a newline between each speaker :\n\n%s", article)
+	if language.Name != "English" {
+		prompt = fmt.Sprintf("Write the conversation in %s, keeping the 'Bob:' and 'Alice:' prefixes. %s", language.Name, prompt)
+	}
+
+	return complete(prompt, groqToken, 400)
+}
+
+// GenerateSummary writes a short listener-facing summary, in the given
+// language, of an episode covering the given headlines.
+func GenerateSummary(headlines []string, language Language, groqToken string) (string, error) {
+	prompt := fmt.Sprintf("Write a two to three sentence summary in %s of a news podcast episode that covers the following stories. Reply with the summary only:\n\n%s", language.Name, strings.Join(headlines, "\n"))
+
+	return complete(prompt, groqToken, 200)
+}
 
+// complete sends a single-message chat completion request to Groq.
+func complete(prompt, groqToken string, maxTokens int) (string, error) {
 	client := resty.New()
 
 	request := ChatRequest{
-		Model: "llama-3.1-8b-instant",
+		Model: DialogueModel,
 		Messages: []ChatMessage{
 			{
 				Role:    "user",
 				Content: prompt,
 			},
 		},
-		MaxTokens:   400,
+		MaxTokens:   maxTokens,
 		Temperature: 0.7,
 	}
 
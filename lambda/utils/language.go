@@ -0,0 +1,82 @@
+package utils
+
+import "github.com/aws/aws-sdk-go-v2/service/polly/types"
+
+// DefaultLanguage is used for events that don't name a language.
+const DefaultLanguage = "en"
+
+// Language holds what the pipeline needs to produce an episode in one of the
+// languages listed in the backend's supported options.
+type Language struct {
+	// Name is the language's English name, used in prompts.
+	Name string
+	// Voices of the two hosts, Alice and Bob.
+	AliceVoice types.VoiceId
+	BobVoice   types.VoiceId
+	// Lines the host reads between stories.
+	Welcome string
+	Next    string
+	Final   string
+	Outro   string
+}
+
+// Languages are the languages episodes can be produced in, by code.
+var Languages = map[string]Language{
+	"en": {
+		Name:       "English",
+		AliceVoice: types.VoiceIdDanielle,
+		BobVoice:   types.VoiceIdStephen,
+		Welcome:    "Welcome back to your daily news update!",
+		Next:       "Moving on to our next discussion.",
+		Final:      "Now to our final story.",
+		Outro:      "Thank you for tuning in! We'll be back with more news coverage for you tomorrow!",
+	},
+	"fr": {
+		Name:       "French",
+		AliceVoice: types.VoiceIdLea,
+		BobVoice:   types.VoiceIdRemi,
+		Welcome:    "Bienvenue dans votre point quotidien sur l'actualité !",
+		Next:       "Passons à notre prochain sujet.",
+		Final:      "Et maintenant, notre dernière histoire.",
+		Outro:      "Merci de nous avoir écoutés ! Nous serons de retour demain avec plus d'actualités !",
+	},
+	"de": {
+		Name:       "German",
+		AliceVoice: types.VoiceIdVicki,
+		BobVoice:   types.VoiceIdDaniel,
+		Welcome:    "Willkommen zurück zu Ihrem täglichen Nachrichtenüberblick!",
+		Next:       "Kommen wir zu unserem nächsten Thema.",
+		Final:      "Und nun zu unserer letzten Geschichte.",
+		Outro:      "Danke fürs Zuhören! Morgen sind wir mit weiteren Nachrichten für Sie zurück!",
+	},
+	"es": {
+		Name:       "Spanish",
+		AliceVoice: types.VoiceIdLucia,
+		BobVoice:   types.VoiceIdSergio,
+		Welcome:    "¡Bienvenidos de nuevo a su resumen diario de noticias!",
+		Next:       "Pasamos a nuestro siguiente tema.",
+		Final:      "Y ahora, nuestra última historia.",
+		Outro:      "¡Gracias por escucharnos! ¡Volveremos mañana con más noticias!",
+	},
+}
+
+// LookupLanguage returns the language for a code, falling back to English
+// for codes the pipeline doesn't know.
+func LookupLanguage(code string) Language {
+	if language, ok := Languages[code]; ok {
+		return language
+	}
+	return Languages[DefaultLanguage]
+}
+
+// Transition returns the line read before the i-th of n stories.
+func (l Language) Transition(i, n int) string {
+	switch {
+	case i == 0:
+		return l.Welcome
+	case i < n-1:
+		return l.Next
+	default:
+		return l.Final
+	}
+}
@@ -0,0 +1,341 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Event selects what an episode covers. Stories are divided between the
+// topics, and then the countries, in proportion to their weights, and picked
+// from the headlines the filters allow. An empty event produces the shared
+// general episode for the US. Events with a JobID produce a personal episode
+// from the request stored with that job. Date, as YYYY-MM-DD, is the date the
+// episode is for, today by default.
+type Event struct {
+	JobID     string          `json:"jobId,omitempty"`
+	Topics    []TopicWeight   `json:"topics,omitempty"`
+	Countries []CountryWeight `json:"countries,omitempty"`
+	Language  string          `json:"language,omitempty"`
+	Stories   int             `json:"stories,omitempty"`
+	Filters   ArticleFilters  `json:"filters,omitempty"`
+	Date      string          `json:"date,omitempty"`
+}
+
+// withDefaults fills in the shared episode's preferences for anything the
+// event leaves out.
+func (e Event) withDefaults() Event {
+	if len(e.Topics) == 0 {
+		e.Topics = []TopicWeight{{Topic: "general", Weight: 1}}
+	}
+	if len(e.Countries) == 0 {
+		e.Countries = []CountryWeight{{Country: "us", Weight: 1}}
+	}
+	if e.Language == "" {
+		e.Language = DefaultLanguage
+	}
+	if e.Stories <= 0 {
+		e.Stories = DefaultStories
+	}
+	return e
+}
+
+// catalogueKeys returns the country and topic the episode is catalogued under.
+func (e Event) catalogueKeys() (country, topic string) {
+	countries := make([]string, len(e.Countries))
+	for i, c := range e.Countries {
+		countries[i] = c.Country
+	}
+	topics := make([]string, len(e.Topics))
+	for i, t := range e.Topics {
+		topics[i] = t.Topic
+	}
+	return PreferenceKey(countries), PreferenceKey(topics)
+}
+
+// Pipeline generates episodes: it fetches the news, writes and synthesizes the
+// discussion, stores the audio and records the episode in the catalogue. The
+// Lambda and the worker run the same pipeline.
+type Pipeline struct {
+	NewsAPIKey string
+	GroqToken  string
+	Storage    Storage
+	// Catalogue is optional for shared episodes, which are then only
+	// stored, and required for jobs.
+	Catalogue *Catalogue
+}
+
+// Run generates the episode an event describes and returns the key its audio
+// is stored under. If a job fails and final is false, the job is returned to
+// the queue for another attempt instead of being marked as failed.
+// ErrJobNotQueued is returned for jobs that have already been started.
+func (p *Pipeline) Run(ctx context.Context, event Event, final bool) (string, error) {
+	catalogue := p.Catalogue
+	if event.JobID != "" && catalogue == nil {
+		return "", fmt.Errorf("DATABASE_URL is required to run episode jobs")
+	}
+	if catalogue == nil {
+		log.Println("DATABASE_URL is not set, episode will not be catalogued")
+	}
+
+	// endJob fails the job, or returns it to the queue to be tried again
+	endJob := func(message string) {
+		if event.JobID == "" {
+			return
+		}
+		var err error
+		if final {
+			err = catalogue.FailJob(ctx, event.JobID, message)
+		} else {
+			err = catalogue.RequeueJob(ctx, event.JobID)
+		}
+		if err != nil {
+			log.Printf("could not update job %s: %v", event.JobID, err)
+		}
+	}
+
+	// Personal episodes are generated from the request stored with their job
+	var userID string
+	if event.JobID != "" {
+		var request []byte
+		var err error
+		userID, request, err = catalogue.ClaimJob(ctx, event.JobID)
+		if err != nil {
+			return "", err
+		}
+		if err := json.Unmarshal(request, &event); err != nil {
+			endJob("the episode request could not be read")
+			return "", fmt.Errorf("failed to read job request: %w", err)
+		}
+	}
+
+	event = event.withDefaults()
+	language := LookupLanguage(event.Language)
+	country, topic := event.catalogueKeys()
+
+	// Personal episodes are dated in the user's timezone
+	date := time.Now()
+	if event.Date != "" {
+		var err error
+		if date, err = time.Parse(time.DateOnly, event.Date); err != nil {
+			endJob("the episode request could not be read")
+			return "", fmt.Errorf("invalid episode date %q: %w", event.Date, err)
+		}
+	}
+	fileName := fmt.Sprintf("%s_podcast_%s.mp3", strings.ReplaceAll(topic, "+", "-"), date.Format("2006-01-02"))
+	if country != "us" {
+		fileName = strings.ReplaceAll(country, "+", "-") + "_" + fileName
+	}
+	if event.JobID != "" {
+		fileName = fmt.Sprintf("users/%s/%s.mp3", userID, event.JobID)
+	}
+
+	var episodeID string
+	if catalogue != nil {
+		var err error
+		if event.JobID != "" {
+			episodeID, err = catalogue.StartPersonalEpisode(ctx, userID, date, country, topic, fileName)
+		} else {
+			episodeID, err = catalogue.StartEpisode(ctx, date, country, topic, fileName)
+		}
+		if err != nil {
+			endJob("the episode could not be recorded")
+			return "", err
+		}
+	}
+
+	// fail marks the episode as failed and ends the job before returning the
+	// error
+	fail := func(message string, err error) (string, error) {
+		if catalogue != nil {
+			if failErr := catalogue.FailEpisode(ctx, episodeID); failErr != nil {
+				log.Printf("could not mark episode %s as failed: %v", episodeID, failErr)
+			}
+		}
+		endJob(message)
+		return "", fmt.Errorf("%s: %w", message, err)
+	}
+
+	// progress records the step a job has reached and how much of the step's
+	// work is done
+	progress := func(status string, done, total int) {
+		if event.JobID == "" {
+			return
+		}
+		if err := catalogue.SetJobStatus(ctx, event.JobID, status, JobProgress(status, done, total)); err != nil {
+			log.Printf("could not update job %s: %v", event.JobID, err)
+		}
+	}
+
+	// Get news articles, shared out between topics and countries by weight
+	quotas := PlanStories(event.Topics, event.Countries, event.Stories)
+	articles, exclusions, err := FetchStories(p.NewsAPIKey, quotas, event.Filters)
+	if err != nil {
+		return fail("the news could not be fetched", err)
+	}
+
+	// Generate podcast script, one segment per article. Stories that were
+	// already discussed in another episode reuse that segment.
+	hashes := make([]string, len(articles))
+	cached := make([]*Segment, len(articles))
+	dialogues := make([]string, len(articles))
+	for i, article := range articles {
+		progress(JobStatusScripting, i, len(articles))
+		hashes[i] = ArticleHash(article)
+		if catalogue != nil {
+			segment, err := catalogue.GetSegment(ctx, hashes[i], event.Language)
+			if err != nil {
+				log.Printf("could not look up segment for %s: %v", article.URL, err)
+			}
+			if segment != nil {
+				cached[i] = segment
+				dialogues[i] = segment.Script
+				continue
+			}
+		}
+
+		dialogue, err := GenerateDialogue(article.String(), language, p.GroqToken)
+		if err != nil {
+			return fail("the discussion could not be written", err)
+		}
+		dialogues[i] = dialogue
+	}
+
+	// Create temporary file for audio
+	audio, err := os.CreateTemp("", "podcast-*.mp3")
+	if err != nil {
+		return fail("the podcast audio could not be created", err)
+	}
+	defer os.Remove(audio.Name())
+
+	// Generate audio: each story is introduced by the host, followed by its
+	// segment, which is synthesized once and then reused
+	synthesizer, err := NewSynthesizer(ctx, language)
+	if err != nil {
+		audio.Close()
+		return fail("the podcast could not be synthesized", err)
+	}
+
+	var written int64
+	offsets := make([]int, len(articles))
+	transcript := make([]string, 0, len(articles)+1)
+	for i := range articles {
+		progress(JobStatusSynthesizing, i, len(articles))
+		offsets[i] = AudioSeconds(written)
+
+		transition := language.Transition(i, len(articles))
+		n, err := synthesizer.Synthesize(ctx, transition, audio)
+		written += n
+		if err != nil {
+			audio.Close()
+			return fail("the podcast could not be synthesized", err)
+		}
+		transcript = append(transcript, "\n"+transition+"\n"+dialogues[i])
+
+		n, err = p.writeSegment(ctx, synthesizer, hashes[i], event.Language, dialogues[i], cached[i], audio)
+		written += n
+		if err != nil {
+			audio.Close()
+			return fail("the podcast could not be synthesized", err)
+		}
+	}
+	if len(articles) > 0 {
+		n, err := synthesizer.Synthesize(ctx, language.Outro, audio)
+		written += n
+		if err != nil {
+			audio.Close()
+			return fail("the podcast could not be synthesized", err)
+		}
+		transcript = append(transcript, "\n"+language.Outro)
+	}
+
+	duration := AudioSeconds(written)
+
+	// Store the audio
+	if _, err := audio.Seek(0, io.SeekStart); err != nil {
+		audio.Close()
+		return fail("the podcast audio could not be read", err)
+	}
+	err = p.Storage.Put(ctx, fileName, audio)
+	audio.Close()
+	if err != nil {
+		return fail("the podcast could not be stored", err)
+	}
+
+	// Store the transcript next to the audio
+	transcriptKey := strings.TrimSuffix(fileName, ".mp3") + ".txt"
+	if err := p.Storage.Put(ctx, transcriptKey, strings.NewReader(strings.Join(transcript, "\n"))); err != nil {
+		return fail("the transcript could not be stored", err)
+	}
+
+	// Publish the episode in the catalogue
+	if catalogue != nil {
+		manifest := NewManifest(date, articles, offsets)
+		manifest.TranscriptKey = transcriptKey
+		manifest.Topics = event.Topics
+		manifest.Countries = event.Countries
+		if !event.Filters.Empty() {
+			manifest.Filters = &event.Filters
+			manifest.Exclusions = exclusions
+		}
+
+		headlines := make([]string, len(articles))
+		for i, article := range articles {
+			headlines[i] = article.Title
+		}
+		if summary, err := GenerateSummary(headlines, language, p.GroqToken); err == nil {
+			manifest.Summary = summary
+		} else {
+			log.Printf("could not generate episode summary: %v", err)
+		}
+
+		if err := catalogue.PublishEpisode(ctx, episodeID, duration, manifest); err != nil {
+			return fail("the episode could not be published", err)
+		}
+		if event.JobID != "" {
+			// Ending the job lets another attempt finish it rather than
+			// leaving it in progress for good
+			if err := catalogue.CompleteJob(ctx, event.JobID, episodeID); err != nil {
+				endJob("the episode could not be recorded")
+				return "", err
+			}
+		}
+	}
+
+	return fileName, nil
+}
+
+// writeSegment writes the audio of a story's segment to out and returns the
+// number of bytes written. Cached audio is copied from storage; otherwise the
+// dialogue is synthesized and cached for later episodes.
+func (p *Pipeline) writeSegment(ctx context.Context, synthesizer *Synthesizer, hash, language, dialogue string, cached *Segment, out io.Writer) (int64, error) {
+	if cached != nil {
+		var buf bytes.Buffer
+		if err := p.Storage.Get(ctx, cached.AudioKey, &buf); err == nil {
+			return io.Copy(out, &buf)
+		} else {
+			log.Printf("could not reuse segment %s, synthesizing it again: %v", cached.AudioKey, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := synthesizer.Synthesize(ctx, dialogue, &buf); err != nil {
+		return 0, err
+	}
+
+	if p.Catalogue != nil {
+		segment := Segment{Script: dialogue, AudioKey: SegmentAudioKey(hash, language)}
+		if err := p.Storage.Put(ctx, segment.AudioKey, bytes.NewReader(buf.Bytes())); err != nil {
+			log.Printf("could not cache segment %s: %v", segment.AudioKey, err)
+		} else if err := p.Catalogue.SaveSegment(ctx, hash, language, segment); err != nil {
+			log.Printf("could not cache segment %s: %v", segment.AudioKey, err)
+		}
+	}
+	return io.Copy(out, &buf)
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Generation queue statuses shared with the backend's generation_queue table.
+// Requests that run out of attempts are dead-lettered and kept for review.
+const (
+	QueueStatusPending = "pending"
+	QueueStatusRunning = "running"
+	QueueStatusDone    = "done"
+	QueueStatusDead    = "dead"
+)
+
+// QueuedEvent is a generation request taken from the queue.
+type QueuedEvent struct {
+	ID          string
+	Event       Event
+	Attempts    int
+	MaxAttempts int
+	// Abandoned is set when the worker running the previous attempt stopped
+	// without finishing it.
+	Abandoned bool
+}
+
+// Enqueue adds a generation request to the queue.
+func (c *Catalogue) Enqueue(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	if _, err := c.db.ExecContext(ctx, `INSERT INTO generation_queue (payload) VALUES ($1)`, payload); err != nil {
+		return fmt.Errorf("failed to enqueue event: %w", err)
+	}
+	return nil
+}
+
+// ClaimQueued takes the next due request for a worker, or nil if there is
+// none. Requests whose worker has held them for longer than timeout are
+// taken over. Concurrent workers skip each other's rows instead of waiting.
+func (c *Catalogue) ClaimQueued(ctx context.Context, worker string, timeout time.Duration) (*QueuedEvent, error) {
+	query := `
+		UPDATE generation_queue q
+		SET status = $1, attempts = q.attempts + 1, locked_by = $2, locked_at = now(), updated_at = now()
+		FROM (
+			SELECT id, status = $1 AS abandoned
+			FROM generation_queue
+			WHERE (status = $3 AND run_at <= now())
+				OR (status = $1 AND locked_at < now() - make_interval(secs => $4))
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		) due
+		WHERE q.id = due.id
+		RETURNING q.id, q.payload, q.attempts, q.max_attempts, due.abandoned`
+
+	var item QueuedEvent
+	var payload []byte
+	err := c.db.QueryRowContext(ctx, query, QueueStatusRunning, worker, QueueStatusPending, timeout.Seconds()).
+		Scan(&item.ID, &payload, &item.Attempts, &item.MaxAttempts, &item.Abandoned)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim queued event: %w", err)
+	}
+
+	if err := json.Unmarshal(payload, &item.Event); err != nil {
+		return nil, fmt.Errorf("failed to decode queued event %s: %w", item.ID, err)
+	}
+	return &item, nil
+}
+
+// CompleteQueued marks a request as done.
+func (c *Catalogue) CompleteQueued(ctx context.Context, id string) error {
+	query := `
+		UPDATE generation_queue
+		SET status = $1, locked_by = NULL, locked_at = NULL, last_error = NULL, updated_at = now()
+		WHERE id = $2`
+
+	if _, err := c.db.ExecContext(ctx, query, QueueStatusDone, id); err != nil {
+		return fmt.Errorf("failed to complete queued event: %w", err)
+	}
+	return nil
+}
+
+// RetryQueued returns a failed request to the queue to run again after delay.
+func (c *Catalogue) RetryQueued(ctx context.Context, id string, delay time.Duration, message string) error {
+	query := `
+		UPDATE generation_queue
+		SET status = $1, run_at = now() + make_interval(secs => $2), locked_by = NULL, locked_at = NULL,
+			last_error = $3, updated_at = now()
+		WHERE id = $4`
+
+	if _, err := c.db.ExecContext(ctx, query, QueueStatusPending, delay.Seconds(), message, id); err != nil {
+		return fmt.Errorf("failed to retry queued event: %w", err)
+	}
+	return nil
+}
+
+// DeadLetterQueued sets aside a request that has run out of attempts.
+func (c *Catalogue) DeadLetterQueued(ctx context.Context, id, message string) error {
+	query := `
+		UPDATE generation_queue
+		SET status = $1, locked_by = NULL, locked_at = NULL, last_error = $2, updated_at = now()
+		WHERE id = $3`
+
+	if _, err := c.db.ExecContext(ctx, query, QueueStatusDead, message, id); err != nil {
+		return fmt.Errorf("failed to dead-letter queued event: %w", err)
+	}
+	return nil
+}
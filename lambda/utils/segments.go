@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Segment is the script and audio of the discussion of one story. Segments
+// are cached by article so a story that appears in several episodes, such as
+// the shared episode and a personal one, is only generated once.
+type Segment struct {
+	Script   string
+	AudioKey string
+}
+
+// ArticleHash identifies an article in the segment cache by its URL, or by
+// its title when it has none.
+func ArticleHash(article Article) string {
+	key := article.URL
+	if key == "" {
+		key = article.Title
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// SegmentAudioKey is where the audio of a segment is stored.
+func SegmentAudioKey(hash, language string) string {
+	return fmt.Sprintf("segments/%s_%s.mp3", hash, language)
+}
+
+// GetSegment returns the cached segment for an article in a language, or nil
+// if there is none.
+func (c *Catalogue) GetSegment(ctx context.Context, hash, language string) (*Segment, error) {
+	var segment Segment
+	query := `SELECT script, audio_key FROM segments WHERE article_hash = $1 AND language = $2`
+
+	err := c.db.QueryRowContext(ctx, query, hash, language).Scan(&segment.Script, &segment.AudioKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up segment: %w", err)
+	}
+	return &segment, nil
+}
+
+// SaveSegment caches the segment for an article in a language.
+func (c *Catalogue) SaveSegment(ctx context.Context, hash, language string, segment Segment) error {
+	query := `
+		INSERT INTO segments (article_hash, language, model, script, audio_key)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (article_hash, language) DO UPDATE
+		SET model = EXCLUDED.model, script = EXCLUDED.script, audio_key = EXCLUDED.audio_key, created_at = now()`
+
+	if _, err := c.db.ExecContext(ctx, query, hash, language, DialogueModel, segment.Script, segment.AudioKey); err != nil {
+		return fmt.Errorf("failed to save segment: %w", err)
+	}
+	return nil
+}
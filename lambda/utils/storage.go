@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Storage holds episode audio, transcripts and cached segments under the keys
+// the backend serves them from.
+type Storage interface {
+	Put(ctx context.Context, key string, body io.Reader) error
+	Get(ctx context.Context, key string, w io.Writer) error
+}
+
+// NewStorage creates the storage named by backend, "s3" or "local", matching
+// the backend's STORAGE_BACKEND setting.
+func NewStorage(ctx context.Context, backend, bucket, dir string) (Storage, error) {
+	switch backend {
+	case "", "s3":
+		return NewS3Storage(ctx, bucket)
+	case "local":
+		return NewLocalStorage(dir)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
+
+// S3Storage implements Storage on an S3 bucket.
+type S3Storage struct {
+	client *s3.Client
+	bucket string
+}
+
+// NewS3Storage creates an S3Storage for bucket.
+func NewS3Storage(ctx context.Context, bucket string) (*S3Storage, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS config: %w", err)
+	}
+	return &S3Storage{client: s3.NewFromConfig(cfg), bucket: bucket}, nil
+}
+
+// Put uploads an object.
+func (s *S3Storage) Put(ctx context.Context, key string, body io.Reader) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+		Body:   body,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload '%s': %w", key, err)
+	}
+	return nil
+}
+
+// Get copies an object into w.
+func (s *S3Storage) Get(ctx context.Context, key string, w io.Writer) error {
+	resp, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to download '%s': %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to download '%s': %w", key, err)
+	}
+	return nil
+}
+
+// LocalStorage implements Storage on a directory of the local filesystem.
+type LocalStorage struct {
+	dir string
+}
+
+// NewLocalStorage creates a LocalStorage rooted at dir.
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("LOCAL_STORAGE_DIR environment variable is not set")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create storage directory: %w", err)
+	}
+	return &LocalStorage{dir: dir}, nil
+}
+
+// path returns where key is stored. Keys are cleaned against the root so they
+// can't escape the storage directory.
+func (s *LocalStorage) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(filepath.Clean("/"+key)))
+}
+
+// Put writes an object, replacing it atomically.
+func (s *LocalStorage) Put(ctx context.Context, key string, body io.Reader) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to store '%s': %w", key, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to store '%s': %w", key, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to store '%s': %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to store '%s': %w", key, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to store '%s': %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to store '%s': %w", key, err)
+	}
+	return nil
+}
+
+// Get copies an object into w.
+func (s *LocalStorage) Get(ctx context.Context, key string, w io.Writer) error {
+	file, err := os.Open(s.path(key))
+	if err != nil {
+		return fmt.Errorf("failed to open '%s': %w", key, err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(w, file); err != nil {
+		return fmt.Errorf("failed to read '%s': %w", key, err)
+	}
+	return nil
+}
@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"context"
 	"io"
-	"os"
 	"strings"
 
 	//AWS SDKs
@@ -13,21 +12,25 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/polly/types"
 )
 
-func SynthesizePodcast(script, outputFile string) error {
-	ctx := context.Background()
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion("us-east-1"))
-	if err != nil {
-		return err
-	}
-
-	client := polly.NewFromConfig(cfg)
+// Synthesizer reads scripts aloud with the hosts' voices for a language.
+type Synthesizer struct {
+	client   *polly.Client
+	language Language
+}
 
-	outFile, err := os.Create(outputFile)
+// NewSynthesizer creates a Synthesizer for a language.
+func NewSynthesizer(ctx context.Context, language Language) (*Synthesizer, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion("us-east-1"))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer outFile.Close()
+	return &Synthesizer{client: polly.NewFromConfig(cfg), language: language}, nil
+}
 
+// Synthesize writes the audio for one script to out, line by line, and
+// returns the number of bytes written.
+func (s *Synthesizer) Synthesize(ctx context.Context, script string, out io.Writer) (int64, error) {
+	var written int64
 	scanner := bufio.NewScanner(strings.NewReader(script))
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
@@ -38,13 +41,13 @@ func SynthesizePodcast(script, outputFile string) error {
 		// Determine speaker voice and remove prefix
 		var voice types.VoiceId
 		if strings.HasPrefix(line, "Alice:") {
-			voice = types.VoiceIdDanielle
+			voice = s.language.AliceVoice
 			line = strings.TrimPrefix(line, "Alice:")
 		} else if strings.HasPrefix(line, "Bob:") {
-			voice = types.VoiceIdStephen
+			voice = s.language.BobVoice
 			line = strings.TrimPrefix(line, "Bob:")
 		} else {
-			voice = types.VoiceIdDanielle
+			voice = s.language.AliceVoice
 		}
 
 		line = strings.TrimSpace(line)
@@ -56,16 +59,26 @@ func SynthesizePodcast(script, outputFile string) error {
 			Engine:       types.EngineGenerative,
 		}
 
-		resp, err := client.SynthesizeSpeech(ctx, input)
+		resp, err := s.client.SynthesizeSpeech(ctx, input)
 		if err != nil {
-			return err
+			return written, err
 		}
-		defer resp.AudioStream.Close()
 
-		if _, err := io.Copy(outFile, resp.AudioStream); err != nil {
-			return err
+		n, err := io.Copy(out, resp.AudioStream)
+		resp.AudioStream.Close()
+		written += n
+		if err != nil {
+			return written, err
 		}
 	}
 
-	return scanner.Err()
+	return written, scanner.Err()
+}
+
+// Polly returns 24 kHz MP3 audio at 48 kbps for generative voices.
+const pollyMP3BitRate = 48000
+
+// AudioSeconds converts a length of Polly MP3 audio in bytes to seconds.
+func AudioSeconds(bytes int64) int {
+	return int(bytes * 8 / pollyMP3BitRate)
 }
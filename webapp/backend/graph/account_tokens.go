@@ -0,0 +1,108 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/ShavaizKhan/DailyNewsPodcast/webapp-backend/graph/model"
+)
+
+// Purposes an account token can be issued for.
+const (
+	AccountTokenPasswordReset     = "password_reset"
+	AccountTokenEmailVerification = "email_verification"
+)
+
+// How long account tokens stay valid.
+const (
+	passwordResetTokenTTL     = time.Hour
+	emailVerificationTokenTTL = 24 * time.Hour
+)
+
+// ErrAccountTokenInvalid is returned for unknown, expired or already used tokens.
+var ErrAccountTokenInvalid = errors.New("token is invalid or has expired")
+
+// AccountTokenStore defines the interface for single-use account tokens.
+type AccountTokenStore interface {
+	CreateAccountToken(ctx context.Context, userID, purpose, tokenHash string, expiresAt time.Time) error
+	ConsumeAccountToken(ctx context.Context, purpose, tokenHash string) (string, error)
+}
+
+// CreateAccountToken stores a new token, invalidating any earlier unused token
+// the user has for the same purpose.
+func (p *PGStore) CreateAccountToken(ctx context.Context, userID, purpose, tokenHash string, expiresAt time.Time) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	invalidate := `UPDATE account_tokens SET used_at = now() WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`
+	if _, err := tx.ExecContext(ctx, invalidate, userID, purpose); err != nil {
+		return fmt.Errorf("failed to invalidate account tokens: %w", err)
+	}
+
+	insert := `INSERT INTO account_tokens (user_id, purpose, token_hash, expires_at) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.ExecContext(ctx, insert, userID, purpose, tokenHash, expiresAt); err != nil {
+		return fmt.Errorf("failed to create account token: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// ConsumeAccountToken marks a token as used and returns the user it belongs to.
+func (p *PGStore) ConsumeAccountToken(ctx context.Context, purpose, tokenHash string) (string, error) {
+	query := `
+		UPDATE account_tokens
+		SET used_at = now()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > now()
+		RETURNING user_id`
+
+	var userID string
+	err := p.db.QueryRowContext(ctx, query, tokenHash, purpose).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrAccountTokenInvalid
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to consume account token: %w", err)
+	}
+	return userID, nil
+}
+
+// issueAccountToken creates a token for purpose and returns it. Only its hash
+// is stored.
+func (r *Resolver) issueAccountToken(ctx context.Context, userID, purpose string, ttl time.Duration) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", fmt.Errorf("could not generate account token: %w", err)
+	}
+
+	if err := r.Tokens.CreateAccountToken(ctx, userID, purpose, hashToken(token), time.Now().Add(ttl)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// sendVerificationEmail emails the user a link to confirm their address.
+func (r *Resolver) sendVerificationEmail(ctx context.Context, user *model.User) error {
+	token, err := r.issueAccountToken(ctx, user.ID, AccountTokenEmailVerification, emailVerificationTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return r.Mailer.Send(ctx, Message{
+		To:      user.Email,
+		Subject: "Confirm your Daily News Podcast email",
+		Body: fmt.Sprintf("Welcome to Daily News Podcast!\n\n"+
+			"Follow this link within the next 24 hours to confirm your email address:\n%s", r.appLink("/verify-email", token)),
+	})
+}
+
+// appLink builds a link to a page of the web app carrying token.
+func (r *Resolver) appLink(path, token string) string {
+	return r.AppURL + path + "?token=" + url.QueryEscape(token)
+}
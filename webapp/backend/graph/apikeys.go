@@ -0,0 +1,199 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ShavaizKhan/DailyNewsPodcast/webapp-backend/graph/model"
+)
+
+const (
+	// apiKeyPrefix marks API keys so they are easy to recognise, for example
+	// by secret scanners.
+	apiKeyPrefix = "dnp_"
+
+	// apiKeyDisplayLength is how much of a key is kept to tell keys apart.
+	apiKeyDisplayLength = len(apiKeyPrefix) + 8
+
+	// maxAPIKeysPerUser caps how many active keys a user can have.
+	maxAPIKeysPerUser = 20
+)
+
+// Errors returned by the API key store.
+var (
+	ErrAPIKeyInvalid  = errors.New("API key is invalid or has been revoked")
+	ErrTooManyAPIKeys = errors.New("too many API keys")
+)
+
+// APIKeyStore defines the interface for personal API keys.
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, userID, name, prefix, keyHash string, scopes []string) (*APIKey, error)
+	ListAPIKeys(ctx context.Context, userID string) ([]*APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, id string) error
+	UseAPIKey(ctx context.Context, keyHash string) (*APIKey, error)
+}
+
+// APIKey is a personal API key. Scopes hold the stored, lower-case names.
+type APIKey struct {
+	ID         string
+	UserID     string
+	Name       string
+	Prefix     string
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// toModel converts an APIKey to its GraphQL representation.
+func (k *APIKey) toModel() *model.APIKey {
+	key := &model.APIKey{
+		ID:        k.ID,
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Scopes:    make([]model.APIKeyScope, len(k.Scopes)),
+		CreatedAt: k.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	for i, scope := range k.Scopes {
+		key.Scopes[i] = model.APIKeyScope(strings.ToUpper(scope))
+	}
+	if k.LastUsedAt != nil {
+		lastUsedAt := k.LastUsedAt.UTC().Format(time.RFC3339)
+		key.LastUsedAt = &lastUsedAt
+	}
+	return key
+}
+
+// AuthenticateAPIKey verifies an API key and returns a context carrying its
+// user and scopes. Requests made with a key always have the user role, so
+// keys can't be used for admin operations.
+func (r *Resolver) AuthenticateAPIKey(ctx context.Context, key string) (context.Context, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	apiKey, err := r.Keys.UseAPIKey(ctx, hashToken(key))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = NewContextWithUserID(ctx, apiKey.UserID)
+	ctx = newContextWithRole(ctx, RoleUser)
+	return context.WithValue(ctx, apiKeyScopesCtxKey, apiKey.Scopes), nil
+}
+
+// apiKeyScopesFromContext returns the scopes of the API key a request was
+// made with, and false for requests made with an access token.
+func apiKeyScopesFromContext(ctx context.Context) ([]string, bool) {
+	scopes, ok := ctx.Value(apiKeyScopesCtxKey).([]string)
+	return scopes, ok
+}
+
+// allowedForAPIKey reports whether the request may use scope. Requests made
+// with an access token may use everything.
+func allowedForAPIKey(ctx context.Context, scope model.APIKeyScope) bool {
+	scopes, ok := apiKeyScopesFromContext(ctx)
+	return !ok || slices.Contains(scopes, strings.ToLower(string(scope)))
+}
+
+// CreateAPIKey stores a new API key.
+func (p *PGStore) CreateAPIKey(ctx context.Context, userID, name, prefix, keyHash string, scopes []string) (*APIKey, error) {
+	key := APIKey{UserID: userID, Name: name, Prefix: prefix, Scopes: scopes}
+	query := `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes)
+		SELECT $1, $2, $3, $4, $5
+		WHERE (SELECT count(*) FROM api_keys WHERE user_id = $1 AND revoked_at IS NULL) < $6
+		RETURNING id, created_at`
+
+	err := p.db.QueryRowContext(ctx, query, userID, name, prefix, keyHash, scopes, maxAPIKeysPerUser).Scan(&key.ID, &key.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("user already has %d API keys: %w", maxAPIKeysPerUser, ErrTooManyAPIKeys)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+	return &key, nil
+}
+
+// ListAPIKeys returns a user's active API keys, newest first.
+func (p *PGStore) ListAPIKeys(ctx context.Context, userID string) ([]*APIKey, error) {
+	query := `
+		SELECT id, user_id, name, prefix, array_to_string(scopes, ','), created_at, last_used_at
+		FROM api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC`
+
+	rows, err := p.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey revokes one of a user's API keys.
+func (p *PGStore) RevokeAPIKey(ctx context.Context, userID, id string) error {
+	query := `UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+
+	result, err := p.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("API key '%s' not found: %w", id, sql.ErrNoRows)
+	}
+	return nil
+}
+
+// UseAPIKey looks up an active API key by its hash and records that it was used.
+func (p *PGStore) UseAPIKey(ctx context.Context, keyHash string) (*APIKey, error) {
+	query := `
+		UPDATE api_keys
+		SET last_used_at = now()
+		WHERE key_hash = $1 AND revoked_at IS NULL
+		RETURNING id, user_id, name, prefix, array_to_string(scopes, ','), created_at, last_used_at`
+
+	key, err := scanAPIKey(p.db.QueryRowContext(ctx, query, keyHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPIKeyInvalid
+	}
+	return key, err
+}
+
+// scanAPIKey scans a row selected with the API key columns.
+func scanAPIKey(row interface{ Scan(...any) error }) (*APIKey, error) {
+	var key APIKey
+	var scopes string
+	var lastUsedAt sql.NullTime
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &scopes, &key.CreatedAt, &lastUsedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan API key: %w", err)
+	}
+
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	return &key, nil
+}
@@ -0,0 +1,91 @@
+package graph
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ShavaizKhan/DailyNewsPodcast/webapp-backend/graph/model"
+)
+
+// How long the signed audio URLs handed out through GraphQL stay valid. This
+// is long enough to play an episode through with a few pauses.
+const streamURLExpiry = 2 * time.Hour
+
+// audioURL returns a signed URL to stream an episode through ServeAudio.
+func (r *Resolver) audioURL(episodeID string) string {
+	expires := time.Now().Add(streamURLExpiry).Unix()
+	return fmt.Sprintf("%s/audio/%s?expires=%d&signature=%s", r.PublicURL, url.PathEscape(episodeID), expires, r.signAudio(episodeID, expires))
+}
+
+// signAudio signs an episode ID and expiry time with the audio URL secret.
+func (r *Resolver) signAudio(episodeID string, expires int64) string {
+	mac := hmac.New(sha256.New, r.AudioURLSecret)
+	fmt.Fprintf(mac, "%s:%d", episodeID, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validAudioSignature reports whether query carries an unexpired signature
+// produced by audioURL for the episode.
+func (r *Resolver) validAudioSignature(episodeID string, query url.Values) bool {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(query.Get("signature")), []byte(r.signAudio(episodeID, expires)))
+}
+
+// ServeAudio serves GET /audio/{episodeID}. Requests are authenticated either
+// by a Bearer token, through AuthMiddleware, or by a signed URL from audioURL.
+// A signed URL is a bearer link: until it expires, anyone who has it can
+// stream the episode, which is why it is kept short-lived.
+func (r *Resolver) ServeAudio(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	episodeID := req.PathValue("episodeID")
+
+	// API keys need the EPISODES_READ scope to download audio.
+	userID, err := GetUserIDFromContext(ctx)
+	authorized := err == nil && allowedForAPIKey(ctx, model.APIKeyScopeEpisodesRead)
+	signed := r.validAudioSignature(episodeID, req.URL.Query())
+	if !authorized && !signed {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// A signed URL is enough on its own, as it is only handed out along with
+	// an episode its holder could see.
+	episode, err := r.Catalogue.GetEpisodeByID(ctx, episodeID)
+	if err != nil || episode.Status != EpisodeStatusPublished || (!signed && !episode.visibleTo(userID)) {
+		http.NotFound(w, req)
+		return
+	}
+
+	r.streamEpisode(w, req, episode)
+}
+
+// streamEpisode writes an episode's audio from the storage backend. Range,
+// If-Range and conditional requests are handled by http.ServeContent, so
+// players can seek and resume.
+func (r *Resolver) streamEpisode(w http.ResponseWriter, req *http.Request, episode *Episode) {
+	object, err := r.Storage.Open(req.Context(), episode.StorageKey)
+	if err != nil {
+		log.Printf("audio: %v", err)
+		http.Error(w, "audio unavailable", http.StatusBadGateway)
+		return
+	}
+	defer object.Content.Close()
+
+	w.Header().Set("Content-Type", "audio/mpeg")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	if object.ETag != "" {
+		w.Header().Set("ETag", object.ETag)
+	}
+
+	http.ServeContent(w, req, "", object.ModTime, object.Content)
+}
@@ -1,30 +1,387 @@
 package graph
 
 import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte("super-secret-key")
+// Defaults for the JWT_* environment variables.
+const (
+	defaultJWTIssuer   = "dailynewspodcast"
+	defaultJWTAudience = "dailynewspodcast-api"
+	defaultJWTTTL      = 15 * time.Minute
+	defaultRefreshTTL  = 30 * 24 * time.Hour
+)
+
+// JWTConfig controls how access tokens are signed and verified.
+type JWTConfig struct {
+	Algorithm string
+	Issuer    string
+	Audience  string
+	TTL       time.Duration
+
+	// RefreshTTL is how long a refresh token can be exchanged for.
+	RefreshTTL time.Duration
+
+	// Keys holds every key accepted for verification, by key ID.
+	Keys map[string]*SigningKey
+	// ActiveKeyID names the key new tokens are signed with.
+	ActiveKeyID string
+}
+
+// SigningKey is one key in the JWT keyset. For HS256 both fields hold the
+// shared secret; verification-only keys have no Private key.
+type SigningKey struct {
+	Private crypto.PrivateKey
+	Public  crypto.PublicKey
+}
+
+// LoadJWTConfig reads the JWT configuration from the environment:
+//
+//   - JWT_ALGORITHM: HS256 (default), RS256 or EdDSA.
+//   - JWT_SECRET: a single HS256 secret, with key ID JWT_KEY_ID ("default").
+//   - JWT_KEYS_DIR: a directory of keys named after their key ID. HS256
+//     secrets are stored as <kid>.secret, RS256 and EdDSA private keys as
+//     <kid>.pem and verification-only public keys as <kid>.pub.pem.
+//   - JWT_ACTIVE_KID: the key used for signing, required when more than one
+//     private key is configured.
+//   - JWT_ISSUER, JWT_AUDIENCE, JWT_TTL (access tokens) and JWT_REFRESH_TTL.
+func LoadJWTConfig() (*JWTConfig, error) {
+	cfg := &JWTConfig{
+		Algorithm:   envOr("JWT_ALGORITHM", jwt.SigningMethodHS256.Alg()),
+		Issuer:      envOr("JWT_ISSUER", defaultJWTIssuer),
+		Audience:    envOr("JWT_AUDIENCE", defaultJWTAudience),
+		TTL:         defaultJWTTTL,
+		RefreshTTL:  defaultRefreshTTL,
+		Keys:        map[string]*SigningKey{},
+		ActiveKeyID: os.Getenv("JWT_ACTIVE_KID"),
+	}
+
+	if err := durationEnv("JWT_TTL", &cfg.TTL); err != nil {
+		return nil, err
+	}
+	if err := durationEnv("JWT_REFRESH_TTL", &cfg.RefreshTTL); err != nil {
+		return nil, err
+	}
+
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		if cfg.Algorithm != jwt.SigningMethodHS256.Alg() {
+			return nil, fmt.Errorf("JWT_SECRET can only be used with HS256")
+		}
+		cfg.Keys[envOr("JWT_KEY_ID", "default")] = &SigningKey{Private: []byte(secret), Public: []byte(secret)}
+	}
+
+	if dir := os.Getenv("JWT_KEYS_DIR"); dir != "" {
+		if err := cfg.loadKeysDir(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(cfg.Keys) == 0 {
+		return nil, fmt.Errorf("JWT_SECRET or JWT_KEYS_DIR environment variable must be set")
+	}
+	return cfg, nil
+}
+
+// loadKeysDir adds every key file in dir to the keyset.
+func (cfg *JWTConfig) loadKeysDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("unable to read JWT_KEYS_DIR: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("unable to read JWT key '%s': %w", name, err)
+		}
+
+		var kid string
+		var key *SigningKey
+		switch {
+		case strings.HasSuffix(name, ".secret"):
+			kid = strings.TrimSuffix(name, ".secret")
+			secret := []byte(strings.TrimSpace(string(data)))
+			key = &SigningKey{Private: secret, Public: secret}
+		case strings.HasSuffix(name, ".pub.pem"):
+			kid = strings.TrimSuffix(name, ".pub.pem")
+			key, err = parsePublicKeyPEM(data)
+		case strings.HasSuffix(name, ".pem"):
+			kid = strings.TrimSuffix(name, ".pem")
+			key, err = parsePrivateKeyPEM(data)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("invalid JWT key '%s': %w", name, err)
+		}
+
+		// A private key and its public half may both be present.
+		if existing, ok := cfg.Keys[kid]; ok && existing.Private != nil {
+			continue
+		}
+		cfg.Keys[kid] = key
+	}
+	return nil
+}
+
+func parsePrivateKeyPEM(data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var private crypto.PrivateKey
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if private, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, err
+		}
+	}
+
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{Private: k, Public: &k.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{Private: k, Public: k.Public()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", private)
+	}
+}
+
+func parsePublicKeyPEM(data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	public, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{Public: public}, nil
+}
+
+// JWTManager issues and verifies access tokens.
+type JWTManager struct {
+	cfg    *JWTConfig
+	method jwt.SigningMethod
+	parser *jwt.Parser
+}
 
-func GenerateJWT(userID string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(72 * time.Hour).Unix(),
+// NewJWTManager validates the keyset against the configured algorithm.
+func NewJWTManager(cfg *JWTConfig) (*JWTManager, error) {
+	var method jwt.SigningMethod
+	switch cfg.Algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		method = jwt.SigningMethodHS256
+	case jwt.SigningMethodRS256.Alg():
+		method = jwt.SigningMethodRS256
+	case jwt.SigningMethodEdDSA.Alg():
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", cfg.Algorithm)
+	}
+
+	var signingKeys []string
+	for kid, key := range cfg.Keys {
+		if !keyMatchesMethod(key.Public, method) {
+			return nil, fmt.Errorf("JWT key '%s' cannot be used with %s", kid, method.Alg())
+		}
+		if key.Private != nil {
+			signingKeys = append(signingKeys, kid)
+		}
+	}
+
+	if cfg.ActiveKeyID == "" {
+		if len(signingKeys) != 1 {
+			return nil, fmt.Errorf("JWT_ACTIVE_KID must name one of the %d signing keys", len(signingKeys))
+		}
+		cfg.ActiveKeyID = signingKeys[0]
+	}
+	if key, ok := cfg.Keys[cfg.ActiveKeyID]; !ok || key.Private == nil {
+		return nil, fmt.Errorf("active JWT key '%s' has no private key", cfg.ActiveKeyID)
+	}
+
+	return &JWTManager{
+		cfg:    cfg,
+		method: method,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{method.Alg()}),
+			jwt.WithIssuer(cfg.Issuer),
+			jwt.WithAudience(cfg.Audience),
+			jwt.WithExpirationRequired(),
+		),
+	}, nil
+}
+
+func keyMatchesMethod(public crypto.PublicKey, method jwt.SigningMethod) bool {
+	switch public.(type) {
+	case []byte:
+		return method == jwt.SigningMethodHS256
+	case *rsa.PublicKey:
+		return method == jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		return method == jwt.SigningMethodEdDSA
+	}
+	return false
+}
+
+// RefreshTTL returns how long refresh tokens stay valid.
+func (m *JWTManager) RefreshTTL() time.Duration {
+	return m.cfg.RefreshTTL
+}
+
+// AccessClaims are the claims carried by an access token. The subject is the
+// user ID, SessionID ties the token to a revocable login session and Role is
+// the user's role when the token was issued.
+type AccessClaims struct {
+	SessionID string `json:"sid,omitempty"`
+	Role      string `json:"role,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// GenerateJWT issues an access token for a user's session, signed with the
+// active key, and returns it together with its expiry time.
+func (m *JWTManager) GenerateJWT(userID, sessionID, role string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(m.cfg.TTL)
+	token := jwt.NewWithClaims(m.method, AccessClaims{
+		SessionID: sessionID,
+		Role:      role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			Issuer:    m.cfg.Issuer,
+			Audience:  jwt.ClaimStrings{m.cfg.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
 	})
-	return token.SignedString(jwtSecret)
+	token.Header["kid"] = m.cfg.ActiveKeyID
+
+	signed, err := token.SignedString(m.cfg.Keys[m.cfg.ActiveKeyID].Private)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
 }
 
-func ParseJWT(tokenStr string) (string, error) {
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
+// ParseJWT verifies an access token and returns its claims. Only the
+// configured algorithm is accepted and the key is chosen by kid.
+func (m *JWTManager) ParseJWT(tokenStr string) (*AccessClaims, error) {
+	var claims AccessClaims
+	_, err := m.parser.ParseWithClaims(tokenStr, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := m.cfg.Keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key.Public, nil
 	})
-	if err != nil || !token.Valid {
-		return "", err
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Subject == "" || claims.SessionID == "" {
+		return nil, errors.New("token has no subject or session")
 	}
-	if claims, ok := token.Claims.(jwt.MapClaims); ok {
-		return claims["user_id"].(string), nil
+	if claims.Role == "" {
+		claims.Role = RoleUser
 	}
-	return "", nil
+	return &claims, nil
+}
+
+// ServeJWKS publishes the public verification keys as a JSON Web Key Set.
+// HS256 secrets are never published, so the set is empty in that mode.
+func (m *JWTManager) ServeJWKS(w http.ResponseWriter, req *http.Request) {
+	kids := make([]string, 0, len(m.cfg.Keys))
+	for kid := range m.cfg.Keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	keys := []map[string]string{}
+	for _, kid := range kids {
+		jwk := map[string]string{"kid": kid, "use": "sig", "alg": m.method.Alg()}
+		switch public := m.cfg.Keys[kid].Public.(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		keys = append(keys, jwk)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+}
+
+// envOr returns the environment variable key, or fallback if it is unset.
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// durationEnv parses the environment variable key into target, if it is set.
+func durationEnv(key string, target *time.Duration) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+	*target = d
+	return nil
+}
+
+// newToken returns a random, URL-safe opaque token.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hash under which an opaque token is stored.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
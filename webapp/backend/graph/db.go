@@ -3,10 +3,12 @@ package graph
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/ShavaizKhan/DailyNewsPodcast/webapp-backend/graph/model"
+	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/joho/godotenv"
 	_ "github.com/joho/godotenv/autoload"
@@ -14,10 +16,41 @@ import (
 
 // UserStore defines the interface for our database operations.
 type UserStore interface {
-	CreateUser(ctx context.Context, email, passwordHash, country, topic string) (*model.User, error)
+	CreateUser(ctx context.Context, email, passwordHash string) (*model.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*model.User, error)
 	GetUserByID(ctx context.Context, id string) (*model.User, error)
-	UpdateUserPreferences(ctx context.Context, id, country, topic string) (*model.Preferences, error)
+	UpdateUserPreferences(ctx context.Context, id string, topics []*model.TopicWeight, countries []*model.CountryWeight, language *string) (*model.Preferences, error)
+	UpdateUserFilters(ctx context.Context, id string, filters *model.ArticleFilters) (*model.ArticleFilters, error)
+	UpdateDeliverySchedule(ctx context.Context, id, timezone string, deliveryTime *string) (*model.Preferences, error)
+	UpdateUserPassword(ctx context.Context, id, passwordHash string) error
+	MarkEmailVerified(ctx context.Context, id string) error
+	ListUsers(ctx context.Context, search string, afterEmail string, limit int) ([]*model.User, error)
+	CountUsers(ctx context.Context, search string) (int, error)
+	SetUserRole(ctx context.Context, id, role string) (*model.User, error)
+	DeleteUser(ctx context.Context, id string) error
+}
+
+// ErrEmailTaken is returned when creating a user whose email is already registered.
+var ErrEmailTaken = errors.New("email is already registered")
+
+// Postgres error codes for unique constraint violations and malformed values,
+// such as an ID that isn't a UUID.
+const (
+	pgUniqueViolation           = "23505"
+	pgInvalidTextRepresentation = "22P02"
+)
+
+// isUniqueViolation reports whether err was caused by a unique constraint.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation
+}
+
+// isInvalidText reports whether err was caused by a value Postgres couldn't
+// parse, which lookups by ID treat as not found.
+func isInvalidText(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgInvalidTextRepresentation
 }
 
 // PGStore implements UserStore using the standard library.
@@ -52,73 +85,173 @@ func (p *PGStore) Close() {
 	p.db.Close()
 }
 
-// CreateUser saves a new user to the database.
-func (p *PGStore) CreateUser(ctx context.Context, email, passwordHash, country, topic string) (*model.User, error) {
+// CreateUser saves a new user to the database with the default preferences.
+func (p *PGStore) CreateUser(ctx context.Context, email, passwordHash string) (*model.User, error) {
 	var user model.User
-	var preferences model.Preferences
 	query := `
-		INSERT INTO users (email, password_hash, country, topic)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, email, country, topic`
+		WITH new_user AS (
+			INSERT INTO users (email, password_hash)
+			VALUES ($1, $2)
+			RETURNING id, email, email_verified_at, role
+		), topics AS (
+			INSERT INTO user_topics (user_id, topic) SELECT id, $3 FROM new_user
+		), countries AS (
+			INSERT INTO user_countries (user_id, country) SELECT id, $4 FROM new_user
+		)
+		SELECT id, email, email_verified_at IS NOT NULL, role FROM new_user`
 
-	err := p.db.QueryRowContext(ctx, query, email, passwordHash, country, topic).Scan(&user.ID, &user.Email, &preferences.Country, &preferences.Topic)
+	var role string
+	err := p.db.QueryRowContext(ctx, query, email, passwordHash, defaultTopic, defaultCountry).Scan(&user.ID, &user.Email, &user.EmailVerified, &role)
+	if isUniqueViolation(err) {
+		return nil, ErrEmailTaken
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	user.Preferences = &preferences
+	user.Role = modelRole(role)
+	user.Preferences = newPreferences(
+		[]*model.TopicWeight{{Topic: defaultTopic, Weight: minPreferenceWeight}},
+		[]*model.CountryWeight{{Country: defaultCountry, Weight: minPreferenceWeight}},
+		defaultLanguage,
+		emptyArticleFilters(),
+	)
 	return &user, nil
 }
 
-// GetUserByEmail fetches a user by their email address.
+// GetUserByEmail fetches a user by their normalized email address.
 func (p *PGStore) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
 	var user model.User
-	var preferences model.Preferences
 	query := `
-		SELECT id, email, password_hash, country, topic
+		SELECT id, email, email_verified_at IS NOT NULL, role, password_hash, ` + userPreferencesColumns + `
 		FROM users
-		WHERE email = $1`
+		WHERE lower(email) = $1`
 
-	err := p.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Email, &user.PasswordHash, &preferences.Country, &preferences.Topic)
+	var role string
+	var preferences preferencesRow
+	dest := append([]any{&user.ID, &user.Email, &user.EmailVerified, &role, &user.PasswordHash}, preferences.dest()...)
+	err := p.db.QueryRowContext(ctx, query, email).Scan(dest...)
 	if err != nil {
 		return nil, fmt.Errorf("user with email '%s' not found: %w", email, err)
 	}
 
-	user.Preferences = &preferences
+	user.Role = modelRole(role)
+	user.Preferences, err = preferences.decode()
+	if err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 
 // GetUserByID fetches a user by their ID.
 func (p *PGStore) GetUserByID(ctx context.Context, id string) (*model.User, error) {
 	var user model.User
-	var preferences model.Preferences
 	query := `
-		SELECT id, email, country, topic
+		SELECT id, email, email_verified_at IS NOT NULL, role, ` + userPreferencesColumns + `
 		FROM users
 		WHERE id = $1`
 
-	err := p.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Email, &preferences.Country, &preferences.Topic)
+	var role string
+	var preferences preferencesRow
+	dest := append([]any{&user.ID, &user.Email, &user.EmailVerified, &role}, preferences.dest()...)
+	err := p.db.QueryRowContext(ctx, query, id).Scan(dest...)
 	if err != nil {
 		return nil, fmt.Errorf("user with ID '%s' not found: %w", id, err)
 	}
 
-	user.Preferences = &preferences
+	user.Role = modelRole(role)
+	user.Preferences, err = preferences.decode()
+	if err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 
-// UpdateUserPreferences updates a user's country and topic preferences.
-func (p *PGStore) UpdateUserPreferences(ctx context.Context, id, country, topic string) (*model.Preferences, error) {
-	var preferences model.Preferences
+// UpdateUserPassword replaces a user's password hash.
+func (p *PGStore) UpdateUserPassword(ctx context.Context, id, passwordHash string) error {
+	query := `UPDATE users SET password_hash = $1 WHERE id = $2`
+
+	if _, err := p.db.ExecContext(ctx, query, passwordHash, id); err != nil {
+		return fmt.Errorf("failed to update user password: %w", err)
+	}
+	return nil
+}
+
+// MarkEmailVerified records that a user has confirmed their email address.
+func (p *PGStore) MarkEmailVerified(ctx context.Context, id string) error {
+	query := `UPDATE users SET email_verified_at = COALESCE(email_verified_at, now()) WHERE id = $1`
+
+	if _, err := p.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to mark email as verified: %w", err)
+	}
+	return nil
+}
+
+// ListUsers returns users ordered by email, optionally filtered by a search
+// term matched against the email, starting after afterEmail.
+func (p *PGStore) ListUsers(ctx context.Context, search string, afterEmail string, limit int) ([]*model.User, error) {
 	query := `
-		UPDATE users
-		SET country = $1, topic = $2
-		WHERE id = $3
-		RETURNING country, topic`
+		SELECT id, email, email_verified_at IS NOT NULL, role, ` + userPreferencesColumns + `
+		FROM users
+		WHERE ($1 = '' OR email ILIKE '%' || $1 || '%') AND email > $2
+		ORDER BY email
+		LIMIT $3`
 
-	err := p.db.QueryRowContext(ctx, query, country, topic, id).Scan(&preferences.Country, &preferences.Topic)
+	rows, err := p.db.QueryContext(ctx, query, search, afterEmail, limit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update user preferences: %w", err)
+		return nil, fmt.Errorf("failed to list users: %w", err)
 	}
+	defer rows.Close()
 
-	return &preferences, nil
+	var users []*model.User
+	for rows.Next() {
+		var user model.User
+		var role string
+		var preferences preferencesRow
+		dest := append([]any{&user.ID, &user.Email, &user.EmailVerified, &role}, preferences.dest()...)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		user.Role = modelRole(role)
+		if user.Preferences, err = preferences.decode(); err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, nil
+}
+
+// CountUsers counts the users matching a search term.
+func (p *PGStore) CountUsers(ctx context.Context, search string) (int, error) {
+	var count int
+	query := `SELECT count(*) FROM users WHERE $1 = '' OR email ILIKE '%' || $1 || '%'`
+
+	if err := p.db.QueryRowContext(ctx, query, search).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	return count, nil
+}
+
+// SetUserRole changes a user's role.
+func (p *PGStore) SetUserRole(ctx context.Context, id, role string) (*model.User, error) {
+	if _, err := p.db.ExecContext(ctx, `UPDATE users SET role = $1 WHERE id = $2`, role, id); err != nil {
+		return nil, fmt.Errorf("failed to update user role: %w", err)
+	}
+	return p.GetUserByID(ctx, id)
+}
+
+// DeleteUser deletes a user. Their sessions, tokens and feeds are removed by
+// the foreign key cascades.
+func (p *PGStore) DeleteUser(ctx context.Context, id string) error {
+	result, err := p.db.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("user with ID '%s' not found: %w", id, sql.ErrNoRows)
+	}
+	return nil
 }
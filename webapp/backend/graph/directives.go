@@ -0,0 +1,67 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/ShavaizKhan/DailyNewsPodcast/webapp-backend/graph/model"
+)
+
+// Roles as stored in the users table and carried in access tokens.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// modelRole converts a stored role to its GraphQL enum value.
+func modelRole(role string) model.Role {
+	return model.Role(strings.ToUpper(role))
+}
+
+// storedRole converts a GraphQL role to the value stored in the database.
+func storedRole(role model.Role) string {
+	return strings.ToLower(string(role))
+}
+
+// NewDirectives returns the handlers for the schema's access control
+// directives.
+func NewDirectives() DirectiveRoot {
+	return DirectiveRoot{
+		Auth:    authDirective,
+		HasRole: hasRoleDirective,
+	}
+}
+
+// authDirective implements @auth, requiring a signed in user. API keys are
+// only accepted for fields that name a scope the key has.
+func authDirective(ctx context.Context, obj any, next graphql.Resolver, scope *model.APIKeyScope) (any, error) {
+	if _, err := GetUserIDFromContext(ctx); err != nil {
+		return nil, newError(ctx, CodeUnauthenticated, err.Error(), nil)
+	}
+
+	if _, usingAPIKey := apiKeyScopesFromContext(ctx); usingAPIKey {
+		if scope == nil {
+			return nil, newError(ctx, CodeForbidden, "API keys can't be used for this operation", nil)
+		}
+		if !allowedForAPIKey(ctx, *scope) {
+			return nil, newError(ctx, CodeForbidden, fmt.Sprintf("API key is missing the %s scope", *scope), map[string]any{"scope": *scope})
+		}
+	}
+	return next(ctx)
+}
+
+// hasRoleDirective implements @hasRole, requiring a signed in user with the
+// given role. Admins can do everything users can.
+func hasRoleDirective(ctx context.Context, obj any, next graphql.Resolver, role model.Role) (any, error) {
+	if _, err := GetUserIDFromContext(ctx); err != nil {
+		return nil, newError(ctx, CodeUnauthenticated, err.Error(), nil)
+	}
+
+	current := modelRole(roleFromContext(ctx))
+	if current != role && current != model.RoleAdmin {
+		return nil, newError(ctx, CodeForbidden, "you don't have permission to do this", nil)
+	}
+	return next(ctx)
+}
@@ -0,0 +1,419 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ShavaizKhan/DailyNewsPodcast/webapp-backend/graph/model"
+)
+
+// Episode statuses as written by the generation Lambda.
+const (
+	EpisodeStatusGenerating = "generating"
+	EpisodeStatusPublished  = "published"
+	EpisodeStatusFailed     = "failed"
+)
+
+// The Lambda currently publishes a single shared episode per day.
+const (
+	sharedEpisodeCountry = "us"
+	sharedEpisodeTopic   = "general"
+)
+
+// EpisodeStore defines the interface for the episode catalogue.
+type EpisodeStore interface {
+	SaveEpisode(ctx context.Context, episode *Episode) (*Episode, error)
+	GetEpisodeByID(ctx context.Context, id string) (*Episode, error)
+	GetEpisodeByDate(ctx context.Context, date time.Time, country, topic string) (*Episode, error)
+	NearestEpisodeDate(ctx context.Context, date time.Time, country, topic string) (*time.Time, error)
+	ListEpisodes(ctx context.Context, filter EpisodeFilter, after *EpisodeCursor, limit int) ([]*Episode, error)
+	CountEpisodes(ctx context.Context, filter EpisodeFilter) (int, error)
+	// ListUserEpisodeKeys lists the storage keys of the audio and
+	// transcripts of a user's personal episodes.
+	ListUserEpisodeKeys(ctx context.Context, userID string) ([]string, error)
+}
+
+// EpisodeFilter narrows down the published episodes returned by ListEpisodes.
+// Empty fields match everything, and episodes catalogued under several
+// countries or topics match any of them. Only shared episodes are listed,
+// plus the personal episodes of Owner when it is set; the country and topic
+// fields apply to shared episodes only.
+type EpisodeFilter struct {
+	Country   string
+	Countries []string
+	Topic     string
+	Topics    []string
+	From      *time.Time
+	To        *time.Time
+	Owner     string
+	// IncludeShared adds the shared general episode, whatever the country
+	// and topic conditions.
+	IncludeShared bool
+}
+
+// EpisodeCursor is a position in the episode listing, which is ordered from
+// newest to oldest.
+type EpisodeCursor struct {
+	Date time.Time
+	ID   string
+}
+
+// Episode is a row in the episodes catalogue. UserID is set for personal
+// episodes, which only their owner can list or play.
+type Episode struct {
+	ID              string
+	UserID          string
+	Date            time.Time
+	Country         string
+	Topic           string
+	StorageKey      string
+	DurationSeconds int
+	Status          string
+	CreatedAt       time.Time
+	Manifest        EpisodeManifest
+}
+
+// EpisodeManifest describes how an episode was produced. It is stored as JSON
+// and shares its shape with the manifest written by the Lambda.
+type EpisodeManifest struct {
+	Model         string            `json:"model,omitempty"`
+	Title         string            `json:"title,omitempty"`
+	Description   string            `json:"description,omitempty"`
+	Summary       string            `json:"summary,omitempty"`
+	CoverURL      string            `json:"coverUrl,omitempty"`
+	TranscriptKey string            `json:"transcriptKey,omitempty"`
+	Articles      []ManifestArticle `json:"articles,omitempty"`
+	Chapters      []ManifestChapter `json:"chapters,omitempty"`
+}
+
+// ManifestChapter marks where the discussion of an article starts in the audio.
+type ManifestChapter struct {
+	Title        string `json:"title"`
+	StartSeconds int    `json:"startSeconds"`
+	URL          string `json:"url,omitempty"`
+}
+
+// ManifestArticle is a news article that was used in an episode.
+type ManifestArticle struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Source      string `json:"source,omitempty"`
+	ImageURL    string `json:"imageUrl,omitempty"`
+	Topic       string `json:"topic,omitempty"`
+}
+
+const episodeColumns = `id, COALESCE(user_id::text, ''), episode_date, country, topic, storage_key, duration_seconds, status, created_at, manifest`
+
+// scanEpisode reads a row selected with episodeColumns.
+func scanEpisode(row interface{ Scan(...any) error }) (*Episode, error) {
+	var episode Episode
+	var manifest []byte
+	err := row.Scan(&episode.ID, &episode.UserID, &episode.Date, &episode.Country, &episode.Topic, &episode.StorageKey,
+		&episode.DurationSeconds, &episode.Status, &episode.CreatedAt, &manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(manifest, &episode.Manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest for episode '%s': %w", episode.ID, err)
+	}
+	return &episode, nil
+}
+
+// SaveEpisode inserts a shared episode, or updates the existing one for the
+// same date, country and topic.
+func (p *PGStore) SaveEpisode(ctx context.Context, episode *Episode) (*Episode, error) {
+	manifest, err := json.Marshal(episode.Manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	query := `
+		INSERT INTO episodes (episode_date, country, topic, storage_key, duration_seconds, status, manifest)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (episode_date, country, topic) WHERE user_id IS NULL DO UPDATE
+		SET storage_key = EXCLUDED.storage_key,
+			duration_seconds = EXCLUDED.duration_seconds,
+			status = EXCLUDED.status,
+			manifest = EXCLUDED.manifest,
+			updated_at = now()
+		RETURNING ` + episodeColumns
+
+	saved, err := scanEpisode(p.db.QueryRowContext(ctx, query, episode.Date, episode.Country, episode.Topic,
+		episode.StorageKey, episode.DurationSeconds, episode.Status, manifest))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save episode: %w", err)
+	}
+	return saved, nil
+}
+
+// GetEpisodeByID fetches an episode by its ID.
+func (p *PGStore) GetEpisodeByID(ctx context.Context, id string) (*Episode, error) {
+	query := `SELECT ` + episodeColumns + ` FROM episodes WHERE id = $1`
+
+	episode, err := scanEpisode(p.db.QueryRowContext(ctx, query, id))
+	if isInvalidText(err) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, fmt.Errorf("episode with ID '%s' not found: %w", id, err)
+	}
+	return episode, nil
+}
+
+// GetEpisodeByDate fetches the shared episode for a date, country and topic.
+func (p *PGStore) GetEpisodeByDate(ctx context.Context, date time.Time, country, topic string) (*Episode, error) {
+	query := `
+		SELECT ` + episodeColumns + `
+		FROM episodes
+		WHERE episode_date = $1 AND country = $2 AND topic = $3 AND user_id IS NULL`
+
+	episode, err := scanEpisode(p.db.QueryRowContext(ctx, query, date, country, topic))
+	if err != nil {
+		return nil, fmt.Errorf("episode for %s/%s on %s not found: %w", country, topic, date.Format(time.DateOnly), err)
+	}
+	return episode, nil
+}
+
+// NearestEpisodeDate returns the date of the published episode closest to date,
+// or nil if nothing has been published for the country and topic.
+func (p *PGStore) NearestEpisodeDate(ctx context.Context, date time.Time, country, topic string) (*time.Time, error) {
+	var nearest time.Time
+	query := `
+		SELECT episode_date
+		FROM episodes
+		WHERE country = $2 AND topic = $3 AND status = $4 AND user_id IS NULL
+		ORDER BY abs(episode_date - $1::date), episode_date DESC
+		LIMIT 1`
+
+	err := p.db.QueryRowContext(ctx, query, date, country, topic, EpisodeStatusPublished).Scan(&nearest)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find nearest episode: %w", err)
+	}
+	return &nearest, nil
+}
+
+// where builds the WHERE clause for published episodes matching the filter,
+// appending its parameters to args.
+func (f EpisodeFilter) where(args []any) (string, []any) {
+	args = append(args, EpisodeStatusPublished)
+	conditions := []string{fmt.Sprintf("status = $%d", len(args))}
+
+	shared := []string{"user_id IS NULL"}
+	if f.Country != "" {
+		args = append(args, f.Country)
+		shared = append(shared, fmt.Sprintf("$%d = ANY(string_to_array(country, '+'))", len(args)))
+	}
+	if len(f.Countries) > 0 {
+		args = append(args, f.Countries)
+		shared = append(shared, fmt.Sprintf("string_to_array(country, '+') && $%d", len(args)))
+	}
+	if f.Topic != "" {
+		args = append(args, f.Topic)
+		shared = append(shared, fmt.Sprintf("$%d = ANY(string_to_array(topic, '+'))", len(args)))
+	}
+	if len(f.Topics) > 0 {
+		args = append(args, f.Topics)
+		shared = append(shared, fmt.Sprintf("string_to_array(topic, '+') && $%d", len(args)))
+	}
+	matched := strings.Join(shared, " AND ")
+	if f.IncludeShared {
+		args = append(args, sharedEpisodeCountry, sharedEpisodeTopic)
+		matched = fmt.Sprintf("(%s) OR (user_id IS NULL AND country = $%d AND topic = $%d)", matched, len(args)-1, len(args))
+	}
+	if f.Owner != "" {
+		args = append(args, f.Owner)
+		matched = fmt.Sprintf("(%s) OR user_id = $%d", matched, len(args))
+	}
+	conditions = append(conditions, "("+matched+")")
+
+	if f.From != nil {
+		args = append(args, *f.From)
+		conditions = append(conditions, fmt.Sprintf("episode_date >= $%d", len(args)))
+	}
+	if f.To != nil {
+		args = append(args, *f.To)
+		conditions = append(conditions, fmt.Sprintf("episode_date <= $%d", len(args)))
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// ListEpisodes returns up to limit published episodes matching the filter,
+// newest first, starting after the given cursor.
+func (p *PGStore) ListEpisodes(ctx context.Context, filter EpisodeFilter, after *EpisodeCursor, limit int) ([]*Episode, error) {
+	where, args := filter.where(nil)
+	if after != nil {
+		args = append(args, after.Date, after.ID)
+		where += fmt.Sprintf(" AND (episode_date, id) < ($%d, $%d)", len(args)-1, len(args))
+	}
+	args = append(args, limit)
+
+	query := `
+		SELECT ` + episodeColumns + `
+		FROM episodes
+		` + where + `
+		ORDER BY episode_date DESC, id DESC
+		LIMIT $` + fmt.Sprint(len(args))
+
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list episodes: %w", err)
+	}
+	defer rows.Close()
+
+	var episodes []*Episode
+	for rows.Next() {
+		episode, err := scanEpisode(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read episode: %w", err)
+		}
+		episodes = append(episodes, episode)
+	}
+	return episodes, rows.Err()
+}
+
+// CountEpisodes returns the number of published episodes matching the filter.
+func (p *PGStore) CountEpisodes(ctx context.Context, filter EpisodeFilter) (int, error) {
+	var count int
+	where, args := filter.where(nil)
+
+	err := p.db.QueryRowContext(ctx, `SELECT count(*) FROM episodes `+where, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count episodes: %w", err)
+	}
+	return count, nil
+}
+
+// Encode returns the opaque cursor string handed to clients.
+func (c EpisodeCursor) Encode() string {
+	raw := c.Date.Format(time.DateOnly) + "|" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// parseEpisodeCursor decodes a cursor produced by EpisodeCursor.Encode.
+func parseEpisodeCursor(cursor string) (*EpisodeCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	date, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return nil, errors.New("malformed cursor")
+	}
+
+	day, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return nil, err
+	}
+	return &EpisodeCursor{Date: day, ID: id}, nil
+}
+
+// visibleTo reports whether a user may list or play the episode. Shared
+// episodes are visible to everyone.
+func (e *Episode) visibleTo(userID string) bool {
+	return e.UserID == "" || e.UserID == userID
+}
+
+// toModel converts a catalogue row into its GraphQL representation, filling in
+// a title and description for episodes whose manifest does not have them.
+func (e *Episode) toModel() *model.Episode {
+	title := e.Manifest.Title
+	if title == "" {
+		title = fmt.Sprintf("Daily News Podcast - %s", e.Date.Format("January 2, 2006"))
+	}
+
+	description := e.Manifest.Description
+	if description == "" && len(e.Manifest.Articles) > 0 {
+		headlines := make([]string, len(e.Manifest.Articles))
+		for i, article := range e.Manifest.Articles {
+			headlines[i] = article.Title
+		}
+		description = "In this episode: " + strings.Join(headlines, "; ")
+	}
+
+	summary := e.Manifest.Summary
+	if summary == "" {
+		summary = description
+	}
+
+	chapters := make([]*model.Chapter, len(e.Manifest.Chapters))
+	for i, chapter := range e.Manifest.Chapters {
+		chapters[i] = &model.Chapter{
+			Title:        chapter.Title,
+			StartSeconds: int32(chapter.StartSeconds),
+			SourceURL:    optionalString(chapter.URL),
+		}
+	}
+
+	sources := make([]*model.Source, len(e.Manifest.Articles))
+	for i, article := range e.Manifest.Articles {
+		sources[i] = &model.Source{
+			Title:  article.Title,
+			URL:    article.URL,
+			Outlet: optionalString(article.Source),
+		}
+	}
+
+	return &model.Episode{
+		ID:              e.ID,
+		Date:            e.Date.Format(time.DateOnly),
+		Country:         e.Country,
+		Topic:           e.Topic,
+		Title:           title,
+		Description:     description,
+		Summary:         summary,
+		DurationSeconds: int32(e.DurationSeconds),
+		Chapters:        chapters,
+		Sources:         sources,
+		CoverURL:        optionalString(e.Manifest.CoverURL),
+		StorageKey:      e.StorageKey,
+		TranscriptKey:   e.Manifest.TranscriptKey,
+	}
+}
+
+// optionalString maps an empty string to a null GraphQL value.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// ListUserEpisodeKeys lists the storage keys of a user's personal episodes.
+func (p *PGStore) ListUserEpisodeKeys(ctx context.Context, userID string) ([]string, error) {
+	query := `
+		SELECT storage_key FROM episodes WHERE user_id = $1 AND storage_key <> ''
+		UNION
+		SELECT manifest->>'transcriptKey' FROM episodes WHERE user_id = $1 AND manifest->>'transcriptKey' <> ''`
+
+	rows, err := p.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list episode keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan episode key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list episode keys: %w", err)
+	}
+	return keys, nil
+}
@@ -0,0 +1,72 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Error codes returned in the "code" extension of GraphQL errors.
+const (
+	CodeBadUserInput    = "BAD_USER_INPUT"
+	CodeEpisodeNotFound = "EPISODE_NOT_FOUND"
+	CodeInvalidCursor   = "INVALID_CURSOR"
+	CodeInvalidDate     = "INVALID_DATE"
+	CodeJobNotFound     = "JOB_NOT_FOUND"
+
+	CodeGenerationNotFound = "GENERATION_NOT_FOUND"
+
+	CodeUnauthenticated     = "UNAUTHENTICATED"
+	CodeForbidden           = "FORBIDDEN"
+	CodeInvalidCredentials  = "INVALID_CREDENTIALS"
+	CodeLoginCancelled      = "LOGIN_CANCELLED"
+	CodeEmailTaken          = "EMAIL_TAKEN"
+	CodeInvalidRefreshToken = "INVALID_REFRESH_TOKEN"
+	CodeInvalidToken        = "INVALID_TOKEN"
+	CodeEmailNotVerified    = "EMAIL_NOT_VERIFIED"
+	CodeUnverifiedAccount   = "UNVERIFIED_ACCOUNT"
+	CodeRateLimited         = "RATE_LIMITED"
+	CodeAccountLocked       = "ACCOUNT_LOCKED"
+
+	CodeUnavailable = "SERVICE_UNAVAILABLE"
+	CodeInternal    = "INTERNAL_SERVER_ERROR"
+)
+
+// ErrUnauthenticated is returned by resolvers that need a signed in user.
+var ErrUnauthenticated = errors.New("user not authenticated")
+
+// newError builds a GraphQL error for the current field with a machine-readable
+// code and any extra extensions the client can use.
+func newError(ctx context.Context, code, message string, extensions map[string]any) *gqlerror.Error {
+	ext := map[string]any{"code": code}
+	for k, v := range extensions {
+		ext[k] = v
+	}
+
+	return &gqlerror.Error{
+		Path:       graphql.GetPath(ctx),
+		Message:    message,
+		Extensions: ext,
+	}
+}
+
+// ErrorPresenter turns resolver errors into the errors sent to clients. GraphQL
+// errors built with newError, or by gqlgen itself, are passed through; anything
+// else is logged and replaced with a generic INTERNAL_SERVER_ERROR so database
+// and other internal details never reach the client.
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	var gqlErr *gqlerror.Error
+	if errors.As(err, &gqlErr) {
+		return graphql.DefaultErrorPresenter(ctx, gqlErr)
+	}
+
+	if errors.Is(err, ErrUnauthenticated) {
+		return newError(ctx, CodeUnauthenticated, err.Error(), nil)
+	}
+
+	log.Printf("internal error at %s: %v", graphql.GetPath(ctx), err)
+	return newError(ctx, CodeInternal, "internal server error", nil)
+}
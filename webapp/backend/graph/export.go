@@ -0,0 +1,296 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ShavaizKhan/DailyNewsPodcast/webapp-backend/graph/model"
+)
+
+// DataExportStore defines the interface for gathering a user's personal data.
+type DataExportStore interface {
+	ExportUserData(ctx context.Context, userID string) (*DataExport, error)
+}
+
+// DataExport is the archive a user can download of everything stored about
+// them. Secrets such as password and token hashes are never included.
+type DataExport struct {
+	ExportedAt  time.Time          `json:"exportedAt"`
+	Profile     ExportProfile      `json:"profile"`
+	Preferences *model.Preferences `json:"preferences"`
+	Identities  []ExportIdentity   `json:"identities"`
+	Sessions    []ExportSession    `json:"sessions"`
+	FeedLinks   []ExportFeedLink   `json:"feedLinks"`
+	APIKeys     []*model.APIKey    `json:"apiKeys"`
+
+	// Episodes are the user's personal episodes, and EpisodeJobs their
+	// requests to generate them.
+	Episodes    []*model.Episode `json:"episodes"`
+	EpisodeJobs []*EpisodeJob    `json:"episodeJobs"`
+
+	// ListeningHistory is where the user is in each episode they have played,
+	// and PlayEvents are their listening sessions.
+	ListeningHistory []*Listen    `json:"listeningHistory"`
+	PlayEvents       []*PlayEvent `json:"playEvents"`
+
+	Feedback ExportFeedback `json:"feedback"`
+}
+
+// ExportFeedback holds the user's ratings of episodes and stories.
+type ExportFeedback struct {
+	Episodes []*EpisodeRating `json:"episodes"`
+	Stories  []*StoryRating   `json:"stories"`
+}
+
+// ExportProfile holds the account details of a DataExport.
+type ExportProfile struct {
+	ID            string `json:"id"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"emailVerified"`
+	Role          string `json:"role"`
+}
+
+// ExportIdentity is an external sign in linked to the account.
+type ExportIdentity struct {
+	Provider  string    `json:"provider"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ExportSession is a login session.
+type ExportSession struct {
+	CreatedAt time.Time  `json:"createdAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// ExportFeedLink is a private podcast feed link.
+type ExportFeedLink struct {
+	CreatedAt time.Time  `json:"createdAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// ServeDataExport serves GET /me/export, the signed in user's data as a JSON
+// download. API keys need the PROFILE_READ scope.
+func (r *Resolver) ServeDataExport(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil || !allowedForAPIKey(ctx, model.APIKeyScopeProfileRead) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	export, err := r.Exports.ExportUserData(ctx, userID)
+	if err != nil {
+		log.Printf("export: %v", err)
+		http.Error(w, "export failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="dailynewspodcast-export-%s.json"`, export.ExportedAt.Format(time.DateOnly)))
+	w.Header().Set("Cache-Control", "no-store")
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(export); err != nil {
+		log.Printf("export: %v", err)
+	}
+}
+
+// ExportUserData gathers everything stored about a user.
+func (p *PGStore) ExportUserData(ctx context.Context, userID string) (*DataExport, error) {
+	user, err := p.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &DataExport{
+		ExportedAt: time.Now().UTC(),
+		Profile: ExportProfile{
+			ID:            user.ID,
+			Email:         user.Email,
+			EmailVerified: user.EmailVerified,
+			Role:          storedRole(user.Role),
+		},
+		Preferences:      user.Preferences,
+		Identities:       []ExportIdentity{},
+		Sessions:         []ExportSession{},
+		FeedLinks:        []ExportFeedLink{},
+		APIKeys:          []*model.APIKey{},
+		Episodes:         []*model.Episode{},
+		EpisodeJobs:      []*EpisodeJob{},
+		ListeningHistory: []*Listen{},
+		PlayEvents:       []*PlayEvent{},
+		Feedback: ExportFeedback{
+			Episodes: []*EpisodeRating{},
+			Stories:  []*StoryRating{},
+		},
+	}
+
+	identities, err := p.db.QueryContext(ctx, `SELECT provider, email, created_at FROM user_identities WHERE user_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export identities: %w", err)
+	}
+	defer identities.Close()
+	for identities.Next() {
+		var identity ExportIdentity
+		if err := identities.Scan(&identity.Provider, &identity.Email, &identity.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to export identities: %w", err)
+		}
+		export.Identities = append(export.Identities, identity)
+	}
+	if err := identities.Err(); err != nil {
+		return nil, fmt.Errorf("failed to export identities: %w", err)
+	}
+
+	sessions, err := p.db.QueryContext(ctx, `SELECT created_at, revoked_at FROM sessions WHERE user_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export sessions: %w", err)
+	}
+	defer sessions.Close()
+	for sessions.Next() {
+		var session ExportSession
+		var revokedAt sql.NullTime
+		if err := sessions.Scan(&session.CreatedAt, &revokedAt); err != nil {
+			return nil, fmt.Errorf("failed to export sessions: %w", err)
+		}
+		if revokedAt.Valid {
+			session.RevokedAt = &revokedAt.Time
+		}
+		export.Sessions = append(export.Sessions, session)
+	}
+	if err := sessions.Err(); err != nil {
+		return nil, fmt.Errorf("failed to export sessions: %w", err)
+	}
+
+	feeds, err := p.db.QueryContext(ctx, `SELECT created_at, revoked_at FROM feed_tokens WHERE user_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export feed links: %w", err)
+	}
+	defer feeds.Close()
+	for feeds.Next() {
+		var feed ExportFeedLink
+		var revokedAt sql.NullTime
+		if err := feeds.Scan(&feed.CreatedAt, &revokedAt); err != nil {
+			return nil, fmt.Errorf("failed to export feed links: %w", err)
+		}
+		if revokedAt.Valid {
+			feed.RevokedAt = &revokedAt.Time
+		}
+		export.FeedLinks = append(export.FeedLinks, feed)
+	}
+	if err := feeds.Err(); err != nil {
+		return nil, fmt.Errorf("failed to export feed links: %w", err)
+	}
+
+	episodes, err := p.db.QueryContext(ctx, `SELECT `+episodeColumns+` FROM episodes WHERE user_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export episodes: %w", err)
+	}
+	defer episodes.Close()
+	for episodes.Next() {
+		episode, err := scanEpisode(episodes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export episodes: %w", err)
+		}
+		export.Episodes = append(export.Episodes, episode.toModel())
+	}
+	if err := episodes.Err(); err != nil {
+		return nil, fmt.Errorf("failed to export episodes: %w", err)
+	}
+
+	jobs, err := p.db.QueryContext(ctx, `SELECT `+episodeJobColumns+` FROM episode_jobs WHERE user_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export episode jobs: %w", err)
+	}
+	defer jobs.Close()
+	for jobs.Next() {
+		job, err := scanEpisodeJob(jobs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export episode jobs: %w", err)
+		}
+		export.EpisodeJobs = append(export.EpisodeJobs, job)
+	}
+	if err := jobs.Err(); err != nil {
+		return nil, fmt.Errorf("failed to export episode jobs: %w", err)
+	}
+
+	listens, err := p.db.QueryContext(ctx, `SELECT `+listenColumns+` FROM listens WHERE user_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export listening history: %w", err)
+	}
+	defer listens.Close()
+	for listens.Next() {
+		listen, err := scanListen(listens)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export listening history: %w", err)
+		}
+		export.ListeningHistory = append(export.ListeningHistory, listen)
+	}
+	if err := listens.Err(); err != nil {
+		return nil, fmt.Errorf("failed to export listening history: %w", err)
+	}
+
+	plays, err := p.db.QueryContext(ctx, `SELECT `+playEventColumns+` FROM play_events WHERE user_id = $1 ORDER BY started_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export play events: %w", err)
+	}
+	defer plays.Close()
+	for plays.Next() {
+		var event PlayEvent
+		if err := plays.Scan(playEventDest(&event)...); err != nil {
+			return nil, fmt.Errorf("failed to export play events: %w", err)
+		}
+		export.PlayEvents = append(export.PlayEvents, &event)
+	}
+	if err := plays.Err(); err != nil {
+		return nil, fmt.Errorf("failed to export play events: %w", err)
+	}
+
+	episodeRatings, err := p.db.QueryContext(ctx, `SELECT `+episodeRatingColumns+` FROM episode_ratings WHERE user_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export episode ratings: %w", err)
+	}
+	defer episodeRatings.Close()
+	for episodeRatings.Next() {
+		rating, err := scanEpisodeRating(episodeRatings)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export episode ratings: %w", err)
+		}
+		export.Feedback.Episodes = append(export.Feedback.Episodes, rating)
+	}
+	if err := episodeRatings.Err(); err != nil {
+		return nil, fmt.Errorf("failed to export episode ratings: %w", err)
+	}
+
+	storyRatings, err := p.db.QueryContext(ctx, `SELECT `+storyRatingColumns+` FROM story_ratings WHERE user_id = $1 ORDER BY created_at, story_index`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export story ratings: %w", err)
+	}
+	defer storyRatings.Close()
+	for storyRatings.Next() {
+		rating, err := scanStoryRating(storyRatings)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export story ratings: %w", err)
+		}
+		export.Feedback.Stories = append(export.Feedback.Stories, rating)
+	}
+	if err := storyRatings.Err(); err != nil {
+		return nil, fmt.Errorf("failed to export story ratings: %w", err)
+	}
+
+	keys, err := p.ListAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		export.APIKeys = append(export.APIKeys, key.toModel())
+	}
+
+	return export, nil
+}
@@ -0,0 +1,175 @@
+package graph
+
+import (
+	"encoding/xml"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// Number of episodes listed in a private feed.
+	feedSize = 50
+
+	// Bitrate of the Lambda's MP3 output, used to estimate enclosure sizes.
+	audioBitRate = 48000
+)
+
+type rssFeed struct {
+	XMLName  xml.Name   `xml:"rss"`
+	Version  string     `xml:"version,attr"`
+	ITunesNS string     `xml:"xmlns:itunes,attr"`
+	Channel  rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title          string         `xml:"title"`
+	Link           string         `xml:"link"`
+	Description    string         `xml:"description"`
+	Language       string         `xml:"language"`
+	ITunesAuthor   string         `xml:"itunes:author"`
+	ITunesExplicit string         `xml:"itunes:explicit"`
+	ITunesCategory rssCategory    `xml:"itunes:category"`
+	ITunesBlock    string         `xml:"itunes:block"`
+	ITunesImage    *rssITunesLink `xml:"itunes:image,omitempty"`
+	Items          []rssItem      `xml:"item"`
+}
+
+type rssCategory struct {
+	Text string `xml:"text,attr"`
+}
+
+type rssITunesLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type rssItem struct {
+	Title          string         `xml:"title"`
+	Description    string         `xml:"description"`
+	GUID           rssGUID        `xml:"guid"`
+	PubDate        string         `xml:"pubDate"`
+	Enclosure      rssEnclosure   `xml:"enclosure"`
+	ITunesDuration int            `xml:"itunes:duration"`
+	ITunesSummary  string         `xml:"itunes:summary"`
+	ITunesImage    *rssITunesLink `xml:"itunes:image,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int    `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// feedURL returns the private feed URL for a feed token.
+func (r *Resolver) feedURL(token string) string {
+	return r.PublicURL + "/feed/" + token + "/podcast.xml"
+}
+
+// ServeFeed serves GET /feed/{token}/podcast.xml, the RSS feed of episodes
+// matching the token owner's country and topic preferences.
+func (r *Resolver) ServeFeed(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	token := req.PathValue("token")
+
+	userID, err := r.Feeds.GetFeedTokenUserID(ctx, hashToken(token))
+	if err != nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	user, err := r.Store.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Printf("feed: %v", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// The shared general episode is included alongside the preferred topics
+	// and the user's personal episodes.
+	filter := EpisodeFilter{
+		Countries:     countryNames(user.Preferences.Countries),
+		Topics:        topicNames(user.Preferences.Topics),
+		Owner:         userID,
+		IncludeShared: true,
+	}
+	episodes, err := r.Catalogue.ListEpisodes(ctx, filter, nil, feedSize)
+	if err != nil {
+		log.Printf("feed: %v", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	channel := rssChannel{
+		Title:          "Daily News Podcast",
+		Link:           r.PublicURL,
+		Description:    "Your daily AI-generated news podcast for " + strings.Join(topicNames(user.Preferences.Topics), ", ") + " in " + strings.ToUpper(strings.Join(countryNames(user.Preferences.Countries), ", ")) + ".",
+		Language:       "en",
+		ITunesAuthor:   "Daily News Podcast",
+		ITunesExplicit: "false",
+		ITunesCategory: rssCategory{Text: "News"},
+		ITunesBlock:    "Yes",
+	}
+	for _, episode := range episodes {
+		ep := episode.toModel()
+		item := rssItem{
+			Title:       ep.Title,
+			Description: ep.Summary,
+			GUID:        rssGUID{Value: ep.ID},
+			PubDate:     episode.CreatedAt.Format(time.RFC1123Z),
+			Enclosure: rssEnclosure{
+				URL:    r.PublicURL + "/feed/" + token + "/episodes/" + ep.ID + ".mp3",
+				Length: episode.DurationSeconds * audioBitRate / 8,
+				Type:   "audio/mpeg",
+			},
+			ITunesDuration: episode.DurationSeconds,
+			ITunesSummary:  ep.Summary,
+		}
+		if ep.CoverURL != nil {
+			item.ITunesImage = &rssITunesLink{Href: *ep.CoverURL}
+			if channel.ITunesImage == nil {
+				channel.ITunesImage = item.ITunesImage
+			}
+		}
+		channel.Items = append(channel.Items, item)
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(rssFeed{
+		Version:  "2.0",
+		ITunesNS: "http://www.itunes.com/dtds/podcast-1.0.dtd",
+		Channel:  channel,
+	}); err != nil {
+		log.Printf("feed: %v", err)
+	}
+}
+
+// ServeFeedAudio serves GET /feed/{token}/episodes/{file}. Podcast apps keep
+// enclosure URLs for a long time, so the audio is streamed through the backend
+// for as long as the feed token is valid rather than linking to storage.
+func (r *Resolver) ServeFeedAudio(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	userID, err := r.Feeds.GetFeedTokenUserID(ctx, hashToken(req.PathValue("token")))
+	if err != nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	episodeID := strings.TrimSuffix(req.PathValue("file"), ".mp3")
+	episode, err := r.Catalogue.GetEpisodeByID(ctx, episodeID)
+	if err != nil || episode.Status != EpisodeStatusPublished || !episode.visibleTo(userID) {
+		http.NotFound(w, req)
+		return
+	}
+
+	r.streamEpisode(w, req, episode)
+}
@@ -0,0 +1,317 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ShavaizKhan/DailyNewsPodcast/webapp-backend/graph/model"
+)
+
+const (
+	// Bounds of an episode rating.
+	minRatingStars = 1
+	maxRatingStars = 5
+
+	// maxRatingCommentLength is the longest comment, in characters, that can
+	// come with an episode rating.
+	maxRatingCommentLength = 2000
+
+	// Sources a user gave more thumbs-down than thumbs-up within
+	// demotedSourceWindow are ranked last in their episodes, up to
+	// maxDemotedSources of the most recently rated.
+	demotedSourceWindow = 90 * 24 * time.Hour
+	maxDemotedSources   = 20
+
+	// feedbackGroupLimit is how many groups each part of the feedback summary
+	// lists, most rated first.
+	feedbackGroupLimit = 50
+
+	// unknownFeedbackKey groups feedback whose topic, source or model wasn't
+	// recorded.
+	unknownFeedbackKey = "unknown"
+)
+
+// Values of story_ratings.thumbs.
+const (
+	ThumbsUp   = "up"
+	ThumbsDown = "down"
+)
+
+// FeedbackStore defines the interface for listeners' ratings of episodes and
+// of the stories in them.
+type FeedbackStore interface {
+	// RateEpisode records a user's star rating of an episode, replacing any
+	// earlier one.
+	RateEpisode(ctx context.Context, userID, episodeID string, stars int, comment *string) (*EpisodeRating, error)
+	// RateStory records a user's thumbs up or down for a story, replacing any
+	// earlier one. The story's source, topic and model are kept with it.
+	RateStory(ctx context.Context, rating *StoryRating) (*StoryRating, error)
+	// DemotedSources lists the domains whose stories a user has recently
+	// disliked more than liked.
+	DemotedSources(ctx context.Context, userID string) ([]string, error)
+	SummarizeFeedback(ctx context.Context, since time.Time) (*model.FeedbackSummary, error)
+}
+
+// EpisodeRating is a row in the episode_ratings table.
+type EpisodeRating struct {
+	UserID    string    `json:"-"`
+	EpisodeID string    `json:"episodeId"`
+	Stars     int       `json:"stars"`
+	Comment   *string   `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// toModel converts an episode rating to its GraphQL representation.
+func (r *EpisodeRating) toModel() *model.EpisodeRating {
+	return &model.EpisodeRating{
+		EpisodeID: r.EpisodeID,
+		Stars:     int32(r.Stars),
+		Comment:   r.Comment,
+		UpdatedAt: r.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+const episodeRatingColumns = `user_id, episode_id, stars, comment, created_at, updated_at`
+
+// scanEpisodeRating reads a row selected with episodeRatingColumns.
+func scanEpisodeRating(row interface{ Scan(...any) error }) (*EpisodeRating, error) {
+	var rating EpisodeRating
+	var comment sql.NullString
+	err := row.Scan(&rating.UserID, &rating.EpisodeID, &rating.Stars, &comment, &rating.CreatedAt, &rating.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if comment.Valid {
+		rating.Comment = &comment.String
+	}
+	return &rating, nil
+}
+
+// StoryRating is a row in the story_ratings table. StoryIndex is the
+// position of the story in the episode's sources.
+type StoryRating struct {
+	UserID     string    `json:"-"`
+	EpisodeID  string    `json:"episodeId"`
+	StoryIndex int       `json:"storyIndex"`
+	Thumbs     string    `json:"thumbs"`
+	Source     string    `json:"source,omitempty"`
+	Domain     string    `json:"domain,omitempty"`
+	Topic      string    `json:"topic,omitempty"`
+	Model      string    `json:"model,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// newStoryRating builds a user's rating of one of an episode's stories,
+// taking the story's details from the episode manifest.
+func newStoryRating(userID string, episode *Episode, storyIndex int, thumbs string) *StoryRating {
+	article := episode.Manifest.Articles[storyIndex]
+	domain, _ := normalizeSource(article.URL)
+	topic := article.Topic
+	if topic == "" {
+		topic = episode.Topic
+	}
+
+	return &StoryRating{
+		UserID:     userID,
+		EpisodeID:  episode.ID,
+		StoryIndex: storyIndex,
+		Thumbs:     thumbs,
+		Source:     article.Source,
+		Domain:     domain,
+		Topic:      topic,
+		Model:      episode.Manifest.Model,
+	}
+}
+
+// toModel converts a story rating to its GraphQL representation.
+func (r *StoryRating) toModel() *model.StoryRating {
+	return &model.StoryRating{
+		EpisodeID:  r.EpisodeID,
+		StoryIndex: int32(r.StoryIndex),
+		Thumbs:     model.Thumbs(strings.ToUpper(r.Thumbs)),
+		UpdatedAt:  r.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+const storyRatingColumns = `user_id, episode_id, story_index, thumbs, source, domain, topic, model, created_at, updated_at`
+
+// scanStoryRating reads a row selected with storyRatingColumns.
+func scanStoryRating(row interface{ Scan(...any) error }) (*StoryRating, error) {
+	var rating StoryRating
+	err := row.Scan(&rating.UserID, &rating.EpisodeID, &rating.StoryIndex, &rating.Thumbs, &rating.Source,
+		&rating.Domain, &rating.Topic, &rating.Model, &rating.CreatedAt, &rating.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rating, nil
+}
+
+// RateEpisode records a user's rating of an episode.
+func (p *PGStore) RateEpisode(ctx context.Context, userID, episodeID string, stars int, comment *string) (*EpisodeRating, error) {
+	query := `
+		INSERT INTO episode_ratings (user_id, episode_id, stars, comment)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, episode_id) DO UPDATE
+		SET stars = EXCLUDED.stars, comment = EXCLUDED.comment, updated_at = now()
+		RETURNING ` + episodeRatingColumns
+
+	rating, err := scanEpisodeRating(p.db.QueryRowContext(ctx, query, userID, episodeID, stars, comment))
+	if err != nil {
+		return nil, fmt.Errorf("failed to rate episode '%s': %w", episodeID, err)
+	}
+	return rating, nil
+}
+
+// RateStory records a user's thumbs up or down for a story.
+func (p *PGStore) RateStory(ctx context.Context, rating *StoryRating) (*StoryRating, error) {
+	query := `
+		INSERT INTO story_ratings (user_id, episode_id, story_index, thumbs, source, domain, topic, model)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id, episode_id, story_index) DO UPDATE
+		SET thumbs = EXCLUDED.thumbs, updated_at = now()
+		RETURNING ` + storyRatingColumns
+
+	saved, err := scanStoryRating(p.db.QueryRowContext(ctx, query, rating.UserID, rating.EpisodeID, rating.StoryIndex,
+		rating.Thumbs, rating.Source, rating.Domain, rating.Topic, rating.Model))
+	if err != nil {
+		return nil, fmt.Errorf("failed to rate story %d of episode '%s': %w", rating.StoryIndex, rating.EpisodeID, err)
+	}
+	return saved, nil
+}
+
+// DemotedSources lists the domains a user gave more thumbs-down than
+// thumbs-up within the demotion window, most recently rated first.
+func (p *PGStore) DemotedSources(ctx context.Context, userID string) ([]string, error) {
+	query := `
+		SELECT domain
+		FROM story_ratings
+		WHERE user_id = $1 AND domain <> '' AND updated_at > now() - make_interval(secs => $2)
+		GROUP BY domain
+		HAVING count(*) FILTER (WHERE thumbs = $3) > count(*) FILTER (WHERE thumbs = $4)
+		ORDER BY max(updated_at) DESC
+		LIMIT $5`
+
+	rows, err := p.db.QueryContext(ctx, query, userID, demotedSourceWindow.Seconds(), ThumbsDown, ThumbsUp, maxDemotedSources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list demoted sources: %w", err)
+	}
+	defer rows.Close()
+
+	var domains []string
+	for rows.Next() {
+		var domain string
+		if err := rows.Scan(&domain); err != nil {
+			return nil, fmt.Errorf("failed to list demoted sources: %w", err)
+		}
+		domains = append(domains, domain)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list demoted sources: %w", err)
+	}
+	return domains, nil
+}
+
+// Queries giving each episode rating and story rating updated since $1 the
+// key it is summarized under, as "key". Personal episodes cover several
+// topics, and their ratings count towards each.
+const (
+	episodeRatingsByTopic = `
+		SELECT covered.topic AS key, episode_ratings.stars
+		FROM episode_ratings
+		JOIN episodes ON episodes.id = episode_ratings.episode_id,
+			unnest(string_to_array(episodes.topic, '+')) AS covered (topic)
+		WHERE episode_ratings.updated_at >= $1`
+	episodeRatingsByModel = `
+		SELECT COALESCE(NULLIF(episodes.manifest->>'model', ''), '` + unknownFeedbackKey + `') AS key, episode_ratings.stars
+		FROM episode_ratings
+		JOIN episodes ON episodes.id = episode_ratings.episode_id
+		WHERE episode_ratings.updated_at >= $1`
+	storyRatingsByTopic = `
+		SELECT COALESCE(NULLIF(topic, ''), '` + unknownFeedbackKey + `') AS key, thumbs
+		FROM story_ratings
+		WHERE updated_at >= $1`
+	storyRatingsBySource = `
+		SELECT COALESCE(NULLIF(domain, ''), NULLIF(source, ''), '` + unknownFeedbackKey + `') AS key, thumbs
+		FROM story_ratings
+		WHERE updated_at >= $1`
+	storyRatingsByModel = `
+		SELECT COALESCE(NULLIF(model, ''), '` + unknownFeedbackKey + `') AS key, thumbs
+		FROM story_ratings
+		WHERE updated_at >= $1`
+)
+
+// SummarizeFeedback groups the ratings given since a time by topic, by
+// source and by model. Episode ratings don't have a source, so sources are
+// only summarized from story ratings.
+func (p *PGStore) SummarizeFeedback(ctx context.Context, since time.Time) (*model.FeedbackSummary, error) {
+	byTopic, err := p.feedbackGroups(ctx, episodeRatingsByTopic, storyRatingsByTopic, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize feedback by topic: %w", err)
+	}
+	bySource, err := p.feedbackGroups(ctx, "", storyRatingsBySource, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize feedback by source: %w", err)
+	}
+	byModel, err := p.feedbackGroups(ctx, episodeRatingsByModel, storyRatingsByModel, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize feedback by model: %w", err)
+	}
+	return &model.FeedbackSummary{ByTopic: byTopic, BySource: bySource, ByModel: byModel}, nil
+}
+
+// feedbackGroups totals the episode and story ratings selected by the given
+// queries for each key. An empty episodes query leaves out episode ratings.
+func (p *PGStore) feedbackGroups(ctx context.Context, episodes, stories string, since time.Time) ([]*model.FeedbackGroup, error) {
+	if episodes == "" {
+		episodes = `SELECT NULL::text AS key, NULL::int AS stars WHERE false`
+	}
+
+	query := `
+		SELECT COALESCE(episode.key, story.key), COALESCE(episode.ratings, 0), episode.average,
+			COALESCE(story.up, 0), COALESCE(story.down, 0)
+		FROM (
+			SELECT key, count(*) AS ratings, avg(stars)::float8 AS average
+			FROM (` + episodes + `) rated
+			GROUP BY key
+		) episode
+		FULL JOIN (
+			SELECT key, count(*) FILTER (WHERE thumbs = $2) AS up, count(*) FILTER (WHERE thumbs = $3) AS down
+			FROM (` + stories + `) rated
+			GROUP BY key
+		) story ON story.key = episode.key
+		ORDER BY COALESCE(episode.ratings, 0) + COALESCE(story.up, 0) + COALESCE(story.down, 0) DESC, 1
+		LIMIT $4`
+
+	rows, err := p.db.QueryContext(ctx, query, since, ThumbsUp, ThumbsDown, feedbackGroupLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []*model.FeedbackGroup{}
+	for rows.Next() {
+		var group model.FeedbackGroup
+		var ratings, up, down int
+		var average sql.NullFloat64
+		if err := rows.Scan(&group.Key, &ratings, &average, &up, &down); err != nil {
+			return nil, err
+		}
+		group.Ratings = int32(ratings)
+		group.ThumbsUp = int32(up)
+		group.ThumbsDown = int32(down)
+		if average.Valid {
+			group.AverageStars = &average.Float64
+		}
+		groups = append(groups, &group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
@@ -0,0 +1,55 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+)
+
+// FeedStore defines the interface for private podcast feed tokens.
+type FeedStore interface {
+	CreateFeedToken(ctx context.Context, userID, tokenHash string) error
+	GetFeedTokenUserID(ctx context.Context, tokenHash string) (string, error)
+	RevokeFeedTokens(ctx context.Context, userID string) error
+}
+
+// CreateFeedToken stores a new feed token for a user, revoking the previous one.
+func (p *PGStore) CreateFeedToken(ctx context.Context, userID, tokenHash string) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	revoke := `UPDATE feed_tokens SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`
+	if _, err := tx.ExecContext(ctx, revoke, userID); err != nil {
+		return fmt.Errorf("failed to revoke feed token: %w", err)
+	}
+
+	insert := `INSERT INTO feed_tokens (user_id, token_hash) VALUES ($1, $2)`
+	if _, err := tx.ExecContext(ctx, insert, userID, tokenHash); err != nil {
+		return fmt.Errorf("failed to create feed token: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetFeedTokenUserID returns the user an active feed token belongs to.
+func (p *PGStore) GetFeedTokenUserID(ctx context.Context, tokenHash string) (string, error) {
+	var userID string
+	query := `SELECT user_id FROM feed_tokens WHERE token_hash = $1 AND revoked_at IS NULL`
+
+	if err := p.db.QueryRowContext(ctx, query, tokenHash).Scan(&userID); err != nil {
+		return "", fmt.Errorf("feed token not found: %w", err)
+	}
+	return userID, nil
+}
+
+// RevokeFeedTokens revokes a user's active feed token.
+func (p *PGStore) RevokeFeedTokens(ctx context.Context, userID string) error {
+	query := `UPDATE feed_tokens SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`
+
+	if _, err := p.db.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("failed to revoke feed token: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ShavaizKhan/DailyNewsPodcast/webapp-backend/graph/model"
+)
+
+// Kinds of article filter, as stored in user_filters.
+const (
+	filterIncludeKeyword  = "include_keyword"
+	filterExcludeKeyword  = "exclude_keyword"
+	filterBlockedSource   = "blocked_source"
+	filterPreferredSource = "preferred_source"
+)
+
+// Limits on article filters.
+const (
+	maxFilterValues  = 20
+	minKeywordLength = 2
+	maxKeywordLength = 50
+)
+
+// emptyArticleFilters returns filters that let every article through.
+func emptyArticleFilters() *model.ArticleFilters {
+	return &model.ArticleFilters{
+		IncludeKeywords:  []string{},
+		ExcludeKeywords:  []string{},
+		BlockedSources:   []string{},
+		PreferredSources: []string{},
+	}
+}
+
+// decodeArticleFilters decodes the filters selected by userPreferencesColumns.
+func decodeArticleFilters(data []byte) (*model.ArticleFilters, error) {
+	var rows []struct {
+		Kind  string `json:"kind"`
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("failed to decode article filters: %w", err)
+	}
+
+	filters := emptyArticleFilters()
+	for _, row := range rows {
+		switch row.Kind {
+		case filterIncludeKeyword:
+			filters.IncludeKeywords = append(filters.IncludeKeywords, row.Value)
+		case filterExcludeKeyword:
+			filters.ExcludeKeywords = append(filters.ExcludeKeywords, row.Value)
+		case filterBlockedSource:
+			filters.BlockedSources = append(filters.BlockedSources, row.Value)
+		case filterPreferredSource:
+			filters.PreferredSources = append(filters.PreferredSources, row.Value)
+		}
+	}
+	return filters, nil
+}
+
+// validateArticleFilters checks and normalizes an updateArticleFilters
+// request. Keywords are lower-cased and sources reduced to bare domains, so
+// "https://www.Example.com/news" becomes "example.com".
+func validateArticleFilters(ctx context.Context, input model.ArticleFiltersInput) (*model.ArticleFilters, error) {
+	filters := &model.ArticleFilters{}
+	var err error
+
+	if filters.IncludeKeywords, err = normalizeFilterValues(ctx, "includeKeywords", input.IncludeKeywords, normalizeKeyword); err != nil {
+		return nil, err
+	}
+	if filters.ExcludeKeywords, err = normalizeFilterValues(ctx, "excludeKeywords", input.ExcludeKeywords, normalizeKeyword); err != nil {
+		return nil, err
+	}
+	if filters.BlockedSources, err = normalizeFilterValues(ctx, "blockedSources", input.BlockedSources, normalizeSource); err != nil {
+		return nil, err
+	}
+	if filters.PreferredSources, err = normalizeFilterValues(ctx, "preferredSources", input.PreferredSources, normalizeSource); err != nil {
+		return nil, err
+	}
+
+	for _, keyword := range filters.IncludeKeywords {
+		if slices.Contains(filters.ExcludeKeywords, keyword) {
+			return nil, fieldError(ctx, "excludeKeywords", fmt.Sprintf("'%s' is also an include keyword", keyword))
+		}
+	}
+	for _, source := range filters.PreferredSources {
+		if slices.Contains(filters.BlockedSources, source) {
+			return nil, fieldError(ctx, "blockedSources", fmt.Sprintf("'%s' is also a preferred source", source))
+		}
+	}
+	return filters, nil
+}
+
+// normalizeFilterValues normalizes one list of filter values, dropping
+// duplicates.
+func normalizeFilterValues(ctx context.Context, field string, values []string, normalize func(string) (string, string)) ([]string, error) {
+	if len(values) > maxFilterValues {
+		return nil, fieldError(ctx, field, fmt.Sprintf("at most %d are allowed", maxFilterValues))
+	}
+
+	normalized := []string{}
+	for _, value := range values {
+		value, problem := normalize(value)
+		if problem != "" {
+			return nil, fieldError(ctx, field, problem)
+		}
+		if !slices.Contains(normalized, value) {
+			normalized = append(normalized, value)
+		}
+	}
+	slices.Sort(normalized)
+	return normalized, nil
+}
+
+// normalizeKeyword lower-cases a keyword, returning a problem if it can't be used.
+func normalizeKeyword(keyword string) (string, string) {
+	keyword = strings.ToLower(strings.Join(strings.Fields(keyword), " "))
+	if n := utf8.RuneCountInString(keyword); n < minKeywordLength || n > maxKeywordLength {
+		return "", fmt.Sprintf("keywords must be between %d and %d characters long", minKeywordLength, maxKeywordLength)
+	}
+	return keyword, ""
+}
+
+// normalizeSource reduces a source to its domain, returning a problem if it
+// isn't one.
+func normalizeSource(source string) (string, string) {
+	source = strings.ToLower(strings.TrimSpace(source))
+	if !strings.Contains(source, "://") {
+		source = "https://" + source
+	}
+
+	u, err := url.Parse(source)
+	if err != nil || u.Hostname() == "" {
+		return "", fmt.Sprintf("'%s' is not a domain", source)
+	}
+	domain := strings.TrimPrefix(u.Hostname(), "www.")
+	if !strings.Contains(strings.Trim(domain, "."), ".") {
+		return "", fmt.Sprintf("'%s' is not a domain", domain)
+	}
+	return domain, ""
+}
+
+// UpdateUserFilters replaces a user's article filters.
+func (p *PGStore) UpdateUserFilters(ctx context.Context, id string, filters *model.ArticleFilters) (*model.ArticleFilters, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_filters WHERE user_id = $1`, id); err != nil {
+		return nil, fmt.Errorf("failed to update article filters: %w", err)
+	}
+
+	insert := `INSERT INTO user_filters (user_id, kind, value) SELECT $1, $2, unnest($3::text[])`
+	for kind, values := range map[string][]string{
+		filterIncludeKeyword:  filters.IncludeKeywords,
+		filterExcludeKeyword:  filters.ExcludeKeywords,
+		filterBlockedSource:   filters.BlockedSources,
+		filterPreferredSource: filters.PreferredSources,
+	} {
+		if len(values) == 0 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, insert, id, kind, values); err != nil {
+			return nil, fmt.Errorf("failed to update article filters: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to update article filters: %w", err)
+	}
+
+	preferences, err := p.userPreferences(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return preferences.Filters, nil
+}
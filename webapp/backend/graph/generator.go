@@ -0,0 +1,70 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// Generator starts the generation of new episodes.
+type Generator interface {
+	// Generate starts the shared episodes.
+	Generate(ctx context.Context) error
+	// GenerateJob starts a queued personal episode job.
+	GenerateJob(ctx context.Context, jobID string) error
+}
+
+// LambdaGenerator runs episode generation by invoking the podcast Lambda.
+type LambdaGenerator struct {
+	client   *lambda.Client
+	function string
+}
+
+// NewLambdaGenerator creates a LambdaGenerator for the named function.
+func NewLambdaGenerator(ctx context.Context, function string) (*LambdaGenerator, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion("us-east-1"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS config: %w", err)
+	}
+
+	return &LambdaGenerator{client: lambda.NewFromConfig(cfg), function: function}, nil
+}
+
+// Generate invokes the Lambda asynchronously; it records its progress in the
+// episode catalogue.
+func (g *LambdaGenerator) Generate(ctx context.Context) error {
+	return g.invoke(ctx, []byte("{}"))
+}
+
+// GenerateJob invokes the Lambda asynchronously for a job. The Lambda loads
+// the job's request and records its progress on the job.
+func (g *LambdaGenerator) GenerateJob(ctx context.Context, jobID string) error {
+	payload, err := jobEvent(jobID)
+	if err != nil {
+		return err
+	}
+	return g.invoke(ctx, payload)
+}
+
+// jobEvent builds the event that generates a personal episode job.
+func jobEvent(jobID string) ([]byte, error) {
+	return json.Marshal(map[string]string{"jobId": jobID})
+}
+
+// invoke sends an event to the Lambda without waiting for it to finish.
+func (g *LambdaGenerator) invoke(ctx context.Context, payload []byte) error {
+	_, err := g.client.Invoke(ctx, &lambda.InvokeInput{
+		FunctionName:   aws.String(g.function),
+		InvocationType: types.InvocationTypeEvent,
+		Payload:        payload,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to invoke '%s': %w", g.function, err)
+	}
+	return nil
+}
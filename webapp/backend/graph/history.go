@@ -0,0 +1,248 @@
+package graph
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ShavaizKhan/DailyNewsPodcast/webapp-backend/graph/model"
+)
+
+const (
+	// playSessionGap is how long playback reports can stop before the next
+	// one starts a new listening session.
+	playSessionGap = 10 * time.Minute
+
+	// maxListenedSeconds bounds the playing time a single report can add.
+	maxListenedSeconds = 3600
+
+	// Reports can't add more playing time than the clock allows since the
+	// session's last report, at up to maxPlaybackRate times normal speed,
+	// with playReportSlack added for reports that arrive late. A session's
+	// first report is held to the slack at that speed.
+	maxPlaybackRate = 2
+	playReportSlack = 30 * time.Second
+
+	// Page sizes for the listening history.
+	defaultHistoryPageSize = 20
+	maxHistoryPageSize     = 100
+
+	// favouriteTopicsLimit is how many topics myStats lists.
+	favouriteTopicsLimit = 5
+)
+
+// PlayEvent is a row in the play_events table: one listening session. The
+// episode is filled in when listing the history.
+type PlayEvent struct {
+	ID              string    `json:"id"`
+	EpisodeID       string    `json:"episodeId"`
+	ListenedSeconds int       `json:"listenedSeconds"`
+	PositionSeconds int       `json:"positionSeconds"`
+	StartedAt       time.Time `json:"startedAt"`
+	EndedAt         time.Time `json:"endedAt"`
+	Episode         *Episode  `json:"-"`
+}
+
+// toModel converts a play event to its GraphQL representation.
+func (e *PlayEvent) toModel() *model.PlayEvent {
+	return &model.PlayEvent{
+		ID:              e.ID,
+		Episode:         e.Episode.toModel(),
+		ListenedSeconds: int32(e.ListenedSeconds),
+		PositionSeconds: int32(e.PositionSeconds),
+		StartedAt:       e.StartedAt.UTC().Format(time.RFC3339),
+		EndedAt:         e.EndedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+const playEventColumns = `play_events.id, play_events.episode_id, play_events.listened_seconds, play_events.position_seconds, play_events.started_at, play_events.ended_at`
+
+// playEventDest returns the scan destinations for playEventColumns.
+func playEventDest(event *PlayEvent) []any {
+	return []any{&event.ID, &event.EpisodeID, &event.ListenedSeconds, &event.PositionSeconds, &event.StartedAt, &event.EndedAt}
+}
+
+// prefixScanner scans its destinations ahead of the ones passed to Scan, so
+// a row can be split between scanners.
+type prefixScanner struct {
+	row  interface{ Scan(...any) error }
+	dest []any
+}
+
+func (s prefixScanner) Scan(dest ...any) error {
+	return s.row.Scan(append(s.dest, dest...)...)
+}
+
+// PlayEventCursor is a position in the listening history, which is ordered
+// from the most recent session.
+type PlayEventCursor struct {
+	StartedAt time.Time
+	ID        string
+}
+
+// Encode returns the opaque cursor string handed to clients.
+func (c PlayEventCursor) Encode() string {
+	raw := c.StartedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// parsePlayEventCursor decodes a cursor produced by PlayEventCursor.Encode.
+func parsePlayEventCursor(cursor string) (*PlayEventCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	startedAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return nil, errors.New("malformed cursor")
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, startedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &PlayEventCursor{StartedAt: t, ID: id}, nil
+}
+
+// RecordPlay adds playing time to the user's current session with an
+// episode, or starts a new session if the last one has gone quiet. The time
+// is reported by the client, so it is capped by how much time has passed.
+func (p *PGStore) RecordPlay(ctx context.Context, userID, episodeID string, listenedSeconds, positionSeconds int) error {
+	query := `
+		WITH current AS (
+			UPDATE play_events
+			SET listened_seconds = listened_seconds + LEAST($3, (EXTRACT(EPOCH FROM now() - ended_at)::int + $7) * $6),
+				position_seconds = $4, ended_at = now()
+			WHERE id = (
+				SELECT id FROM play_events
+				WHERE user_id = $1 AND episode_id = $2 AND ended_at > now() - make_interval(secs => $5)
+				ORDER BY ended_at DESC
+				LIMIT 1
+			)
+			RETURNING id
+		)
+		INSERT INTO play_events (user_id, episode_id, listened_seconds, position_seconds)
+		SELECT $1, $2, LEAST($3, $7::int * $6::int), $4
+		WHERE NOT EXISTS (SELECT 1 FROM current)`
+
+	_, err := p.db.ExecContext(ctx, query, userID, episodeID, listenedSeconds, positionSeconds, playSessionGap.Seconds(),
+		maxPlaybackRate, int(playReportSlack.Seconds()))
+	if err != nil {
+		return fmt.Errorf("failed to record play: %w", err)
+	}
+	return nil
+}
+
+// ListPlayEvents returns up to limit of a user's listening sessions with
+// their episodes, most recent first, starting after the given cursor.
+func (p *PGStore) ListPlayEvents(ctx context.Context, userID string, after *PlayEventCursor, limit int) ([]*PlayEvent, error) {
+	args := []any{userID}
+	where := "WHERE play_events.user_id = $1"
+	if after != nil {
+		args = append(args, after.StartedAt, after.ID)
+		where += " AND (play_events.started_at, play_events.id) < ($2, $3)"
+	}
+	args = append(args, limit)
+
+	query := `
+		SELECT ` + playEventColumns + `, episode.*
+		FROM play_events
+		JOIN LATERAL (
+			SELECT ` + episodeColumns + ` FROM episodes WHERE episodes.id = play_events.episode_id
+		) episode ON true
+		` + where + `
+		ORDER BY play_events.started_at DESC, play_events.id DESC
+		LIMIT $` + fmt.Sprint(len(args))
+
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list listening history: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*PlayEvent
+	for rows.Next() {
+		var event PlayEvent
+		event.Episode, err = scanEpisode(prefixScanner{row: rows, dest: playEventDest(&event)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read play event: %w", err)
+		}
+		events = append(events, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list listening history: %w", err)
+	}
+	return events, nil
+}
+
+// GetListeningStats aggregates a user's play events and listens. Streaks
+// count days in the user's timezone.
+func (p *PGStore) GetListeningStats(ctx context.Context, userID string) (*model.ListeningStats, error) {
+	query := `
+		WITH days AS (
+			SELECT DISTINCT (play_events.started_at AT TIME ZONE users.timezone)::date AS day
+			FROM play_events
+			JOIN users ON users.id = play_events.user_id
+			WHERE play_events.user_id = $1 AND play_events.listened_seconds > 0
+		), islands AS (
+			SELECT day, day - (row_number() OVER (ORDER BY day))::int AS island
+			FROM days
+		), latest AS (
+			SELECT island, day FROM islands ORDER BY day DESC LIMIT 1
+		)
+		SELECT
+			COALESCE((SELECT sum(listened_seconds) FROM play_events WHERE user_id = $1), 0) / 60,
+			COALESCE((SELECT avg((completed_at IS NOT NULL)::int) FROM listens WHERE user_id = $1), 0)::float8,
+			(
+				SELECT count(*)
+				FROM islands, latest, users
+				WHERE islands.island = latest.island AND users.id = $1
+					AND latest.day >= (now() AT TIME ZONE users.timezone)::date - 1
+			)`
+
+	var stats model.ListeningStats
+	var totalMinutes, streakDays int
+	if err := p.db.QueryRowContext(ctx, query, userID).Scan(&totalMinutes, &stats.CompletionRate, &streakDays); err != nil {
+		return nil, fmt.Errorf("failed to compute listening stats: %w", err)
+	}
+	stats.TotalMinutes = int32(totalMinutes)
+	stats.StreakDays = int32(streakDays)
+
+	// Personal episodes cover several topics, each of which is credited with
+	// the session's full listening time.
+	topics := `
+		SELECT covered.topic, sum(play_events.listened_seconds) / 60
+		FROM play_events
+		JOIN episodes ON episodes.id = play_events.episode_id,
+			unnest(string_to_array(episodes.topic, '+')) AS covered (topic)
+		WHERE play_events.user_id = $1
+		GROUP BY covered.topic
+		HAVING sum(play_events.listened_seconds) >= 60
+		ORDER BY sum(play_events.listened_seconds) DESC, covered.topic
+		LIMIT $2`
+
+	rows, err := p.db.QueryContext(ctx, topics, userID, favouriteTopicsLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute favourite topics: %w", err)
+	}
+	defer rows.Close()
+
+	stats.FavouriteTopics = []*model.TopicListening{}
+	for rows.Next() {
+		var topic model.TopicListening
+		var minutes int
+		if err := rows.Scan(&topic.Topic, &minutes); err != nil {
+			return nil, fmt.Errorf("failed to compute favourite topics: %w", err)
+		}
+		topic.Minutes = int32(minutes)
+		stats.FavouriteTopics = append(stats.FavouriteTopics, &topic)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to compute favourite topics: %w", err)
+	}
+	return &stats, nil
+}
@@ -0,0 +1,248 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ShavaizKhan/DailyNewsPodcast/webapp-backend/graph/model"
+)
+
+// Episode job statuses. A job moves through them in order, or to failed.
+const (
+	JobStatusQueued       = "queued"
+	JobStatusFetching     = "fetching"
+	JobStatusScripting    = "scripting"
+	JobStatusSynthesizing = "synthesizing"
+	JobStatusPublished    = "published"
+	JobStatusFailed       = "failed"
+)
+
+// staleJobTimeout is how long a job can go without an update before it is
+// taken to have been abandoned, for example by a generator that crashed or
+// timed out. It is well past the longest wait between queue retries.
+const staleJobTimeout = time.Hour
+
+// EpisodeJobStore defines the interface for personal episode generation jobs.
+type EpisodeJobStore interface {
+	// CreateEpisodeJob queues a job unless the user already has one in
+	// progress, in which case that job is returned and created is false.
+	CreateEpisodeJob(ctx context.Context, userID string, request *GenerationRequest) (job *EpisodeJob, created bool, err error)
+	GetEpisodeJob(ctx context.Context, userID, id string) (*EpisodeJob, error)
+	FailEpisodeJob(ctx context.Context, id, message string) error
+	// ListEpisodeJobsUpdatedSince returns the jobs changed after a time,
+	// oldest change first.
+	ListEpisodeJobsUpdatedSince(ctx context.Context, since time.Time) ([]*EpisodeJob, error)
+}
+
+// GenerationRequest is what a personal episode is generated from. It is
+// stored with the job and shares its shape with the Lambda's event. Date is
+// the episode's date in the user's timezone.
+type GenerationRequest struct {
+	Topics    []*model.TopicWeight   `json:"topics"`
+	Countries []*model.CountryWeight `json:"countries"`
+	Language  string                 `json:"language"`
+	Filters   *GenerationFilters     `json:"filters,omitempty"`
+	Date      string                 `json:"date,omitempty"`
+}
+
+// GenerationFilters are the user's article filters together with the
+// sources their story ratings demote.
+type GenerationFilters struct {
+	*model.ArticleFilters
+	DemotedSources []string `json:"demotedSources,omitempty"`
+}
+
+// newGenerationRequest builds the request for a user's preferences, the
+// sources they demoted and the date the episode is for.
+func newGenerationRequest(preferences *model.Preferences, demoted []string, date time.Time) *GenerationRequest {
+	var filters *GenerationFilters
+	if preferences.Filters != nil || len(demoted) > 0 {
+		filters = &GenerationFilters{ArticleFilters: preferences.Filters, DemotedSources: demoted}
+	}
+
+	return &GenerationRequest{
+		Topics:    preferences.Topics,
+		Countries: preferences.Countries,
+		Language:  preferences.Language,
+		Filters:   filters,
+		Date:      date.Format(time.DateOnly),
+	}
+}
+
+// EpisodeJob is a row in the episode_jobs table. It is also the message
+// progress brokers deliver to subscriptions.
+type EpisodeJob struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	Status    string    `json:"status"`
+	Progress  int32     `json:"progress"`
+	EpisodeID *string   `json:"episodeId,omitempty"`
+	Error     *string   `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// finished reports whether the job has stopped changing.
+func (j *EpisodeJob) finished() bool {
+	return j.Status == JobStatusPublished || j.Status == JobStatusFailed
+}
+
+// toModel converts a job to its GraphQL representation. The episode is
+// filled in by the resolver.
+func (j *EpisodeJob) toModel() *model.EpisodeJob {
+	return &model.EpisodeJob{
+		ID:        j.ID,
+		Status:    model.EpisodeJobStatus(strings.ToUpper(j.Status)),
+		Progress:  j.Progress,
+		Error:     j.Error,
+		CreatedAt: j.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt: j.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// episodeJobModel converts a job to its GraphQL representation, loading the
+// episode of a published job.
+func (r *Resolver) episodeJobModel(ctx context.Context, job *EpisodeJob) (*model.EpisodeJob, error) {
+	result := job.toModel()
+	if job.EpisodeID != nil {
+		episode, err := r.Catalogue.GetEpisodeByID(ctx, *job.EpisodeID)
+		if err != nil {
+			return nil, err
+		}
+		result.Episode = episode.toModel()
+	}
+	return result, nil
+}
+
+const episodeJobColumns = `id, user_id, status, progress, episode_id, error, created_at, updated_at`
+
+// scanEpisodeJob reads a row selected with episodeJobColumns.
+func scanEpisodeJob(row interface{ Scan(...any) error }) (*EpisodeJob, error) {
+	var job EpisodeJob
+	var episodeID, message sql.NullString
+	err := row.Scan(&job.ID, &job.UserID, &job.Status, &job.Progress, &episodeID, &message, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if episodeID.Valid {
+		job.EpisodeID = &episodeID.String
+	}
+	if message.Valid {
+		job.Error = &message.String
+	}
+	return &job, nil
+}
+
+// CreateEpisodeJob queues a job unless the user already has one in progress.
+// A job in progress that hasn't been updated within staleJobTimeout is
+// failed first, so an abandoned job doesn't stop the user generating again.
+func (p *PGStore) CreateEpisodeJob(ctx context.Context, userID string, request *GenerationRequest) (*EpisodeJob, bool, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode generation request: %w", err)
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stale := `
+		UPDATE episode_jobs
+		SET status = $1, error = $2, updated_at = now()
+		WHERE user_id = $3 AND status NOT IN ('published', 'failed')
+			AND updated_at < now() - make_interval(secs => $4)`
+
+	if _, err := tx.ExecContext(ctx, stale, JobStatusFailed, "generation timed out", userID, staleJobTimeout.Seconds()); err != nil {
+		return nil, false, fmt.Errorf("failed to expire stale episode job: %w", err)
+	}
+
+	insert := `
+		INSERT INTO episode_jobs (user_id, request) VALUES ($1, $2)
+		ON CONFLICT (user_id) WHERE status NOT IN ('published', 'failed') DO NOTHING
+		RETURNING ` + episodeJobColumns
+
+	created := true
+	job, err := scanEpisodeJob(tx.QueryRowContext(ctx, insert, userID, data))
+	if errors.Is(err, sql.ErrNoRows) {
+		active := `
+			SELECT ` + episodeJobColumns + `
+			FROM episode_jobs
+			WHERE user_id = $1 AND status NOT IN ('published', 'failed')`
+
+		created = false
+		job, err = scanEpisodeJob(tx.QueryRowContext(ctx, active, userID))
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to find active episode job: %w", err)
+		}
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create episode job: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to create episode job: %w", err)
+	}
+	return job, created, nil
+}
+
+// GetEpisodeJob fetches one of a user's jobs.
+func (p *PGStore) GetEpisodeJob(ctx context.Context, userID, id string) (*EpisodeJob, error) {
+	query := `SELECT ` + episodeJobColumns + ` FROM episode_jobs WHERE id = $1 AND user_id = $2`
+
+	job, err := scanEpisodeJob(p.db.QueryRowContext(ctx, query, id, userID))
+	if isInvalidText(err) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, fmt.Errorf("episode job '%s' not found: %w", id, err)
+	}
+	return job, nil
+}
+
+// FailEpisodeJob marks a job as failed with a message for the user.
+func (p *PGStore) FailEpisodeJob(ctx context.Context, id, message string) error {
+	query := `UPDATE episode_jobs SET status = $1, error = $2, updated_at = now() WHERE id = $3`
+
+	if _, err := p.db.ExecContext(ctx, query, JobStatusFailed, message, id); err != nil {
+		return fmt.Errorf("failed to mark episode job as failed: %w", err)
+	}
+	return nil
+}
+
+// ListEpisodeJobsUpdatedSince returns the jobs changed after a time, oldest
+// change first.
+func (p *PGStore) ListEpisodeJobsUpdatedSince(ctx context.Context, since time.Time) ([]*EpisodeJob, error) {
+	query := `
+		SELECT ` + episodeJobColumns + `
+		FROM episode_jobs
+		WHERE updated_at > $1
+		ORDER BY updated_at
+		LIMIT 500`
+
+	rows, err := p.db.QueryContext(ctx, query, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list updated episode jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*EpisodeJob
+	for rows.Next() {
+		job, err := scanEpisodeJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan episode job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list updated episode jobs: %w", err)
+	}
+	return jobs, nil
+}
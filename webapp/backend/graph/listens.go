@@ -0,0 +1,255 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/ShavaizKhan/DailyNewsPodcast/webapp-backend/graph/model"
+)
+
+const (
+	// listenCompletionThreshold is the share of an episode a user has to play
+	// for it to count as completed, leaving out the outro.
+	listenCompletionThreshold = 0.9
+
+	// Page size of the continueListening query.
+	defaultContinueListeningSize = 10
+	maxContinueListeningSize     = 50
+
+	// listenBatchWait is how long the listen loader waits for other episodes
+	// to ask for their playback positions before fetching them together.
+	listenBatchWait = 2 * time.Millisecond
+
+	listenLoaderCtxKey contextKey = "listen_loader"
+)
+
+// ListenStore defines the interface for where users are in the episodes
+// they play.
+type ListenStore interface {
+	// UpdatePlaybackPosition records a user's position in an episode, marking
+	// it completed once they pass the completion threshold.
+	UpdatePlaybackPosition(ctx context.Context, userID, episodeID string, seconds int) (*Listen, error)
+	GetListen(ctx context.Context, userID, episodeID string) (*Listen, error)
+	// GetListens fetches a user's positions in several episodes, keyed by
+	// episode ID. Episodes the user hasn't played are left out.
+	GetListens(ctx context.Context, userID string, episodeIDs []string) (map[string]*Listen, error)
+	// ListInProgressEpisodes lists the published episodes a user has started
+	// and not completed, most recently played first.
+	ListInProgressEpisodes(ctx context.Context, userID string, limit int) ([]*Episode, error)
+	// RecordPlay adds seconds of playing time to the user's listening
+	// session with an episode.
+	RecordPlay(ctx context.Context, userID, episodeID string, listenedSeconds, positionSeconds int) error
+	ListPlayEvents(ctx context.Context, userID string, after *PlayEventCursor, limit int) ([]*PlayEvent, error)
+	GetListeningStats(ctx context.Context, userID string) (*model.ListeningStats, error)
+}
+
+// Listen is a row in the listens table.
+type Listen struct {
+	UserID          string     `json:"-"`
+	EpisodeID       string     `json:"episodeId"`
+	PositionSeconds int        `json:"positionSeconds"`
+	CompletedAt     *time.Time `json:"completedAt,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+}
+
+// toModel converts a listen to its GraphQL representation.
+func (l *Listen) toModel() *model.PlaybackPosition {
+	return &model.PlaybackPosition{
+		Seconds:   int32(l.PositionSeconds),
+		Completed: l.CompletedAt != nil,
+		UpdatedAt: l.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+const listenColumns = `user_id, episode_id, position_seconds, completed_at, created_at, updated_at`
+
+// scanListen reads a row selected with listenColumns.
+func scanListen(row interface{ Scan(...any) error }) (*Listen, error) {
+	var listen Listen
+	var completedAt sql.NullTime
+	err := row.Scan(&listen.UserID, &listen.EpisodeID, &listen.PositionSeconds, &completedAt, &listen.CreatedAt, &listen.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if completedAt.Valid {
+		listen.CompletedAt = &completedAt.Time
+	}
+	return &listen, nil
+}
+
+// UpdatePlaybackPosition records a user's position in an episode. Positions
+// past the end of the episode are cut down to its duration.
+func (p *PGStore) UpdatePlaybackPosition(ctx context.Context, userID, episodeID string, seconds int) (*Listen, error) {
+	query := `
+		INSERT INTO listens (user_id, episode_id, position_seconds, completed_at)
+		SELECT $1, id, LEAST($3, NULLIF(duration_seconds, 0)),
+			CASE WHEN duration_seconds > 0 AND $3 >= duration_seconds * $4::float8 THEN now() END
+		FROM episodes
+		WHERE id = $2
+		ON CONFLICT (user_id, episode_id) DO UPDATE
+		SET position_seconds = EXCLUDED.position_seconds,
+			completed_at = COALESCE(listens.completed_at, EXCLUDED.completed_at),
+			updated_at = now()
+		RETURNING ` + listenColumns
+
+	listen, err := scanListen(p.db.QueryRowContext(ctx, query, userID, episodeID, seconds, listenCompletionThreshold))
+	if isInvalidText(err) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update playback position of episode '%s': %w", episodeID, err)
+	}
+	return listen, nil
+}
+
+// GetListen fetches a user's position in an episode.
+func (p *PGStore) GetListen(ctx context.Context, userID, episodeID string) (*Listen, error) {
+	query := `SELECT ` + listenColumns + ` FROM listens WHERE user_id = $1 AND episode_id = $2`
+
+	listen, err := scanListen(p.db.QueryRowContext(ctx, query, userID, episodeID))
+	if isInvalidText(err) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no playback position for episode '%s': %w", episodeID, err)
+	}
+	return listen, nil
+}
+
+// GetListens fetches a user's positions in several episodes.
+func (p *PGStore) GetListens(ctx context.Context, userID string, episodeIDs []string) (map[string]*Listen, error) {
+	query := `SELECT ` + listenColumns + ` FROM listens WHERE user_id = $1 AND episode_id = ANY($2)`
+
+	rows, err := p.db.QueryContext(ctx, query, userID, episodeIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get playback positions: %w", err)
+	}
+	defer rows.Close()
+
+	listens := make(map[string]*Listen, len(episodeIDs))
+	for rows.Next() {
+		listen, err := scanListen(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan playback position: %w", err)
+		}
+		listens[listen.EpisodeID] = listen
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get playback positions: %w", err)
+	}
+	return listens, nil
+}
+
+// ListInProgressEpisodes lists the episodes a user has started and not
+// completed, most recently played first.
+func (p *PGStore) ListInProgressEpisodes(ctx context.Context, userID string, limit int) ([]*Episode, error) {
+	query := `
+		SELECT ` + episodeColumns + `
+		FROM episodes
+		JOIN (
+			SELECT episode_id, updated_at AS listened_at
+			FROM listens
+			WHERE user_id = $1 AND completed_at IS NULL AND position_seconds > 0
+		) started ON started.episode_id = episodes.id
+		WHERE status = $2 AND (user_id IS NULL OR user_id = $1)
+		ORDER BY started.listened_at DESC
+		LIMIT $3`
+
+	rows, err := p.db.QueryContext(ctx, query, userID, EpisodeStatusPublished, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list episodes in progress: %w", err)
+	}
+	defer rows.Close()
+
+	var episodes []*Episode
+	for rows.Next() {
+		episode, err := scanEpisode(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan episode: %w", err)
+		}
+		episodes = append(episodes, episode)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list episodes in progress: %w", err)
+	}
+	return episodes, nil
+}
+
+// listenLoader batches the playback positions the episodes in one operation
+// ask for, so a list of episodes takes a single query instead of one each.
+type listenLoader struct {
+	store  ListenStore
+	userID string
+
+	mu    sync.Mutex
+	batch *listenBatch
+}
+
+// listenBatch is a set of episodes whose positions are fetched together.
+type listenBatch struct {
+	episodeIDs []string
+	done       chan struct{}
+	listens    map[string]*Listen
+	err        error
+}
+
+// load returns the user's position in an episode once the batch it joins
+// has been fetched, or sql.ErrNoRows if they haven't played it.
+func (l *listenLoader) load(ctx context.Context, episodeID string) (*Listen, error) {
+	l.mu.Lock()
+	batch := l.batch
+	if batch == nil {
+		batch = &listenBatch{done: make(chan struct{})}
+		l.batch = batch
+		go l.fetch(ctx, batch)
+	}
+	batch.episodeIDs = append(batch.episodeIDs, episodeID)
+	l.mu.Unlock()
+
+	<-batch.done
+	if batch.err != nil {
+		return nil, batch.err
+	}
+	listen, ok := batch.listens[episodeID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return listen, nil
+}
+
+// fetch closes the batch to new episodes after listenBatchWait and looks
+// up their positions.
+func (l *listenLoader) fetch(ctx context.Context, batch *listenBatch) {
+	time.Sleep(listenBatchWait)
+
+	l.mu.Lock()
+	l.batch = nil
+	l.mu.Unlock()
+
+	batch.listens, batch.err = l.store.GetListens(ctx, l.userID, batch.episodeIDs)
+	close(batch.done)
+}
+
+// LoadListens is an operation middleware that gives each operation of a
+// signed in user a loader for their playback positions.
+func (r *Resolver) LoadListens(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	if userID, err := GetUserIDFromContext(ctx); err == nil {
+		ctx = context.WithValue(ctx, listenLoaderCtxKey, &listenLoader{store: r.Listens, userID: userID})
+	}
+	return next(ctx)
+}
+
+// getListen fetches a user's position in an episode through the operation's
+// listen loader, when it has one.
+func (r *Resolver) getListen(ctx context.Context, userID, episodeID string) (*Listen, error) {
+	if loader, ok := ctx.Value(listenLoaderCtxKey).(*listenLoader); ok && loader.userID == userID {
+		return loader.load(ctx, episodeID)
+	}
+	return r.Listens.GetListen(ctx, userID, episodeID)
+}
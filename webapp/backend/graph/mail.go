@@ -0,0 +1,100 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends transactional email such as password reset links.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPMailer sends email through an SMTP server.
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer creates an SMTPMailer. Authentication is skipped when no
+// username is given.
+func NewSMTPMailer(host, port, username, password, from string) (*SMTPMailer, error) {
+	if host == "" || from == "" {
+		return nil, fmt.Errorf("SMTP_HOST and MAIL_FROM environment variables must be set")
+	}
+	if port == "" {
+		port = "587"
+	}
+
+	mailer := &SMTPMailer{addr: host + ":" + port, from: from}
+	if username != "" {
+		mailer.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return mailer, nil
+}
+
+// Send delivers msg over SMTP.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, formatMessage(m.from, msg)); err != nil {
+		return fmt.Errorf("failed to send email to '%s': %w", msg.To, err)
+	}
+	return nil
+}
+
+// LogMailer is a Mailer for local runs. It writes each message to a file in
+// dir, or to the server log when dir is empty.
+type LogMailer struct {
+	dir string
+}
+
+// NewLogMailer creates a LogMailer writing to dir.
+func NewLogMailer(dir string) (*LogMailer, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("unable to create mail directory: %w", err)
+		}
+	}
+	return &LogMailer{dir: dir}, nil
+}
+
+// Send writes msg to the mail directory or the log.
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	data := formatMessage("noreply@localhost", msg)
+	if m.dir == "" {
+		log.Printf("email:\n%s", data)
+		return nil
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), strings.ReplaceAll(msg.To, "@", "_at_"))
+	if err := os.WriteFile(filepath.Join(m.dir, name), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	return nil
+}
+
+// formatMessage renders msg as an RFC 5322 message.
+func formatMessage(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,20 @@
+package model
+
+// Episode is a published podcast episode. The audio and transcript URLs are
+// resolved on demand from StorageKey and TranscriptKey, which are not exposed
+// in the schema.
+type Episode struct {
+	ID              string     `json:"id"`
+	Date            string     `json:"date"`
+	Country         string     `json:"country"`
+	Topic           string     `json:"topic"`
+	Title           string     `json:"title"`
+	Description     string     `json:"description"`
+	Summary         string     `json:"summary"`
+	DurationSeconds int32      `json:"durationSeconds"`
+	Chapters        []*Chapter `json:"chapters"`
+	Sources         []*Source  `json:"sources"`
+	CoverURL        *string    `json:"coverUrl,omitempty"`
+	StorageKey      string     `json:"-"`
+	TranscriptKey   string     `json:"-"`
+}
@@ -2,25 +2,573 @@
 
 package model
 
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type APIKey struct {
+	ID         string        `json:"id"`
+	Name       string        `json:"name"`
+	Prefix     string        `json:"prefix"`
+	Scopes     []APIKeyScope `json:"scopes"`
+	CreatedAt  string        `json:"createdAt"`
+	LastUsedAt *string       `json:"lastUsedAt,omitempty"`
+}
+
+// Rules for which articles go into a user's episodes. Keywords are matched
+// case-insensitively against article titles and descriptions, and sources are
+// matched by domain, including subdomains.
+type ArticleFilters struct {
+	// When not empty, articles must mention at least one of these.
+	IncludeKeywords []string `json:"includeKeywords"`
+	// Articles mentioning any of these are left out.
+	ExcludeKeywords []string `json:"excludeKeywords"`
+	// Articles from these domains are never used.
+	BlockedSources []string `json:"blockedSources"`
+	// Articles from these domains are used before others.
+	PreferredSources []string `json:"preferredSources"`
+}
+
+type ArticleFiltersInput struct {
+	IncludeKeywords  []string `json:"includeKeywords"`
+	ExcludeKeywords  []string `json:"excludeKeywords"`
+	BlockedSources   []string `json:"blockedSources"`
+	PreferredSources []string `json:"preferredSources"`
+}
+
+type AuthPayload struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresAt    string `json:"expiresAt"`
+}
+
+type Chapter struct {
+	Title        string  `json:"title"`
+	StartSeconds int32   `json:"startSeconds"`
+	SourceURL    *string `json:"sourceUrl,omitempty"`
+}
+
+type CountryWeight struct {
+	Country string `json:"country"`
+	Weight  int32  `json:"weight"`
+}
+
+type CountryWeightInput struct {
+	Country string `json:"country"`
+	Weight  int32  `json:"weight"`
+}
+
+type CreatedAPIKey struct {
+	APIKey *APIKey `json:"apiKey"`
+	// The full key. It is only returned once, when the key is created.
+	Key string `json:"key"`
+}
+
+type EpisodeConnection struct {
+	Edges      []*EpisodeEdge `json:"edges"`
+	PageInfo   *PageInfo      `json:"pageInfo"`
+	TotalCount int32          `json:"totalCount"`
+}
+
+type EpisodeEdge struct {
+	Cursor string   `json:"cursor"`
+	Node   *Episode `json:"node"`
+}
+
+// The generation of a personal episode for the signed in user.
+type EpisodeJob struct {
+	ID     string           `json:"id"`
+	Status EpisodeJobStatus `json:"status"`
+	// How far along the job is, from 0 to 100.
+	Progress int32 `json:"progress"`
+	// The finished episode, once the job is published.
+	Episode *Episode `json:"episode,omitempty"`
+	// Why the job failed.
+	Error     *string `json:"error,omitempty"`
+	CreatedAt string  `json:"createdAt"`
+	UpdatedAt string  `json:"updatedAt"`
+}
+
+// The signed in user's rating of an episode.
+type EpisodeRating struct {
+	EpisodeID string `json:"episodeId"`
+	// From 1 to 5.
+	Stars     int32   `json:"stars"`
+	Comment   *string `json:"comment,omitempty"`
+	UpdatedAt string  `json:"updatedAt"`
+}
+
+// Ratings sharing a topic, source or model.
+type FeedbackGroup struct {
+	Key string `json:"key"`
+	// Number of episode ratings.
+	Ratings int32 `json:"ratings"`
+	// Average stars of the episode ratings. Null when there are none.
+	AverageStars *float64 `json:"averageStars,omitempty"`
+	ThumbsUp     int32    `json:"thumbsUp"`
+	ThumbsDown   int32    `json:"thumbsDown"`
+}
+
+// Listener feedback grouped three ways, most rated first. Sources are outlet
+// domains and only have story ratings.
+type FeedbackSummary struct {
+	ByTopic  []*FeedbackGroup `json:"byTopic"`
+	BySource []*FeedbackGroup `json:"bySource"`
+	ByModel  []*FeedbackGroup `json:"byModel"`
+}
+
+type ListeningStats struct {
+	TotalMinutes int32 `json:"totalMinutes"`
+	// Consecutive days, in the user's timezone, with some listening, up to today or yesterday.
+	StreakDays int32 `json:"streakDays"`
+	// Share of started episodes played to the end, from 0 to 1.
+	CompletionRate float64 `json:"completionRate"`
+	// Topics listened to the most, by time.
+	FavouriteTopics []*TopicListening `json:"favouriteTopics"`
+}
+
 type Mutation struct {
 }
 
+type OIDCProvider struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	LoginURL string `json:"loginUrl"`
+}
+
+// A value accepted by updatePreferences, with a label for display.
+type Option struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+}
+
+type PageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	EndCursor   *string `json:"endCursor,omitempty"`
+}
+
+// A listening session: one stretch of playing an episode.
+type PlayEvent struct {
+	ID      string   `json:"id"`
+	Episode *Episode `json:"episode"`
+	// Seconds of audio played during the session.
+	ListenedSeconds int32 `json:"listenedSeconds"`
+	// Where in the episode the session ended.
+	PositionSeconds int32  `json:"positionSeconds"`
+	StartedAt       string `json:"startedAt"`
+	EndedAt         string `json:"endedAt"`
+}
+
+type PlayEventConnection struct {
+	Edges    []*PlayEventEdge `json:"edges"`
+	PageInfo *PageInfo        `json:"pageInfo"`
+}
+
+type PlayEventEdge struct {
+	Cursor string     `json:"cursor"`
+	Node   *PlayEvent `json:"node"`
+}
+
+type PlaybackPosition struct {
+	Seconds int32 `json:"seconds"`
+	// Whether the user has played the episode to the end, or nearly.
+	Completed bool   `json:"completed"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
 type Podcast struct {
-	Date string `json:"date"`
-	URL  string `json:"url"`
+	Date    string   `json:"date"`
+	URL     string   `json:"url"`
+	Episode *Episode `json:"episode"`
 }
 
+// What a user wants to hear about. Weights are relative: a topic with weight 2
+// gets about twice as many stories as one with weight 1.
 type Preferences struct {
+	Topics    []*TopicWeight   `json:"topics"`
+	Countries []*CountryWeight `json:"countries"`
+	// Language episodes are written and read in.
+	Language string          `json:"language"`
+	Filters  *ArticleFilters `json:"filters"`
+	// IANA timezone the delivery time and the default podcast date are in.
+	Timezone string `json:"timezone"`
+	// Local time, as HH:MM, by which a personal episode is delivered each day. Null when none is scheduled.
+	DeliveryTime *string `json:"deliveryTime,omitempty"`
+	// The most heavily weighted country.
 	Country string `json:"country"`
-	Topic   string `json:"topic"`
+	// The most heavily weighted topic.
+	Topic string `json:"topic"`
 }
 
 type Query struct {
 }
 
+// A request in the generation queue worked through by the worker process.
+type QueuedGeneration struct {
+	ID     string           `json:"id"`
+	Status GenerationStatus `json:"status"`
+	// The event the episode is generated from, as JSON.
+	Payload     string `json:"payload"`
+	Attempts    int32  `json:"attempts"`
+	MaxAttempts int32  `json:"maxAttempts"`
+	// When the request is next due to run.
+	RunAt string `json:"runAt"`
+	// The worker running the request.
+	LockedBy  *string `json:"lockedBy,omitempty"`
+	LastError *string `json:"lastError,omitempty"`
+	CreatedAt string  `json:"createdAt"`
+	UpdatedAt string  `json:"updatedAt"`
+}
+
+type Source struct {
+	Title  string  `json:"title"`
+	URL    string  `json:"url"`
+	Outlet *string `json:"outlet,omitempty"`
+}
+
+// The signed in user's thumbs up or down for a story in an episode.
+type StoryRating struct {
+	EpisodeID string `json:"episodeId"`
+	// The position of the story in the episode's sources.
+	StoryIndex int32  `json:"storyIndex"`
+	Thumbs     Thumbs `json:"thumbs"`
+	UpdatedAt  string `json:"updatedAt"`
+}
+
+type Subscription struct {
+}
+
+type SupportedOptions struct {
+	Countries []*Option `json:"countries"`
+	Topics    []*Option `json:"topics"`
+	Languages []*Option `json:"languages"`
+}
+
+type TopicListening struct {
+	Topic   string `json:"topic"`
+	Minutes int32  `json:"minutes"`
+}
+
+type TopicWeight struct {
+	Topic  string `json:"topic"`
+	Weight int32  `json:"weight"`
+}
+
+type TopicWeightInput struct {
+	Topic  string `json:"topic"`
+	Weight int32  `json:"weight"`
+}
+
 type User struct {
-	ID           string       `json:"id"`
-	Email        string       `json:"email"`
-	PasswordHash string       `json:"PasswordHash"`
-	Preferences  *Preferences `json:"preferences,omitempty"`
+	ID            string       `json:"id"`
+	Email         string       `json:"email"`
+	EmailVerified bool         `json:"emailVerified"`
+	Role          Role         `json:"role"`
+	PasswordHash  string       `json:"PasswordHash"`
+	Preferences   *Preferences `json:"preferences,omitempty"`
+}
+
+type UserConnection struct {
+	Edges      []*UserEdge `json:"edges"`
+	PageInfo   *PageInfo   `json:"pageInfo"`
+	TotalCount int32       `json:"totalCount"`
+}
+
+type UserEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *User  `json:"node"`
+}
+
+type APIKeyScope string
+
+const (
+	APIKeyScopeEpisodesRead     APIKeyScope = "EPISODES_READ"
+	APIKeyScopeProfileRead      APIKeyScope = "PROFILE_READ"
+	APIKeyScopePreferencesWrite APIKeyScope = "PREFERENCES_WRITE"
+	APIKeyScopeFeedsWrite       APIKeyScope = "FEEDS_WRITE"
+)
+
+var AllAPIKeyScope = []APIKeyScope{
+	APIKeyScopeEpisodesRead,
+	APIKeyScopeProfileRead,
+	APIKeyScopePreferencesWrite,
+	APIKeyScopeFeedsWrite,
+}
+
+func (e APIKeyScope) IsValid() bool {
+	switch e {
+	case APIKeyScopeEpisodesRead, APIKeyScopeProfileRead, APIKeyScopePreferencesWrite, APIKeyScopeFeedsWrite:
+		return true
+	}
+	return false
+}
+
+func (e APIKeyScope) String() string {
+	return string(e)
+}
+
+func (e *APIKeyScope) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = APIKeyScope(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid APIKeyScope", str)
+	}
+	return nil
+}
+
+func (e APIKeyScope) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *APIKeyScope) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e APIKeyScope) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type EpisodeJobStatus string
+
+const (
+	EpisodeJobStatusQueued       EpisodeJobStatus = "QUEUED"
+	EpisodeJobStatusFetching     EpisodeJobStatus = "FETCHING"
+	EpisodeJobStatusScripting    EpisodeJobStatus = "SCRIPTING"
+	EpisodeJobStatusSynthesizing EpisodeJobStatus = "SYNTHESIZING"
+	EpisodeJobStatusPublished    EpisodeJobStatus = "PUBLISHED"
+	EpisodeJobStatusFailed       EpisodeJobStatus = "FAILED"
+)
+
+var AllEpisodeJobStatus = []EpisodeJobStatus{
+	EpisodeJobStatusQueued,
+	EpisodeJobStatusFetching,
+	EpisodeJobStatusScripting,
+	EpisodeJobStatusSynthesizing,
+	EpisodeJobStatusPublished,
+	EpisodeJobStatusFailed,
+}
+
+func (e EpisodeJobStatus) IsValid() bool {
+	switch e {
+	case EpisodeJobStatusQueued, EpisodeJobStatusFetching, EpisodeJobStatusScripting, EpisodeJobStatusSynthesizing, EpisodeJobStatusPublished, EpisodeJobStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e EpisodeJobStatus) String() string {
+	return string(e)
+}
+
+func (e *EpisodeJobStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = EpisodeJobStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid EpisodeJobStatus", str)
+	}
+	return nil
+}
+
+func (e EpisodeJobStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *EpisodeJobStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e EpisodeJobStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type GenerationStatus string
+
+const (
+	GenerationStatusPending GenerationStatus = "PENDING"
+	GenerationStatusRunning GenerationStatus = "RUNNING"
+	GenerationStatusDone    GenerationStatus = "DONE"
+	GenerationStatusDead    GenerationStatus = "DEAD"
+)
+
+var AllGenerationStatus = []GenerationStatus{
+	GenerationStatusPending,
+	GenerationStatusRunning,
+	GenerationStatusDone,
+	GenerationStatusDead,
+}
+
+func (e GenerationStatus) IsValid() bool {
+	switch e {
+	case GenerationStatusPending, GenerationStatusRunning, GenerationStatusDone, GenerationStatusDead:
+		return true
+	}
+	return false
+}
+
+func (e GenerationStatus) String() string {
+	return string(e)
+}
+
+func (e *GenerationStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = GenerationStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid GenerationStatus", str)
+	}
+	return nil
+}
+
+func (e GenerationStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *GenerationStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e GenerationStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type Role string
+
+const (
+	RoleUser  Role = "USER"
+	RoleAdmin Role = "ADMIN"
+)
+
+var AllRole = []Role{
+	RoleUser,
+	RoleAdmin,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleUser, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *Role) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e Role) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type Thumbs string
+
+const (
+	ThumbsUp   Thumbs = "UP"
+	ThumbsDown Thumbs = "DOWN"
+)
+
+var AllThumbs = []Thumbs{
+	ThumbsUp,
+	ThumbsDown,
+}
+
+func (e Thumbs) IsValid() bool {
+	switch e {
+	case ThumbsUp, ThumbsDown:
+		return true
+	}
+	return false
+}
+
+func (e Thumbs) String() string {
+	return string(e)
+}
+
+func (e *Thumbs) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Thumbs(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Thumbs", str)
+	}
+	return nil
+}
+
+func (e Thumbs) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *Thumbs) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e Thumbs) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
 }
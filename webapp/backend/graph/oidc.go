@@ -0,0 +1,379 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// oidcLoginTTL is how long a user has to finish signing in at the provider.
+	oidcLoginTTL = 10 * time.Minute
+
+	// oidcStateCookie binds a login to the browser that started it, so a
+	// callback link can't be used to sign someone else in.
+	oidcStateCookie = "oidc_state"
+)
+
+// ErrOIDCLoginInvalid is returned for unknown, expired or already used login states.
+var ErrOIDCLoginInvalid = errors.New("login request is invalid or has expired")
+
+// OIDCProvider is an OpenID Connect identity provider users can sign in with.
+// Discovery happens on first use, so a provider being down doesn't stop the
+// server from starting.
+type OIDCProvider struct {
+	ID   string
+	Name string
+
+	issuer       string
+	clientID     string
+	clientSecret string
+	scopes       []string
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+// LoadOIDCProviders reads the providers listed in OIDC_PROVIDERS. Each one is
+// configured by OIDC_<ID>_ISSUER, OIDC_<ID>_CLIENT_ID, an optional
+// OIDC_<ID>_CLIENT_SECRET (public clients rely on PKCE alone), an optional
+// OIDC_<ID>_NAME shown on the login page and optional extra OIDC_<ID>_SCOPES.
+func LoadOIDCProviders() ([]*OIDCProvider, error) {
+	var providers []*OIDCProvider
+	for _, id := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		id = strings.ToLower(strings.TrimSpace(id))
+		if id == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(id) + "_"
+		provider := &OIDCProvider{
+			ID:           id,
+			Name:         envOr(prefix+"NAME", id),
+			issuer:       os.Getenv(prefix + "ISSUER"),
+			clientID:     os.Getenv(prefix + "CLIENT_ID"),
+			clientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+		}
+		if provider.issuer == "" || provider.clientID == "" {
+			return nil, fmt.Errorf("%sISSUER and %sCLIENT_ID must be set for OIDC provider '%s'", prefix, prefix, id)
+		}
+		provider.scopes = append(provider.scopes, strings.Fields(os.Getenv(prefix+"SCOPES"))...)
+
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+// discover fetches the provider's OpenID configuration, caching it once it
+// succeeds.
+func (p *OIDCProvider) discover(ctx context.Context) (*oidc.Provider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.provider == nil {
+		provider, err := oidc.NewProvider(ctx, p.issuer)
+		if err != nil {
+			return nil, fmt.Errorf("OIDC discovery for '%s' failed: %w", p.ID, err)
+		}
+		p.provider = provider
+	}
+	return p.provider, nil
+}
+
+// OIDCLoginRequest is a sign in that has been sent to a provider and not yet
+// come back.
+type OIDCLoginRequest struct {
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+}
+
+// IdentityStore defines the interface for OIDC logins and linked identities.
+type IdentityStore interface {
+	CreateOIDCLoginRequest(ctx context.Context, stateHash string, request OIDCLoginRequest, expiresAt time.Time) error
+	ConsumeOIDCLoginRequest(ctx context.Context, stateHash string) (*OIDCLoginRequest, error)
+	GetIdentityUserID(ctx context.Context, provider, subject string) (string, error)
+	LinkIdentity(ctx context.Context, userID, provider, subject, email string) error
+}
+
+// oidcProvider looks up a configured provider by ID.
+func (r *Resolver) oidcProvider(id string) *OIDCProvider {
+	for _, provider := range r.OIDCProviders {
+		if provider.ID == id {
+			return provider
+		}
+	}
+	return nil
+}
+
+// oidcRedirectURL is the callback URL registered with a provider.
+func (r *Resolver) oidcRedirectURL(provider *OIDCProvider) string {
+	return r.PublicURL + "/auth/oidc/" + provider.ID + "/callback"
+}
+
+// oauth2Config builds the authorization code flow configuration for a provider.
+func (r *Resolver) oauth2Config(provider *OIDCProvider, discovered *oidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     provider.clientID,
+		ClientSecret: provider.clientSecret,
+		Endpoint:     discovered.Endpoint(),
+		RedirectURL:  r.oidcRedirectURL(provider),
+		Scopes:       provider.scopes,
+	}
+}
+
+// ServeOIDCStart serves GET /auth/oidc/{provider}/start, sending the browser
+// to the provider with a fresh state, nonce and PKCE challenge.
+func (r *Resolver) ServeOIDCStart(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	provider := r.oidcProvider(req.PathValue("provider"))
+	if provider == nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	discovered, err := provider.discover(ctx)
+	if err != nil {
+		log.Printf("oidc: %v", err)
+		r.redirectOIDCError(w, req, CodeUnavailable)
+		return
+	}
+
+	state, err := newToken()
+	if err != nil {
+		http.Error(w, "could not start login", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := newToken()
+	if err != nil {
+		http.Error(w, "could not start login", http.StatusInternalServerError)
+		return
+	}
+	login := OIDCLoginRequest{
+		Provider:     provider.ID,
+		Nonce:        nonce,
+		CodeVerifier: oauth2.GenerateVerifier(),
+	}
+
+	if err := r.Identities.CreateOIDCLoginRequest(ctx, hashToken(state), login, time.Now().Add(oidcLoginTTL)); err != nil {
+		log.Printf("oidc: %v", err)
+		http.Error(w, "could not start login", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/auth/oidc/",
+		MaxAge:   int(oidcLoginTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(r.PublicURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	authURL := r.oauth2Config(provider, discovered).AuthCodeURL(state,
+		oidc.Nonce(nonce),
+		oauth2.S256ChallengeOption(login.CodeVerifier),
+	)
+	http.Redirect(w, req, authURL, http.StatusFound)
+}
+
+// ServeOIDCCallback serves GET /auth/oidc/{provider}/callback. It exchanges
+// the authorization code, verifies the ID token, finds or creates the user
+// and hands the app's own tokens to the web app in the URL fragment.
+func (r *Resolver) ServeOIDCCallback(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	provider := r.oidcProvider(req.PathValue("provider"))
+	if provider == nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	query := req.URL.Query()
+	if query.Get("error") != "" {
+		r.redirectOIDCError(w, req, CodeLoginCancelled)
+		return
+	}
+
+	// The state must match the one this browser was given.
+	state := query.Get("state")
+	cookie, err := req.Cookie(oidcStateCookie)
+	if err != nil || state == "" || cookie.Value != state {
+		r.redirectOIDCError(w, req, CodeInvalidToken)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/auth/oidc/", MaxAge: -1})
+
+	login, err := r.Identities.ConsumeOIDCLoginRequest(ctx, hashToken(state))
+	if err != nil || login.Provider != provider.ID {
+		r.redirectOIDCError(w, req, CodeInvalidToken)
+		return
+	}
+
+	discovered, err := provider.discover(ctx)
+	if err != nil {
+		log.Printf("oidc: %v", err)
+		r.redirectOIDCError(w, req, CodeUnavailable)
+		return
+	}
+
+	token, err := r.oauth2Config(provider, discovered).Exchange(ctx, query.Get("code"), oauth2.VerifierOption(login.CodeVerifier))
+	if err != nil {
+		log.Printf("oidc: code exchange with '%s' failed: %v", provider.ID, err)
+		r.redirectOIDCError(w, req, CodeInvalidToken)
+		return
+	}
+
+	rawIDToken, _ := token.Extra("id_token").(string)
+	idToken, err := discovered.Verifier(&oidc.Config{ClientID: provider.clientID}).Verify(ctx, rawIDToken)
+	if err != nil || idToken.Nonce != login.Nonce {
+		log.Printf("oidc: invalid ID token from '%s': %v", provider.ID, err)
+		r.redirectOIDCError(w, req, CodeInvalidToken)
+		return
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		r.redirectOIDCError(w, req, CodeInvalidToken)
+		return
+	}
+
+	userID, err := r.oidcUser(ctx, provider.ID, idToken.Subject, normalizeEmail(claims.Email), claims.EmailVerified)
+	if err != nil {
+		if gqlCode := errorCode(err); gqlCode != "" {
+			r.redirectOIDCError(w, req, gqlCode)
+			return
+		}
+		log.Printf("oidc: %v", err)
+		r.redirectOIDCError(w, req, CodeInternal)
+		return
+	}
+
+	payload, err := r.startSession(ctx, userID)
+	if err != nil {
+		log.Printf("oidc: %v", err)
+		r.redirectOIDCError(w, req, CodeInternal)
+		return
+	}
+
+	// The fragment never reaches servers, so the tokens stay out of logs.
+	fragment := url.Values{
+		"accessToken":  {payload.AccessToken},
+		"refreshToken": {payload.RefreshToken},
+		"expiresAt":    {payload.ExpiresAt},
+	}
+	http.Redirect(w, req, r.AppURL+"/oauth/callback#"+fragment.Encode(), http.StatusFound)
+}
+
+// oidcUser returns the user an external identity belongs to. Unknown
+// identities are linked to the account with the same email, or to a new
+// account, but only when the provider has verified the email. Accounts whose
+// email hasn't been verified aren't linked: whoever created one may not own
+// the email, and would keep its password.
+func (r *Resolver) oidcUser(ctx context.Context, provider, subject, email string, emailVerified bool) (string, error) {
+	userID, err := r.Identities.GetIdentityUserID(ctx, provider, subject)
+	if err == nil {
+		return userID, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	if email == "" || !emailVerified {
+		return "", newError(ctx, CodeEmailNotVerified, "the provider did not return a verified email", nil)
+	}
+
+	user, err := r.Store.GetUserByEmail(ctx, email)
+	if err == nil && !user.EmailVerified {
+		return "", newError(ctx, CodeUnverifiedAccount, "an account with this email exists but its email has not been verified", nil)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		// Accounts created this way have no password until the user sets
+		// one through a password reset.
+		user, err = r.Store.CreateUser(ctx, email, "")
+		if err == nil {
+			err = r.Store.MarkEmailVerified(ctx, user.ID)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.Identities.LinkIdentity(ctx, user.ID, provider, subject, email); err != nil {
+		return "", err
+	}
+	return user.ID, nil
+}
+
+// redirectOIDCError sends the browser back to the web app's login page with an
+// error code.
+func (r *Resolver) redirectOIDCError(w http.ResponseWriter, req *http.Request, code string) {
+	http.Redirect(w, req, r.AppURL+"/login?error="+url.QueryEscape(code), http.StatusFound)
+}
+
+// CreateOIDCLoginRequest stores a pending login under the hash of its state.
+func (p *PGStore) CreateOIDCLoginRequest(ctx context.Context, stateHash string, request OIDCLoginRequest, expiresAt time.Time) error {
+	query := `
+		INSERT INTO oidc_login_requests (state_hash, provider, nonce, code_verifier, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	if _, err := p.db.ExecContext(ctx, query, stateHash, request.Provider, request.Nonce, request.CodeVerifier, expiresAt); err != nil {
+		return fmt.Errorf("failed to create login request: %w", err)
+	}
+	return nil
+}
+
+// ConsumeOIDCLoginRequest removes a pending login and returns it, so each
+// state can only be used once.
+func (p *PGStore) ConsumeOIDCLoginRequest(ctx context.Context, stateHash string) (*OIDCLoginRequest, error) {
+	var request OIDCLoginRequest
+	query := `
+		DELETE FROM oidc_login_requests
+		WHERE state_hash = $1 AND expires_at > now()
+		RETURNING provider, nonce, code_verifier`
+
+	err := p.db.QueryRowContext(ctx, query, stateHash).Scan(&request.Provider, &request.Nonce, &request.CodeVerifier)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOIDCLoginInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume login request: %w", err)
+	}
+	return &request, nil
+}
+
+// GetIdentityUserID returns the user an external identity is linked to.
+func (p *PGStore) GetIdentityUserID(ctx context.Context, provider, subject string) (string, error) {
+	var userID string
+	query := `SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2`
+
+	if err := p.db.QueryRowContext(ctx, query, provider, subject).Scan(&userID); err != nil {
+		return "", fmt.Errorf("identity not found: %w", err)
+	}
+	return userID, nil
+}
+
+// LinkIdentity links an external identity to a user.
+func (p *PGStore) LinkIdentity(ctx context.Context, userID, provider, subject, email string) error {
+	query := `INSERT INTO user_identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4)`
+
+	if _, err := p.db.ExecContext(ctx, query, userID, provider, subject, email); err != nil {
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,504 @@
+package graph
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ShavaizKhan/DailyNewsPodcast/webapp-backend/graph/model"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID  = "test-client"
+	testKeyID     = "test-key"
+	testAppURL    = "http://app.test"
+	testPublicURL = "http://backend.test"
+)
+
+// fakeIssuer is an OpenID Connect provider serving discovery, JWKS, an
+// authorization endpoint that approves every request and a token endpoint
+// that checks PKCE.
+type fakeIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	// The identity the next login signs in as.
+	subject       string
+	email         string
+	emailVerified bool
+	// nonce replaces the nonce from the authorization request when set.
+	nonce string
+
+	mu    sync.Mutex
+	codes map[string]fakeGrant
+}
+
+// fakeGrant is an authorization code waiting to be exchanged.
+type fakeGrant struct {
+	challenge   string
+	redirectURI string
+	nonce       string
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &fakeIssuer{
+		key:           key,
+		subject:       "subject-1",
+		email:         "listener@example.com",
+		emailVerified: true,
+		codes:         make(map[string]fakeGrant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", issuer.serveDiscovery)
+	mux.HandleFunc("GET /jwks", issuer.serveJWKS)
+	mux.HandleFunc("GET /authorize", issuer.serveAuthorize)
+	mux.HandleFunc("POST /token", issuer.serveToken)
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+	return issuer
+}
+
+func (i *fakeIssuer) serveDiscovery(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, map[string]any{
+		"issuer":                                i.URL,
+		"authorization_endpoint":                i.URL + "/authorize",
+		"token_endpoint":                        i.URL + "/token",
+		"jwks_uri":                              i.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (i *fakeIssuer) serveJWKS(w http.ResponseWriter, req *http.Request) {
+	public := i.key.PublicKey
+	writeJSON(w, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": testKeyID,
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}},
+	})
+}
+
+// serveAuthorize approves the request straight away and sends the browser
+// back with a code.
+func (i *fakeIssuer) serveAuthorize(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	if query.Get("client_id") != testClientID || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "bad authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code := fmt.Sprintf("code-%d", time.Now().UnixNano())
+	i.mu.Lock()
+	i.codes[code] = fakeGrant{
+		challenge:   query.Get("code_challenge"),
+		redirectURI: query.Get("redirect_uri"),
+		nonce:       query.Get("nonce"),
+	}
+	i.mu.Unlock()
+
+	callback := query.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+	http.Redirect(w, req, callback, http.StatusFound)
+}
+
+// serveToken exchanges a code for an ID token once the PKCE verifier
+// matches the challenge it was issued for.
+func (i *fakeIssuer) serveToken(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		http.Error(w, "bad token request", http.StatusBadRequest)
+		return
+	}
+
+	i.mu.Lock()
+	grant, ok := i.codes[req.PostForm.Get("code")]
+	delete(i.codes, req.PostForm.Get("code"))
+	i.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(req.PostForm.Get("code_verifier")))
+	if !ok || grant.redirectURI != req.PostForm.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	nonce := grant.nonce
+	if i.nonce != "" {
+		nonce = i.nonce
+	}
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            i.URL,
+		"sub":            i.subject,
+		"aud":            testClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          nonce,
+		"email":          i.email,
+		"email_verified": i.emailVerified,
+	})
+	idToken.Header["kid"] = testKeyID
+	signed, err := idToken.SignedString(i.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"access_token": "provider-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// fakeUsers keeps users in memory. Methods the OIDC flow doesn't use panic
+// through the nil embedded interface.
+type fakeUsers struct {
+	UserStore
+	users map[string]*model.User
+}
+
+func (s *fakeUsers) CreateUser(ctx context.Context, email, passwordHash string) (*model.User, error) {
+	if _, ok := s.users[email]; ok {
+		return nil, ErrEmailTaken
+	}
+	user := &model.User{ID: fmt.Sprintf("user-%d", len(s.users)+1), Email: email, PasswordHash: passwordHash, Role: model.RoleUser}
+	s.users[email] = user
+	return user, nil
+}
+
+func (s *fakeUsers) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	user, ok := s.users[email]
+	if !ok {
+		return nil, fmt.Errorf("user not found: %w", sql.ErrNoRows)
+	}
+	return user, nil
+}
+
+func (s *fakeUsers) MarkEmailVerified(ctx context.Context, id string) error {
+	for _, user := range s.users {
+		if user.ID == id {
+			user.EmailVerified = true
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+// fakeIdentities keeps login requests and linked identities in memory.
+type fakeIdentities struct {
+	logins     map[string]OIDCLoginRequest
+	identities map[string]string
+}
+
+func (s *fakeIdentities) CreateOIDCLoginRequest(ctx context.Context, stateHash string, request OIDCLoginRequest, expiresAt time.Time) error {
+	s.logins[stateHash] = request
+	return nil
+}
+
+func (s *fakeIdentities) ConsumeOIDCLoginRequest(ctx context.Context, stateHash string) (*OIDCLoginRequest, error) {
+	request, ok := s.logins[stateHash]
+	if !ok {
+		return nil, ErrOIDCLoginInvalid
+	}
+	delete(s.logins, stateHash)
+	return &request, nil
+}
+
+func (s *fakeIdentities) GetIdentityUserID(ctx context.Context, provider, subject string) (string, error) {
+	userID, ok := s.identities[provider+"|"+subject]
+	if !ok {
+		return "", fmt.Errorf("identity not found: %w", sql.ErrNoRows)
+	}
+	return userID, nil
+}
+
+func (s *fakeIdentities) LinkIdentity(ctx context.Context, userID, provider, subject, email string) error {
+	s.identities[provider+"|"+subject] = userID
+	return nil
+}
+
+// fakeSessions starts sessions without storing them.
+type fakeSessions struct {
+	SessionStore
+}
+
+func (s *fakeSessions) CreateSession(ctx context.Context, userID, refreshTokenHash string, expiresAt time.Time) (*Session, error) {
+	return &Session{ID: "session-" + userID, UserID: userID, Role: "user"}, nil
+}
+
+// oidcTest wires a resolver to a fake issuer.
+type oidcTest struct {
+	issuer     *fakeIssuer
+	users      *fakeUsers
+	identities *fakeIdentities
+	handler    http.Handler
+}
+
+func newOIDCTest(t *testing.T) *oidcTest {
+	t.Helper()
+	secret := []byte("test-secret")
+	tokens, err := NewJWTManager(&JWTConfig{
+		Algorithm:  jwt.SigningMethodHS256.Alg(),
+		Issuer:     defaultJWTIssuer,
+		Audience:   defaultJWTAudience,
+		TTL:        defaultJWTTTL,
+		RefreshTTL: defaultRefreshTTL,
+		Keys:       map[string]*SigningKey{"test": {Private: secret, Public: secret}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test := &oidcTest{
+		issuer:     newFakeIssuer(t),
+		users:      &fakeUsers{users: make(map[string]*model.User)},
+		identities: &fakeIdentities{logins: make(map[string]OIDCLoginRequest), identities: make(map[string]string)},
+	}
+	resolver := &Resolver{
+		JWT:        tokens,
+		Store:      test.users,
+		Sessions:   &fakeSessions{},
+		Identities: test.identities,
+		PublicURL:  testPublicURL,
+		AppURL:     testAppURL,
+		OIDCProviders: []*OIDCProvider{{
+			ID:       "fake",
+			Name:     "Fake",
+			issuer:   test.issuer.URL,
+			clientID: testClientID,
+			scopes:   []string{"openid", "email"},
+		}},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /auth/oidc/{provider}/start", resolver.ServeOIDCStart)
+	mux.HandleFunc("GET /auth/oidc/{provider}/callback", resolver.ServeOIDCCallback)
+	test.handler = mux
+	return test
+}
+
+// start begins a login and returns the provider's redirect to the callback
+// and the state cookie.
+func (o *oidcTest) start(t *testing.T) (*url.URL, *http.Cookie) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	o.handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, testPublicURL+"/auth/oidc/fake/start", nil))
+	response := recorder.Result()
+	if response.StatusCode != http.StatusFound {
+		t.Fatalf("start: got status %d, want %d", response.StatusCode, http.StatusFound)
+	}
+	cookies := response.Cookies()
+	if len(cookies) != 1 || cookies[0].Name != oidcStateCookie {
+		t.Fatalf("start: got cookies %v, want the state cookie", cookies)
+	}
+
+	// Follow the redirect to the provider, which approves and redirects back
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	authorize, err := client.Get(response.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorize.Body.Close()
+	if authorize.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: got status %d, want %d", authorize.StatusCode, http.StatusFound)
+	}
+
+	callback, err := url.Parse(authorize.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return callback, cookies[0]
+}
+
+// callback finishes a login and returns where the browser is sent.
+func (o *oidcTest) callback(t *testing.T, callback *url.URL, cookie *http.Cookie) *url.URL {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, callback.String(), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	recorder := httptest.NewRecorder()
+	o.handler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusFound {
+		t.Fatalf("callback: got status %d, want %d", recorder.Code, http.StatusFound)
+	}
+
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location
+}
+
+// login runs a whole sign in.
+func (o *oidcTest) login(t *testing.T) *url.URL {
+	t.Helper()
+	callback, cookie := o.start(t)
+	return o.callback(t, callback, cookie)
+}
+
+func assertSignedIn(t *testing.T, location *url.URL) {
+	t.Helper()
+	if got := location.Scheme + "://" + location.Host + location.Path; got != testAppURL+"/oauth/callback" {
+		t.Fatalf("got redirect to %s, want the app's OAuth callback", location)
+	}
+	fragment, err := url.ParseQuery(location.Fragment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fragment.Get("accessToken") == "" || fragment.Get("refreshToken") == "" {
+		t.Fatalf("got fragment %q, want access and refresh tokens", location.Fragment)
+	}
+}
+
+func assertLoginError(t *testing.T, location *url.URL, code string) {
+	t.Helper()
+	if got := location.Scheme + "://" + location.Host + location.Path; got != testAppURL+"/login" {
+		t.Fatalf("got redirect to %s, want the app's login page", location)
+	}
+	if got := location.Query().Get("error"); got != code {
+		t.Fatalf("got error %q, want %q", got, code)
+	}
+}
+
+func TestOIDCLoginCreatesUser(t *testing.T) {
+	o := newOIDCTest(t)
+
+	assertSignedIn(t, o.login(t))
+
+	user, ok := o.users.users[o.issuer.email]
+	if !ok {
+		t.Fatal("no user was created")
+	}
+	if !user.EmailVerified {
+		t.Error("new user's email is not verified")
+	}
+	if user.PasswordHash != "" {
+		t.Error("new user has a password")
+	}
+	if got := o.identities.identities["fake|"+o.issuer.subject]; got != user.ID {
+		t.Errorf("identity is linked to %q, want %q", got, user.ID)
+	}
+
+	// Signing in again finds the same user through the identity
+	assertSignedIn(t, o.login(t))
+	if len(o.users.users) != 1 {
+		t.Errorf("got %d users, want 1", len(o.users.users))
+	}
+}
+
+func TestOIDCLoginRejectsWrongCodeVerifier(t *testing.T) {
+	o := newOIDCTest(t)
+
+	callback, cookie := o.start(t)
+	for stateHash, login := range o.identities.logins {
+		login.CodeVerifier = "not-the-verifier-the-challenge-was-made-from"
+		o.identities.logins[stateHash] = login
+	}
+
+	assertLoginError(t, o.callback(t, callback, cookie), CodeInvalidToken)
+	if len(o.users.users) != 0 {
+		t.Error("a user was created")
+	}
+}
+
+func TestOIDCLoginRejectsStateMismatch(t *testing.T) {
+	o := newOIDCTest(t)
+
+	callback, cookie := o.start(t)
+	cookie.Value = "another-browsers-state"
+	assertLoginError(t, o.callback(t, callback, cookie), CodeInvalidToken)
+
+	// A callback without the state cookie is rejected too
+	callback, _ = o.start(t)
+	assertLoginError(t, o.callback(t, callback, nil), CodeInvalidToken)
+}
+
+func TestOIDCLoginRejectsNonceMismatch(t *testing.T) {
+	o := newOIDCTest(t)
+	o.issuer.nonce = "a-nonce-from-another-login"
+
+	assertLoginError(t, o.login(t), CodeInvalidToken)
+	if len(o.users.users) != 0 {
+		t.Error("a user was created")
+	}
+}
+
+func TestOIDCLoginRejectsUnverifiedEmail(t *testing.T) {
+	o := newOIDCTest(t)
+	o.issuer.emailVerified = false
+
+	assertLoginError(t, o.login(t), CodeEmailNotVerified)
+	if len(o.users.users) != 0 {
+		t.Error("a user was created")
+	}
+	if len(o.identities.identities) != 0 {
+		t.Error("an identity was linked")
+	}
+}
+
+func TestOIDCLoginLinksExistingAccount(t *testing.T) {
+	o := newOIDCTest(t)
+	existing := &model.User{ID: "existing", Email: o.issuer.email, EmailVerified: true, PasswordHash: "hash"}
+	o.users.users[existing.Email] = existing
+
+	assertSignedIn(t, o.login(t))
+
+	if len(o.users.users) != 1 {
+		t.Errorf("got %d users, want 1", len(o.users.users))
+	}
+	if got := o.identities.identities["fake|"+o.issuer.subject]; got != existing.ID {
+		t.Errorf("identity is linked to %q, want %q", got, existing.ID)
+	}
+}
+
+func TestOIDCLoginRefusesUnverifiedExistingAccount(t *testing.T) {
+	o := newOIDCTest(t)
+	existing := &model.User{ID: "existing", Email: o.issuer.email, PasswordHash: "hash"}
+	o.users.users[existing.Email] = existing
+
+	assertLoginError(t, o.login(t), CodeUnverifiedAccount)
+
+	if existing.EmailVerified {
+		t.Error("the existing account's email was marked verified")
+	}
+	if len(o.identities.identities) != 0 {
+		t.Error("an identity was linked")
+	}
+	if existing.PasswordHash != "hash" {
+		t.Error("the existing account's password was changed")
+	}
+}
@@ -0,0 +1,47 @@
+package graph
+
+import "github.com/ShavaizKhan/DailyNewsPodcast/webapp-backend/graph/model"
+
+// The registry of values preferences can be set to. Countries and topics
+// are limited to what the news provider can fetch headlines for, and
+// languages to those the speech synthesizer has voices for.
+var (
+	supportedCountries = []*model.Option{
+		{Value: "au", Label: "Australia"},
+		{Value: "ca", Label: "Canada"},
+		{Value: "fr", Label: "France"},
+		{Value: "de", Label: "Germany"},
+		{Value: "jp", Label: "Japan"},
+		{Value: "uk", Label: "United Kingdom"},
+		{Value: "us", Label: "United States"},
+	}
+
+	supportedTopics = []*model.Option{
+		{Value: "general", Label: "General"},
+		{Value: "business", Label: "Business"},
+		{Value: "education", Label: "Education"},
+		{Value: "entertainment", Label: "Entertainment"},
+		{Value: "health", Label: "Health & Wellness"},
+		{Value: "politics", Label: "Politics"},
+		{Value: "science", Label: "Science"},
+		{Value: "sports", Label: "Sports"},
+		{Value: "technology", Label: "Technology"},
+	}
+
+	supportedLanguages = []*model.Option{
+		{Value: "en", Label: "English"},
+		{Value: "fr", Label: "French"},
+		{Value: "de", Label: "German"},
+		{Value: "es", Label: "Spanish"},
+	}
+)
+
+// isSupported reports whether value is one of options.
+func isSupported(options []*model.Option, value string) bool {
+	for _, option := range options {
+		if option.Value == value {
+			return true
+		}
+	}
+	return false
+}
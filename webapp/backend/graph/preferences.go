@@ -0,0 +1,245 @@
+package graph
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ShavaizKhan/DailyNewsPodcast/webapp-backend/graph/model"
+)
+
+const (
+	// Default preferences for new users.
+	defaultCountry  = "us"
+	defaultTopic    = "general"
+	defaultLanguage = "en"
+	defaultTimezone = "UTC"
+
+	// Bounds of a preference weight.
+	minPreferenceWeight = 1
+	maxPreferenceWeight = 10
+
+	// maxPreferences caps how many topics or countries a user can pick.
+	maxPreferences = 10
+)
+
+// userPreferencesColumns selects a user's weighted topics and countries as
+// JSON arrays, heaviest first, followed by their language, their article
+// filters as a JSON array, their timezone and their delivery time. It must be
+// used in a query over users and scanned into a preferencesRow.
+const userPreferencesColumns = `
+	COALESCE((SELECT json_agg(json_build_object('topic', t.topic, 'weight', t.weight) ORDER BY t.weight DESC, t.topic)
+		FROM user_topics t WHERE t.user_id = users.id), '[]'),
+	COALESCE((SELECT json_agg(json_build_object('country', c.country, 'weight', c.weight) ORDER BY c.weight DESC, c.country)
+		FROM user_countries c WHERE c.user_id = users.id), '[]'),
+	users.language,
+	COALESCE((SELECT json_agg(json_build_object('kind', f.kind, 'value', f.value) ORDER BY f.kind, f.value)
+		FROM user_filters f WHERE f.user_id = users.id), '[]'),
+	users.timezone,
+	to_char(users.delivery_time, 'HH24:MI')`
+
+// newPreferences builds Preferences from weighted topics and countries
+// ordered heaviest first, with no scheduled delivery.
+func newPreferences(topics []*model.TopicWeight, countries []*model.CountryWeight, language string, filters *model.ArticleFilters) *model.Preferences {
+	preferences := &model.Preferences{Topics: topics, Countries: countries, Language: language, Filters: filters, Timezone: defaultTimezone}
+	if len(topics) > 0 {
+		preferences.Topic = topics[0].Topic
+	}
+	if len(countries) > 0 {
+		preferences.Country = countries[0].Country
+	}
+	return preferences
+}
+
+// preferencesRow holds the columns selected by userPreferencesColumns.
+type preferencesRow struct {
+	topics, countries, filters []byte
+	language, timezone         string
+	deliveryTime               sql.NullString
+}
+
+// dest returns the scan destinations for userPreferencesColumns.
+func (r *preferencesRow) dest() []any {
+	return []any{&r.topics, &r.countries, &r.language, &r.filters, &r.timezone, &r.deliveryTime}
+}
+
+// decode decodes the scanned columns.
+func (r *preferencesRow) decode() (*model.Preferences, error) {
+	var topics []*model.TopicWeight
+	if err := json.Unmarshal(r.topics, &topics); err != nil {
+		return nil, fmt.Errorf("failed to decode topics: %w", err)
+	}
+	var countries []*model.CountryWeight
+	if err := json.Unmarshal(r.countries, &countries); err != nil {
+		return nil, fmt.Errorf("failed to decode countries: %w", err)
+	}
+	filters, err := decodeArticleFilters(r.filters)
+	if err != nil {
+		return nil, err
+	}
+
+	preferences := newPreferences(topics, countries, r.language, filters)
+	preferences.Timezone = r.timezone
+	if r.deliveryTime.Valid {
+		preferences.DeliveryTime = &r.deliveryTime.String
+	}
+	return preferences, nil
+}
+
+// userPreferences loads a user's preferences.
+func (p *PGStore) userPreferences(ctx context.Context, id string) (*model.Preferences, error) {
+	var row preferencesRow
+	query := `SELECT ` + userPreferencesColumns + ` FROM users WHERE id = $1`
+
+	if err := p.db.QueryRowContext(ctx, query, id).Scan(row.dest()...); err != nil {
+		return nil, fmt.Errorf("failed to load user preferences: %w", err)
+	}
+	return row.decode()
+}
+
+// topicNames returns the names of weighted topics.
+func topicNames(topics []*model.TopicWeight) []string {
+	names := make([]string, len(topics))
+	for i, topic := range topics {
+		names[i] = topic.Topic
+	}
+	return names
+}
+
+// countryNames returns the codes of weighted countries.
+func countryNames(countries []*model.CountryWeight) []string {
+	names := make([]string, len(countries))
+	for i, country := range countries {
+		names[i] = country.Country
+	}
+	return names
+}
+
+// validatePreferences checks an updatePreferences request against the
+// supported options registry, returning the topics and countries normalized
+// and heaviest first.
+func validatePreferences(ctx context.Context, topicInputs []*model.TopicWeightInput, countryInputs []*model.CountryWeightInput, language *string) ([]*model.TopicWeight, []*model.CountryWeight, error) {
+	topics := make([]*model.TopicWeight, len(topicInputs))
+	for i, input := range topicInputs {
+		topics[i] = &model.TopicWeight{Topic: strings.ToLower(strings.TrimSpace(input.Topic)), Weight: input.Weight}
+	}
+	countries := make([]*model.CountryWeight, len(countryInputs))
+	for i, input := range countryInputs {
+		countries[i] = &model.CountryWeight{Country: strings.ToLower(strings.TrimSpace(input.Country)), Weight: input.Weight}
+	}
+
+	if err := validateWeighted(ctx, "topics", "topic", topicNames(topics), topicWeights(topics), supportedTopics); err != nil {
+		return nil, nil, err
+	}
+	if err := validateWeighted(ctx, "countries", "country", countryNames(countries), countryWeights(countries), supportedCountries); err != nil {
+		return nil, nil, err
+	}
+	if language != nil && !isSupported(supportedLanguages, *language) {
+		return nil, nil, fieldError(ctx, "language", fmt.Sprintf("'%s' is not a supported language", *language))
+	}
+
+	sortByWeight(topics, func(t *model.TopicWeight) (int32, string) { return t.Weight, t.Topic })
+	sortByWeight(countries, func(c *model.CountryWeight) (int32, string) { return c.Weight, c.Country })
+	return topics, countries, nil
+}
+
+// validateWeighted checks one list of weighted preferences.
+func validateWeighted(ctx context.Context, field, kind string, names []string, weights []int32, supported []*model.Option) error {
+	if len(names) == 0 {
+		return fieldError(ctx, field, "choose at least one "+kind)
+	}
+	if len(names) > maxPreferences {
+		return fieldError(ctx, field, fmt.Sprintf("choose at most %d", maxPreferences))
+	}
+
+	seen := make(map[string]bool, len(names))
+	for i, name := range names {
+		if !isSupported(supported, name) {
+			return fieldError(ctx, field, fmt.Sprintf("'%s' is not a supported %s", name, kind))
+		}
+		if seen[name] {
+			return fieldError(ctx, field, fmt.Sprintf("'%s' is listed more than once", name))
+		}
+		seen[name] = true
+
+		if weights[i] < minPreferenceWeight || weights[i] > maxPreferenceWeight {
+			return fieldError(ctx, field, fmt.Sprintf("weights must be between %d and %d", minPreferenceWeight, maxPreferenceWeight))
+		}
+	}
+	return nil
+}
+
+// topicWeights returns the weights of weighted topics.
+func topicWeights(topics []*model.TopicWeight) []int32 {
+	weights := make([]int32, len(topics))
+	for i, topic := range topics {
+		weights[i] = topic.Weight
+	}
+	return weights
+}
+
+// countryWeights returns the weights of weighted countries.
+func countryWeights(countries []*model.CountryWeight) []int32 {
+	weights := make([]int32, len(countries))
+	for i, country := range countries {
+		weights[i] = country.Weight
+	}
+	return weights
+}
+
+// sortByWeight orders preferences heaviest first, then by name, matching
+// userPreferencesColumns.
+func sortByWeight[T any](items []T, key func(T) (int32, string)) {
+	slices.SortStableFunc(items, func(a, b T) int {
+		weightA, nameA := key(a)
+		weightB, nameB := key(b)
+		if weightA != weightB {
+			return cmp.Compare(weightB, weightA)
+		}
+		return strings.Compare(nameA, nameB)
+	})
+}
+
+// UpdateUserPreferences replaces a user's weighted topics and countries, and
+// their language unless it is nil.
+func (p *PGStore) UpdateUserPreferences(ctx context.Context, id string, topics []*model.TopicWeight, countries []*model.CountryWeight, language *string) (*model.Preferences, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	update := `UPDATE users SET language = COALESCE($1, language) WHERE id = $2`
+	if _, err := tx.ExecContext(ctx, update, language, id); err != nil {
+		return nil, fmt.Errorf("failed to update user preferences: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_topics WHERE user_id = $1`, id); err != nil {
+		return nil, fmt.Errorf("failed to update user preferences: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_countries WHERE user_id = $1`, id); err != nil {
+		return nil, fmt.Errorf("failed to update user preferences: %w", err)
+	}
+
+	for _, topic := range topics {
+		insert := `INSERT INTO user_topics (user_id, topic, weight) VALUES ($1, $2, $3)`
+		if _, err := tx.ExecContext(ctx, insert, id, topic.Topic, topic.Weight); err != nil {
+			return nil, fmt.Errorf("failed to update user preferences: %w", err)
+		}
+	}
+	for _, country := range countries {
+		insert := `INSERT INTO user_countries (user_id, country, weight) VALUES ($1, $2, $3)`
+		if _, err := tx.ExecContext(ctx, insert, id, country.Country, country.Weight); err != nil {
+			return nil, fmt.Errorf("failed to update user preferences: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to update user preferences: %w", err)
+	}
+	return p.userPreferences(ctx, id)
+}
@@ -0,0 +1,281 @@
+package graph
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// ProgressBroker delivers changes to episode jobs to the subscriptions
+// watching them.
+type ProgressBroker interface {
+	Publish(ctx context.Context, job *EpisodeJob) error
+	// Subscribe returns the changes published for a job. The channel is
+	// closed once ctx is done.
+	Subscribe(ctx context.Context, jobID string) (<-chan *EpisodeJob, error)
+}
+
+// MemoryProgressBroker is a ProgressBroker for a single server instance.
+// Subscribers that fall behind only receive the latest change.
+type MemoryProgressBroker struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan *EpisodeJob]struct{}
+}
+
+// NewMemoryProgressBroker creates a MemoryProgressBroker with no subscribers.
+func NewMemoryProgressBroker() *MemoryProgressBroker {
+	return &MemoryProgressBroker{subscribers: make(map[string]map[chan *EpisodeJob]struct{})}
+}
+
+// Publish sends a change to the job's subscribers.
+func (b *MemoryProgressBroker) Publish(ctx context.Context, job *EpisodeJob) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[job.ID] {
+		// Replace a change the subscriber hasn't read yet.
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- job
+	}
+	return nil
+}
+
+// Subscribe returns the changes published for a job until ctx is done.
+func (b *MemoryProgressBroker) Subscribe(ctx context.Context, jobID string) (<-chan *EpisodeJob, error) {
+	ch := make(chan *EpisodeJob, 1)
+
+	b.mu.Lock()
+	if b.subscribers[jobID] == nil {
+		b.subscribers[jobID] = make(map[chan *EpisodeJob]struct{})
+	}
+	b.subscribers[jobID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers[jobID], ch)
+		if len(b.subscribers[jobID]) == 0 {
+			delete(b.subscribers, jobID)
+		}
+		close(ch)
+	}()
+	return ch, nil
+}
+
+// progressChannel is the Postgres notification channel job changes are
+// published on.
+const progressChannel = "episode_progress"
+
+// PGProgressBroker is a ProgressBroker shared by several server instances
+// through Postgres notifications.
+type PGProgressBroker struct {
+	store *PGStore
+	local *MemoryProgressBroker
+}
+
+// NewPGProgressBroker creates a PGProgressBroker. Listen must be running for
+// subscribers to receive changes.
+func NewPGProgressBroker(store *PGStore) *PGProgressBroker {
+	return &PGProgressBroker{store: store, local: NewMemoryProgressBroker()}
+}
+
+// Publish notifies every instance of a change.
+func (b *PGProgressBroker) Publish(ctx context.Context, job *EpisodeJob) error {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode episode job: %w", err)
+	}
+
+	if _, err := b.store.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, progressChannel, string(payload)); err != nil {
+		return fmt.Errorf("failed to publish episode progress: %w", err)
+	}
+	return nil
+}
+
+// Subscribe returns the changes published for a job until ctx is done.
+func (b *PGProgressBroker) Subscribe(ctx context.Context, jobID string) (<-chan *EpisodeJob, error) {
+	return b.local.Subscribe(ctx, jobID)
+}
+
+// progressRetryInterval is how long to wait before listening again after
+// losing the connection, and before trying again to take over the job watcher.
+const progressRetryInterval = 5 * time.Second
+
+// Listen passes the changes published by every instance to this instance's
+// subscribers until ctx is done.
+func (b *PGProgressBroker) Listen(ctx context.Context) {
+	for {
+		err := b.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("stopped listening for episode progress, retrying: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(progressRetryInterval):
+		}
+	}
+}
+
+// listen holds a connection listening for notifications until it fails.
+func (b *PGProgressBroker) listen(ctx context.Context) error {
+	conn, err := b.store.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var listenErr error
+	conn.Raw(func(driverConn any) error {
+		pgConn := driverConn.(*stdlib.Conn).Conn()
+		if _, listenErr = pgConn.Exec(ctx, "LISTEN "+progressChannel); listenErr != nil {
+			return driver.ErrBadConn
+		}
+
+		for {
+			notification, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				listenErr = err
+				// Don't return a listening connection to the pool.
+				return driver.ErrBadConn
+			}
+
+			var job EpisodeJob
+			if err := json.Unmarshal([]byte(notification.Payload), &job); err != nil {
+				log.Printf("ignoring malformed episode progress: %v", err)
+				continue
+			}
+			b.local.Publish(ctx, &job)
+		}
+	})
+	return listenErr
+}
+
+// RunExclusively calls run while holding the Postgres advisory lock called
+// name, so only one instance does the work at a time. The others wait to take
+// over until ctx is done.
+func (p *PGStore) RunExclusively(ctx context.Context, name string, run func(context.Context)) {
+	for {
+		if err := p.runWithLock(ctx, name, run); err != nil && ctx.Err() == nil {
+			log.Printf("could not run %s: %v", name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(progressRetryInterval):
+		}
+	}
+}
+
+// runWithLock calls run if the lock called name is free, stopping it if the
+// connection holding the lock is lost.
+func (p *PGStore) runWithLock(ctx context.Context, name string, run func(context.Context)) error {
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, name).Scan(&locked); err != nil {
+		return fmt.Errorf("failed to take lock: %w", err)
+	}
+	if !locked {
+		return nil
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtext($1))`, name)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		ticker := time.NewTicker(progressRetryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-runCtx.Done():
+				return
+			case <-ticker.C:
+				if err := conn.PingContext(runCtx); err != nil {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	run(runCtx)
+	if err := ctx.Err(); err == nil && runCtx.Err() != nil {
+		return errors.New("lost the connection holding the lock")
+	}
+	return nil
+}
+
+// JobWatcherLock names the advisory lock held by the instance running the
+// job watcher when progress is shared through Postgres.
+const JobWatcherLock = "episode_job_watcher"
+
+const (
+	// defaultJobWatchInterval is how often the job watcher polls for changes.
+	defaultJobWatchInterval = 2 * time.Second
+
+	// jobWatchOverlap is how far each poll looks back past the previous one,
+	// so changes committed slightly out of order aren't missed. Subscriptions
+	// skip the repeats.
+	jobWatchOverlap = 2 * time.Second
+)
+
+// JobWatcher publishes the changes the generator makes to episode jobs in the
+// database to a progress broker.
+type JobWatcher struct {
+	Jobs     EpisodeJobStore
+	Broker   ProgressBroker
+	Interval time.Duration
+}
+
+// Run polls for changed jobs until ctx is done.
+func (w *JobWatcher) Run(ctx context.Context) {
+	interval := w.Interval
+	if interval == 0 {
+		interval = defaultJobWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	polledAt := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		jobs, err := w.Jobs.ListEpisodeJobsUpdatedSince(ctx, polledAt.Add(-jobWatchOverlap))
+		if err != nil {
+			log.Printf("could not poll episode jobs: %v", err)
+			continue
+		}
+		for _, job := range jobs {
+			if err := w.Broker.Publish(ctx, job); err != nil {
+				log.Printf("could not publish progress of episode job %s: %v", job.ID, err)
+			}
+		}
+		polledAt = now
+	}
+}
@@ -0,0 +1,237 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ShavaizKhan/DailyNewsPodcast/webapp-backend/graph/model"
+)
+
+// Generation queue statuses shared with the worker. Requests that run out of
+// attempts are dead-lettered and kept for review.
+const (
+	QueueStatusPending = "pending"
+	QueueStatusRunning = "running"
+	QueueStatusDone    = "done"
+	QueueStatusDead    = "dead"
+)
+
+// ErrJobNotRetryable is returned when a dead-lettered request is for a
+// personal episode job that can't be run again: it was published or deleted,
+// or the user has started another job since.
+var ErrJobNotRetryable = errors.New("episode job can't be retried")
+
+const (
+	// Page size of the generationQueue query.
+	defaultQueuePageSize = 50
+	maxQueuePageSize     = 200
+)
+
+// GenerationQueueStore defines the interface for the generation queue the
+// worker process works through.
+type GenerationQueueStore interface {
+	EnqueueGeneration(ctx context.Context, payload []byte) error
+	// ListQueuedGenerations lists requests, most recently updated first. An
+	// empty status lists all of them.
+	ListQueuedGenerations(ctx context.Context, status string, limit int) ([]*QueuedGeneration, error)
+	// RetryQueuedGeneration returns a dead-lettered request to the queue with
+	// a fresh set of attempts, along with the personal episode job it is for.
+	RetryQueuedGeneration(ctx context.Context, id string) (*QueuedGeneration, error)
+}
+
+// QueueGenerator runs episode generation by adding requests to the queue
+// worked through by the worker process.
+type QueueGenerator struct {
+	queue GenerationQueueStore
+}
+
+// NewQueueGenerator creates a QueueGenerator adding to queue.
+func NewQueueGenerator(queue GenerationQueueStore) *QueueGenerator {
+	return &QueueGenerator{queue: queue}
+}
+
+// Generate queues the shared episodes.
+func (g *QueueGenerator) Generate(ctx context.Context) error {
+	return g.queue.EnqueueGeneration(ctx, []byte("{}"))
+}
+
+// GenerateJob queues a personal episode job. The worker loads the job's
+// request and records its progress on the job.
+func (g *QueueGenerator) GenerateJob(ctx context.Context, jobID string) error {
+	payload, err := jobEvent(jobID)
+	if err != nil {
+		return err
+	}
+	return g.queue.EnqueueGeneration(ctx, payload)
+}
+
+// QueuedGeneration is a row in the generation_queue table.
+type QueuedGeneration struct {
+	ID          string
+	Status      string
+	Payload     string
+	Attempts    int32
+	MaxAttempts int32
+	RunAt       time.Time
+	LockedBy    *string
+	LastError   *string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// toModel converts a queued request to its GraphQL representation.
+func (q *QueuedGeneration) toModel() *model.QueuedGeneration {
+	return &model.QueuedGeneration{
+		ID:          q.ID,
+		Status:      model.GenerationStatus(strings.ToUpper(q.Status)),
+		Payload:     q.Payload,
+		Attempts:    q.Attempts,
+		MaxAttempts: q.MaxAttempts,
+		RunAt:       q.RunAt.UTC().Format(time.RFC3339),
+		LockedBy:    q.LockedBy,
+		LastError:   q.LastError,
+		CreatedAt:   q.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:   q.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+const queuedGenerationColumns = `id, status, payload::text, attempts, max_attempts, run_at, locked_by, last_error, created_at, updated_at`
+
+// scanQueuedGeneration reads a row selected with queuedGenerationColumns.
+func scanQueuedGeneration(row interface{ Scan(...any) error }) (*QueuedGeneration, error) {
+	var q QueuedGeneration
+	var lockedBy, lastError sql.NullString
+	err := row.Scan(&q.ID, &q.Status, &q.Payload, &q.Attempts, &q.MaxAttempts, &q.RunAt, &lockedBy, &lastError, &q.CreatedAt, &q.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if lockedBy.Valid {
+		q.LockedBy = &lockedBy.String
+	}
+	if lastError.Valid {
+		q.LastError = &lastError.String
+	}
+	return &q, nil
+}
+
+// EnqueueGeneration adds a generation request to the queue.
+func (p *PGStore) EnqueueGeneration(ctx context.Context, payload []byte) error {
+	if _, err := p.db.ExecContext(ctx, `INSERT INTO generation_queue (payload) VALUES ($1)`, payload); err != nil {
+		return fmt.Errorf("failed to enqueue generation: %w", err)
+	}
+	return nil
+}
+
+// ListQueuedGenerations lists requests, most recently updated first.
+func (p *PGStore) ListQueuedGenerations(ctx context.Context, status string, limit int) ([]*QueuedGeneration, error) {
+	query := `
+		SELECT ` + queuedGenerationColumns + `
+		FROM generation_queue
+		WHERE $1 = '' OR status = $1
+		ORDER BY updated_at DESC
+		LIMIT $2`
+
+	rows, err := p.db.QueryContext(ctx, query, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list generation queue: %w", err)
+	}
+	defer rows.Close()
+
+	var queued []*QueuedGeneration
+	for rows.Next() {
+		q, err := scanQueuedGeneration(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan queued generation: %w", err)
+		}
+		queued = append(queued, q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list generation queue: %w", err)
+	}
+	return queued, nil
+}
+
+// RetryQueuedGeneration returns a dead-lettered request to the queue with a
+// fresh set of attempts. The worker only runs jobs that are queued, so a
+// failed job the request is for is queued again too.
+func (p *PGStore) RetryQueuedGeneration(ctx context.Context, id string) (*QueuedGeneration, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE generation_queue
+		SET status = $1, attempts = 0, run_at = now(), updated_at = now()
+		WHERE id = $2 AND status = $3
+		RETURNING ` + queuedGenerationColumns
+
+	q, err := scanQueuedGeneration(tx.QueryRowContext(ctx, query, QueueStatusPending, id, QueueStatusDead))
+	if isInvalidText(err) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dead-lettered generation '%s' not found: %w", id, err)
+	}
+
+	var event struct {
+		JobID string `json:"jobId"`
+	}
+	if err := json.Unmarshal([]byte(q.Payload), &event); err != nil {
+		return nil, fmt.Errorf("failed to decode generation request '%s': %w", id, err)
+	}
+	if event.JobID != "" {
+		if err := requeueFailedJob(ctx, tx, event.JobID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to retry generation '%s': %w", id, err)
+	}
+	return q, nil
+}
+
+// requeueFailedJob queues a failed job again, unless the user has started
+// another since. Jobs still in progress are left as they are.
+func requeueFailedJob(ctx context.Context, tx *sql.Tx, jobID string) error {
+	requeue := `
+		UPDATE episode_jobs
+		SET status = $1, progress = 0, error = NULL, updated_at = now()
+		WHERE id = $2 AND status = $3 AND NOT EXISTS (
+			SELECT 1 FROM episode_jobs active
+			WHERE active.user_id = episode_jobs.user_id AND active.status NOT IN ('published', 'failed')
+		)`
+
+	result, err := tx.ExecContext(ctx, requeue, JobStatusQueued, jobID, JobStatusFailed)
+	if err != nil {
+		return fmt.Errorf("failed to requeue episode job '%s': %w", jobID, err)
+	}
+	requeued, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to requeue episode job '%s': %w", jobID, err)
+	}
+	if requeued > 0 {
+		return nil
+	}
+
+	var status string
+	err = tx.QueryRowContext(ctx, `SELECT status FROM episode_jobs WHERE id = $1`, jobID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) || isInvalidText(err) {
+		return ErrJobNotRetryable
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find episode job '%s': %w", jobID, err)
+	}
+	if status == JobStatusPublished || status == JobStatusFailed {
+		return ErrJobNotRetryable
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// RateLimitStore keeps the counters behind rate limits. Each counter expires a
+// fixed window after its first hit. The in-memory store is enough for a single
+// server; PGStore shares counters between instances.
+type RateLimitStore interface {
+	// IncrRateLimit adds one to the counter for key and returns the new count
+	// and when the counter resets.
+	IncrRateLimit(ctx context.Context, key string, window time.Duration) (int, time.Time, error)
+	// GetRateLimit returns the current count for key and when it resets.
+	GetRateLimit(ctx context.Context, key string) (int, time.Time, error)
+	// ResetRateLimit clears the counter for key.
+	ResetRateLimit(ctx context.Context, key string) error
+}
+
+// rateLimit allows max hits per window.
+type rateLimit struct {
+	max    int
+	window time.Duration
+}
+
+// rateLimitRule describes how a mutation is throttled. Per-account limits are
+// keyed by the normalized email argument and per-user limits by the signed in
+// user.
+type rateLimitRule struct {
+	perIP      rateLimit
+	perAccount rateLimit
+	perUser    rateLimit
+
+	// trackFailures enables progressive delays and lockout for failed logins.
+	trackFailures bool
+}
+
+// Brute-force protection for failed logins. After loginDelayAfter failures
+// each attempt is slowed down, doubling up to loginMaxDelay; after
+// loginLockoutAfter failures the account is locked until the window ends.
+const (
+	loginFailureWindow = 15 * time.Minute
+	loginDelayAfter    = 3
+	loginLockoutAfter  = 10
+	loginBaseDelay     = 250 * time.Millisecond
+	loginMaxDelay      = 4 * time.Second
+)
+
+// rateLimitRules lists the throttled mutations by field name.
+var rateLimitRules = map[string]rateLimitRule{
+	"login": {
+		perIP:         rateLimit{max: 20, window: time.Minute},
+		trackFailures: true,
+	},
+	"signup": {
+		perIP: rateLimit{max: 5, window: time.Hour},
+	},
+	"requestPasswordReset": {
+		perIP:      rateLimit{max: 5, window: time.Hour},
+		perAccount: rateLimit{max: 3, window: time.Hour},
+	},
+	"resetPassword": {
+		perIP: rateLimit{max: 10, window: time.Hour},
+	},
+	"generateMyEpisode": {
+		perUser: rateLimit{max: 5, window: 24 * time.Hour},
+	},
+}
+
+// RateLimiter throttles authentication and generation mutations per client
+// IP, account and user.
+type RateLimiter struct {
+	store RateLimitStore
+}
+
+// NewRateLimiter creates a RateLimiter backed by store.
+func NewRateLimiter(store RateLimitStore) *RateLimiter {
+	return &RateLimiter{store: store}
+}
+
+// Middleware is a gqlgen field middleware enforcing rateLimitRules.
+func (l *RateLimiter) Middleware(ctx context.Context, next graphql.Resolver) (any, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || fc.Object != "Mutation" {
+		return next(ctx)
+	}
+	rule, ok := rateLimitRules[fc.Field.Name]
+	if !ok {
+		return next(ctx)
+	}
+
+	if rule.perIP.max > 0 {
+		key := fmt.Sprintf("ip:%s:%s", fc.Field.Name, clientIPFromContext(ctx))
+		if err := l.hit(ctx, key, rule.perIP); err != nil {
+			return nil, err
+		}
+	}
+
+	if rule.perUser.max > 0 {
+		if userID, err := GetUserIDFromContext(ctx); err == nil {
+			key := fmt.Sprintf("user:%s:%s", fc.Field.Name, userID)
+			if err := l.hit(ctx, key, rule.perUser); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	email, _ := fc.Args["email"].(string)
+	email = normalizeEmail(email)
+	if email == "" {
+		return next(ctx)
+	}
+
+	if rule.perAccount.max > 0 {
+		key := fmt.Sprintf("account:%s:%s", fc.Field.Name, email)
+		if err := l.hit(ctx, key, rule.perAccount); err != nil {
+			return nil, err
+		}
+	}
+
+	if !rule.trackFailures {
+		return next(ctx)
+	}
+
+	failureKey := "failures:" + email
+	if err := l.throttleFailures(ctx, failureKey); err != nil {
+		return nil, err
+	}
+
+	res, err := next(ctx)
+	switch {
+	case errorCode(err) == CodeInvalidCredentials:
+		if _, _, err := l.store.IncrRateLimit(ctx, failureKey, loginFailureWindow); err != nil {
+			log.Printf("could not record failed login: %v", err)
+		}
+	case err == nil:
+		if err := l.store.ResetRateLimit(ctx, failureKey); err != nil {
+			log.Printf("could not reset failed logins: %v", err)
+		}
+	}
+	return res, err
+}
+
+// hit counts a request against limit, returning RATE_LIMITED once it is used
+// up. Store errors are logged and let the request through, so an outage of a
+// shared store doesn't lock everyone out.
+func (l *RateLimiter) hit(ctx context.Context, key string, limit rateLimit) error {
+	count, resetAt, err := l.store.IncrRateLimit(ctx, key, limit.window)
+	if err != nil {
+		log.Printf("rate limit check failed for %s: %v", key, err)
+		return nil
+	}
+	if count > limit.max {
+		return newError(ctx, CodeRateLimited, "too many requests, try again later", retryAfter(resetAt))
+	}
+	return nil
+}
+
+// throttleFailures rejects locked accounts and slows down attempts on accounts
+// with recent failed logins.
+func (l *RateLimiter) throttleFailures(ctx context.Context, key string) error {
+	failures, resetAt, err := l.store.GetRateLimit(ctx, key)
+	if err != nil {
+		log.Printf("rate limit check failed for %s: %v", key, err)
+		return nil
+	}
+
+	if failures >= loginLockoutAfter {
+		return newError(ctx, CodeAccountLocked, "too many failed sign in attempts, try again later", retryAfter(resetAt))
+	}
+	if failures < loginDelayAfter {
+		return nil
+	}
+
+	delay := time.Duration(float64(loginBaseDelay) * math.Pow(2, float64(failures-loginDelayAfter)))
+	timer := time.NewTimer(min(delay, loginMaxDelay))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryAfter builds the extensions telling clients how many seconds to wait.
+func retryAfter(resetAt time.Time) map[string]any {
+	return map[string]any{"retryAfter": int(math.Ceil(time.Until(resetAt).Seconds()))}
+}
+
+// errorCode returns the code extension of a GraphQL error, if any.
+func errorCode(err error) string {
+	var gqlErr *gqlerror.Error
+	if !errors.As(err, &gqlErr) {
+		return ""
+	}
+	code, _ := gqlErr.Extensions["code"].(string)
+	return code
+}
+
+// MemoryRateLimitStore is a RateLimitStore for a single server instance.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	counters  map[string]*rateLimitCounter
+	lastSweep time.Time
+}
+
+type rateLimitCounter struct {
+	count   int
+	resetAt time.Time
+}
+
+// rateLimitSweepInterval is how often expired counters are dropped.
+const rateLimitSweepInterval = time.Minute
+
+// NewMemoryRateLimitStore creates an empty MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{counters: make(map[string]*rateLimitCounter), lastSweep: time.Now()}
+}
+
+// IncrRateLimit adds one to the counter for key.
+func (s *MemoryRateLimitStore) IncrRateLimit(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) > rateLimitSweepInterval {
+		for k, c := range s.counters {
+			if !now.Before(c.resetAt) {
+				delete(s.counters, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.resetAt) {
+		c = &rateLimitCounter{resetAt: now.Add(window)}
+		s.counters[key] = c
+	}
+	c.count++
+	return c.count, c.resetAt, nil
+}
+
+// GetRateLimit returns the current count for key.
+func (s *MemoryRateLimitStore) GetRateLimit(ctx context.Context, key string) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.counters[key]
+	if !ok || !time.Now().Before(c.resetAt) {
+		return 0, time.Time{}, nil
+	}
+	return c.count, c.resetAt, nil
+}
+
+// ResetRateLimit clears the counter for key.
+func (s *MemoryRateLimitStore) ResetRateLimit(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.counters, key)
+	return nil
+}
+
+// IncrRateLimit adds one to the counter for key, starting a new window if the
+// previous one has ended.
+func (p *PGStore) IncrRateLimit(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	var count int
+	var resetAt time.Time
+	query := `
+		INSERT INTO rate_limits (key, count, reset_at)
+		VALUES ($1, 1, now() + make_interval(secs => $2))
+		ON CONFLICT (key) DO UPDATE SET
+			count = CASE WHEN rate_limits.reset_at <= now() THEN 1 ELSE rate_limits.count + 1 END,
+			reset_at = CASE WHEN rate_limits.reset_at <= now() THEN EXCLUDED.reset_at ELSE rate_limits.reset_at END
+		RETURNING count, reset_at`
+
+	if err := p.db.QueryRowContext(ctx, query, key, window.Seconds()).Scan(&count, &resetAt); err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to update rate limit: %w", err)
+	}
+	return count, resetAt, nil
+}
+
+// GetRateLimit returns the current count for key.
+func (p *PGStore) GetRateLimit(ctx context.Context, key string) (int, time.Time, error) {
+	var count int
+	var resetAt time.Time
+	query := `SELECT count, reset_at FROM rate_limits WHERE key = $1 AND reset_at > now()`
+
+	err := p.db.QueryRowContext(ctx, query, key).Scan(&count, &resetAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, time.Time{}, nil
+	}
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to read rate limit: %w", err)
+	}
+	return count, resetAt, nil
+}
+
+// ResetRateLimit clears the counter for key.
+func (p *PGStore) ResetRateLimit(ctx context.Context, key string) error {
+	if _, err := p.db.ExecContext(ctx, `DELETE FROM rate_limits WHERE key = $1`, key); err != nil {
+		return fmt.Errorf("failed to reset rate limit: %w", err)
+	}
+	return nil
+}
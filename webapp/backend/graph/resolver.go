@@ -4,84 +4,213 @@ package graph
 
 import (
 	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
+	"log"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/ShavaizKhan/DailyNewsPodcast/webapp-backend/graph/model"
 	"golang.org/x/crypto/bcrypt"
-
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
 // Context key for storing user ID
 type contextKey string
 
-const userCtxKey contextKey = "user_id"
+const (
+	userCtxKey     contextKey = "user_id"
+	sessionCtxKey  contextKey = "session_id"
+	clientIPCtxKey contextKey = "client_ip"
+	roleCtxKey     contextKey = "role"
+
+	apiKeyScopesCtxKey contextKey = "api_key_scopes"
+)
 
 // NewContextWithUserID creates a new context with the user ID.
 func NewContextWithUserID(ctx context.Context, userID string) context.Context {
 	return context.WithValue(ctx, userCtxKey, userID)
 }
 
+// newContextWithSessionID creates a new context with the login session ID.
+func newContextWithSessionID(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionCtxKey, sessionID)
+}
+
+// newContextWithRole creates a new context with the user's role.
+func newContextWithRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, roleCtxKey, role)
+}
+
+// roleFromContext retrieves the user's role from the context.
+func roleFromContext(ctx context.Context) string {
+	role, _ := ctx.Value(roleCtxKey).(string)
+	return role
+}
+
+// NewContextWithClientIP creates a new context with the client's IP address.
+func NewContextWithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPCtxKey, ip)
+}
+
+// clientIPFromContext retrieves the client's IP address from the context.
+func clientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPCtxKey).(string)
+	return ip
+}
+
 // GetUserIDFromContext retrieves the user ID from the context.
 func GetUserIDFromContext(ctx context.Context) (string, error) {
 	userID, ok := ctx.Value(userCtxKey).(string)
 	if !ok {
-		return "", errors.New("user not authenticated")
+		return "", ErrUnauthenticated
 	}
 	return userID, nil
 }
 
 // Resolver root
 type Resolver struct {
-	Store UserStore
+	JWT        *JWTManager
+	Store      UserStore
+	Sessions   SessionStore
+	Catalogue  EpisodeStore
+	Feeds      FeedStore
+	Tokens     AccountTokenStore
+	Identities IdentityStore
+	Keys       APIKeyStore
+	Exports    DataExportStore
+	Jobs       EpisodeJobStore
+	Listens    ListenStore
+	Feedback   FeedbackStore
+	Progress   ProgressBroker
+	Queue      GenerationQueueStore
+	Storage    Storage
+	Mailer     Mailer
+	Generator  Generator
+	PublicURL  string
+
+	// OIDCProviders are the external identity providers users can sign in with.
+	OIDCProviders []*OIDCProvider
+
+	// AppURL is the base URL of the web app, used for links in emails.
+	AppURL string
+
+	// AudioURLSecret signs the audio streaming URLs handed out to clients.
+	AudioURLSecret []byte
 }
 
 // Mutation resolver
-func (r *mutationResolver) Signup(ctx context.Context, email string, password string) (string, error) {
+func (r *mutationResolver) Signup(ctx context.Context, email string, password string) (*model.AuthPayload, error) {
+	email = normalizeEmail(email)
+	if err := validateEmail(ctx, email); err != nil {
+		return nil, err
+	}
+	if err := validatePassword(ctx, "password", password, email); err != nil {
+		return nil, err
+	}
+
 	// Hash the password before saving it
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		return "", fmt.Errorf("could not hash password: %w", err)
+		return nil, fmt.Errorf("could not hash password: %w", err)
 	}
 
 	// Create a new user in the database.
-	user, err := r.Store.CreateUser(ctx, email, string(hashedPassword), "us", "general")
+	user, err := r.Store.CreateUser(ctx, email, string(hashedPassword))
+	if errors.Is(err, ErrEmailTaken) {
+		return nil, newError(ctx, CodeEmailTaken, "an account with this email already exists", map[string]any{"field": "email"})
+	}
 	if err != nil {
-		return "", fmt.Errorf("signup failed: %w", err)
+		return nil, fmt.Errorf("signup failed: %w", err)
+	}
+
+	// Signup shouldn't fail just because the mail server is unavailable; the
+	// user can ask for another verification email later.
+	if err := r.sendVerificationEmail(ctx, user); err != nil {
+		log.Printf("could not send verification email to user %s: %v", user.ID, err)
 	}
 
-	// Generate and return a JWT for the new user
-	return GenerateJWT(user.ID)
+	// Start a session and return its tokens
+	return r.startSession(ctx, user.ID)
 }
 
-func (r *mutationResolver) Login(ctx context.Context, email string, password string) (string, error) {
-	user, err := r.Store.GetUserByEmail(ctx, email)
+func (r *mutationResolver) Login(ctx context.Context, email string, password string) (*model.AuthPayload, error) {
+	user, err := r.Store.GetUserByEmail(ctx, normalizeEmail(email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, newError(ctx, CodeInvalidCredentials, "invalid credentials", nil)
+	}
 	if err != nil {
-		return "", errors.New("invalid credentials")
+		return nil, err
 	}
 
 	// Compare the provided password with the stored hashed password
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
 	if err != nil {
-		return "", errors.New("invalid credentials")
+		return nil, newError(ctx, CodeInvalidCredentials, "invalid credentials", nil)
 	}
 
-	// Generate and return a new JWT
-	return GenerateJWT(user.ID)
+	// Start a session and return its tokens
+	return r.startSession(ctx, user.ID)
 }
 
-func (r *mutationResolver) UpdatePreferences(ctx context.Context, country string, topic string) (*model.Preferences, error) {
+func (r *mutationResolver) RefreshToken(ctx context.Context, refreshToken string) (*model.AuthPayload, error) {
+	newRefreshToken, err := newToken()
+	if err != nil {
+		return nil, fmt.Errorf("could not generate refresh token: %w", err)
+	}
+
+	session, err := r.Sessions.RotateRefreshToken(ctx, hashToken(refreshToken), hashToken(newRefreshToken), time.Now().Add(r.JWT.RefreshTTL()))
+	if errors.Is(err, ErrRefreshTokenInvalid) || errors.Is(err, ErrRefreshTokenReused) {
+		return nil, newError(ctx, CodeInvalidRefreshToken, err.Error(), nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return r.authPayload(session, newRefreshToken)
+}
+
+func (r *mutationResolver) Logout(ctx context.Context) (bool, error) {
+	sessionID, ok := ctx.Value(sessionCtxKey).(string)
+	if !ok {
+		return false, ErrUnauthenticated
+	}
+
+	if err := r.Sessions.RevokeSession(ctx, sessionID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) LogoutAllSessions(ctx context.Context) (bool, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return false, err // User not authenticated
+	}
+
+	if err := r.Sessions.RevokeUserSessions(ctx, userID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) UpdatePreferences(ctx context.Context, topics []*model.TopicWeightInput, countries []*model.CountryWeightInput, language *string) (*model.Preferences, error) {
 	userID, err := GetUserIDFromContext(ctx)
 	if err != nil {
 		return nil, err // User not authenticated
 	}
 
+	topicWeights, countryWeights, err := validatePreferences(ctx, topics, countries, language)
+	if err != nil {
+		return nil, err
+	}
+
 	// Update the preferences in the database.
-	preferences, err := r.Store.UpdateUserPreferences(ctx, userID, country, topic)
+	preferences, err := r.Store.UpdateUserPreferences(ctx, userID, topicWeights, countryWeights, language)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update preferences: %w", err)
 	}
@@ -89,6 +218,466 @@ func (r *mutationResolver) UpdatePreferences(ctx context.Context, country string
 	return preferences, nil
 }
 
+func (r *mutationResolver) UpdateArticleFilters(ctx context.Context, filters model.ArticleFiltersInput) (*model.ArticleFilters, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err // User not authenticated
+	}
+
+	normalized, err := validateArticleFilters(ctx, filters)
+	if err != nil {
+		return nil, err
+	}
+	return r.Store.UpdateUserFilters(ctx, userID, normalized)
+}
+
+func (r *mutationResolver) UpdatePlaybackPosition(ctx context.Context, episodeID string, seconds int32, listenedSeconds *int32) (*model.Episode, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err // User not authenticated
+	}
+	if seconds < 0 {
+		return nil, fieldError(ctx, "seconds", "seconds must not be negative")
+	}
+	if listenedSeconds != nil && (*listenedSeconds < 0 || *listenedSeconds > maxListenedSeconds) {
+		return nil, fieldError(ctx, "listenedSeconds", fmt.Sprintf("listenedSeconds must be between 0 and %d", maxListenedSeconds))
+	}
+
+	episode, err := r.playableEpisode(ctx, userID, episodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	listen, err := r.Listens.UpdatePlaybackPosition(ctx, userID, episode.ID, int(seconds))
+	if err != nil {
+		return nil, err
+	}
+	if listenedSeconds != nil && *listenedSeconds > 0 {
+		if err := r.Listens.RecordPlay(ctx, userID, episode.ID, int(*listenedSeconds), listen.PositionSeconds); err != nil {
+			return nil, err
+		}
+	}
+	return episode.toModel(), nil
+}
+
+func (r *mutationResolver) RateEpisode(ctx context.Context, id string, stars int32, comment *string) (*model.EpisodeRating, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err // User not authenticated
+	}
+	if stars < minRatingStars || stars > maxRatingStars {
+		return nil, fieldError(ctx, "stars", fmt.Sprintf("stars must be between %d and %d", minRatingStars, maxRatingStars))
+	}
+	if comment != nil {
+		trimmed := strings.TrimSpace(*comment)
+		if utf8.RuneCountInString(trimmed) > maxRatingCommentLength {
+			return nil, fieldError(ctx, "comment", fmt.Sprintf("comment must be at most %d characters", maxRatingCommentLength))
+		}
+		comment = optionalString(trimmed)
+	}
+
+	episode, err := r.playableEpisode(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rating, err := r.Feedback.RateEpisode(ctx, userID, episode.ID, int(stars), comment)
+	if err != nil {
+		return nil, err
+	}
+	return rating.toModel(), nil
+}
+
+func (r *mutationResolver) RateStory(ctx context.Context, episodeID string, storyIndex int32, thumbs model.Thumbs) (*model.StoryRating, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err // User not authenticated
+	}
+
+	episode, err := r.playableEpisode(ctx, userID, episodeID)
+	if err != nil {
+		return nil, err
+	}
+	if storyIndex < 0 || int(storyIndex) >= len(episode.Manifest.Articles) {
+		return nil, fieldError(ctx, "storyIndex", fmt.Sprintf("episode '%s' has no story %d", episode.ID, storyIndex))
+	}
+
+	rating, err := r.Feedback.RateStory(ctx, newStoryRating(userID, episode, int(storyIndex), strings.ToLower(string(thumbs))))
+	if err != nil {
+		return nil, err
+	}
+	return rating.toModel(), nil
+}
+
+// playableEpisode fetches a published episode the user can see, for
+// mutations about their listening.
+func (r *mutationResolver) playableEpisode(ctx context.Context, userID, id string) (*Episode, error) {
+	episode, err := r.Catalogue.GetEpisodeByID(ctx, id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if episode == nil || episode.Status != EpisodeStatusPublished || !episode.visibleTo(userID) {
+		return nil, newError(ctx, CodeEpisodeNotFound, "episode not found", nil)
+	}
+	return episode, nil
+}
+
+func (r *mutationResolver) UpdateDeliverySchedule(ctx context.Context, timezone string, deliveryTime *string) (*model.Preferences, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err // User not authenticated
+	}
+
+	deliveryTime, err = validateDeliverySchedule(ctx, timezone, deliveryTime)
+	if err != nil {
+		return nil, err
+	}
+	return r.Store.UpdateDeliverySchedule(ctx, userID, timezone, deliveryTime)
+}
+
+func (r *mutationResolver) RotateFeedToken(ctx context.Context) (string, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return "", err // User not authenticated
+	}
+
+	user, err := r.Store.GetUserByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if !user.EmailVerified {
+		return "", newError(ctx, CodeEmailNotVerified, "verify your email address before creating a feed", nil)
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return "", fmt.Errorf("could not generate feed token: %w", err)
+	}
+
+	// Only the hash is stored, so the URL can't be shown again later.
+	if err := r.Feeds.CreateFeedToken(ctx, userID, hashToken(token)); err != nil {
+		return "", err
+	}
+
+	return r.feedURL(token), nil
+}
+
+func (r *mutationResolver) RevokeFeedToken(ctx context.Context) (bool, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return false, err // User not authenticated
+	}
+
+	if err := r.Feeds.RevokeFeedTokens(ctx, userID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) RequestPasswordReset(ctx context.Context, email string) (bool, error) {
+	// Always report success so the mutation can't be used to find out which
+	// emails have accounts.
+	user, err := r.Store.GetUserByEmail(ctx, normalizeEmail(email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Failures are only logged, as an error would reveal that the account
+	// exists.
+	token, err := r.issueAccountToken(ctx, user.ID, AccountTokenPasswordReset, passwordResetTokenTTL)
+	if err != nil {
+		log.Printf("could not issue password reset token for user %s: %v", user.ID, err)
+		return true, nil
+	}
+
+	err = r.Mailer.Send(ctx, Message{
+		To:      user.Email,
+		Subject: "Reset your Daily News Podcast password",
+		Body: fmt.Sprintf("Someone asked to reset the password for your Daily News Podcast account.\n\n"+
+			"Follow this link within the next hour to choose a new password:\n%s\n\n"+
+			"If this wasn't you, you can ignore this email.", r.appLink("/reset-password", token)),
+	})
+	if err != nil {
+		log.Printf("could not send password reset email to user %s: %v", user.ID, err)
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) ResetPassword(ctx context.Context, token string, newPassword string) (bool, error) {
+	// Check the password first so a rejected one doesn't use up the token.
+	if err := validatePassword(ctx, "newPassword", newPassword, ""); err != nil {
+		return false, err
+	}
+
+	userID, err := r.Tokens.ConsumeAccountToken(ctx, AccountTokenPasswordReset, hashToken(token))
+	if errors.Is(err, ErrAccountTokenInvalid) {
+		return false, newError(ctx, CodeInvalidToken, err.Error(), nil)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return false, fmt.Errorf("could not hash password: %w", err)
+	}
+	if err := r.Store.UpdateUserPassword(ctx, userID, string(hashedPassword)); err != nil {
+		return false, err
+	}
+
+	// Sign out everywhere in case the old password was compromised.
+	if err := r.Sessions.RevokeUserSessions(ctx, userID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) SendVerificationEmail(ctx context.Context) (bool, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return false, err // User not authenticated
+	}
+
+	user, err := r.Store.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if user.EmailVerified {
+		return true, nil
+	}
+
+	if err := r.sendVerificationEmail(ctx, user); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) VerifyEmail(ctx context.Context, token string) (bool, error) {
+	userID, err := r.Tokens.ConsumeAccountToken(ctx, AccountTokenEmailVerification, hashToken(token))
+	if errors.Is(err, ErrAccountTokenInvalid) {
+		return false, newError(ctx, CodeInvalidToken, err.Error(), nil)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := r.Store.MarkEmailVerified(ctx, userID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) DeleteAccount(ctx context.Context, password *string) (bool, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return false, err // User not authenticated
+	}
+
+	user, err := r.Store.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	// GetUserByID leaves out the password hash.
+	user, err = r.Store.GetUserByEmail(ctx, user.Email)
+	if err != nil {
+		return false, err
+	}
+
+	// Accounts created through an external provider may have no password.
+	if user.PasswordHash != "" {
+		if password == nil || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(*password)) != nil {
+			return false, newError(ctx, CodeInvalidCredentials, "password is incorrect", map[string]any{"field": "password"})
+		}
+	}
+
+	// The audio and transcripts of personal episodes are removed first, while
+	// the episodes still point to them. If removing the account then fails,
+	// deleting them again on the next attempt is harmless.
+	keys, err := r.Catalogue.ListUserEpisodeKeys(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, key := range keys {
+		if err := r.Storage.Delete(ctx, key); err != nil {
+			return false, err
+		}
+	}
+
+	// Sessions, refresh tokens, feed links, API keys and linked identities are
+	// removed with the user, which invalidates every outstanding token.
+	if err := r.Store.DeleteUser(ctx, userID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) CreateAPIKey(ctx context.Context, name string, scopes []model.APIKeyScope) (*model.CreatedAPIKey, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err // User not authenticated
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return nil, fieldError(ctx, "name", "name must be between 1 and 100 characters long")
+	}
+	if len(scopes) == 0 {
+		return nil, fieldError(ctx, "scopes", "at least one scope is required")
+	}
+
+	stored := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !slices.Contains(stored, strings.ToLower(string(scope))) {
+			stored = append(stored, strings.ToLower(string(scope)))
+		}
+	}
+
+	secret, err := newToken()
+	if err != nil {
+		return nil, fmt.Errorf("could not generate API key: %w", err)
+	}
+	key := apiKeyPrefix + secret
+
+	// Only the hash is stored, so the key can't be shown again later.
+	apiKey, err := r.Keys.CreateAPIKey(ctx, userID, name, key[:apiKeyDisplayLength], hashToken(key), stored)
+	if errors.Is(err, ErrTooManyAPIKeys) {
+		return nil, newError(ctx, CodeBadUserInput, fmt.Sprintf("you can have at most %d API keys", maxAPIKeysPerUser), nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.CreatedAPIKey{APIKey: apiKey.toModel(), Key: key}, nil
+}
+
+func (r *mutationResolver) RevokeAPIKey(ctx context.Context, id string) (bool, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return false, err // User not authenticated
+	}
+
+	err = r.Keys.RevokeAPIKey(ctx, userID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, fieldError(ctx, "id", fmt.Sprintf("API key '%s' not found", id))
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) GenerateMyEpisode(ctx context.Context) (*model.EpisodeJob, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err // User not authenticated
+	}
+	if r.Generator == nil {
+		return nil, newError(ctx, CodeUnavailable, "episode generation is not configured", nil)
+	}
+
+	user, err := r.Store.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	demoted, err := r.Feedback.DemotedSources(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	today := localToday(user.Preferences.Timezone)
+	job, created, err := r.Jobs.CreateEpisodeJob(ctx, userID, newGenerationRequest(user.Preferences, demoted, today))
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		if err := r.Generator.GenerateJob(ctx, job.ID); err != nil {
+			if failErr := r.Jobs.FailEpisodeJob(ctx, job.ID, "generation could not be started"); failErr != nil {
+				log.Printf("could not mark episode job %s as failed: %v", job.ID, failErr)
+			}
+			return nil, err
+		}
+	}
+
+	return r.episodeJobModel(ctx, job)
+}
+
+func (r *mutationResolver) TriggerGeneration(ctx context.Context) (bool, error) {
+	if r.Generator == nil {
+		return false, newError(ctx, CodeUnavailable, "episode generation is not configured", nil)
+	}
+
+	if err := r.Generator.Generate(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) RetryGeneration(ctx context.Context, id string) (*model.QueuedGeneration, error) {
+	if r.Queue == nil {
+		return nil, newError(ctx, CodeUnavailable, "the generation queue is not configured", nil)
+	}
+
+	queued, err := r.Queue.RetryQueuedGeneration(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, newError(ctx, CodeGenerationNotFound, "no dead-lettered generation request with this ID", nil)
+	}
+	if errors.Is(err, ErrJobNotRetryable) {
+		return nil, newError(ctx, CodeBadUserInput, "the episode job for this request was published, deleted or replaced by a newer one", nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return queued.toModel(), nil
+}
+
+func (r *mutationResolver) SetUserRole(ctx context.Context, userID string, role model.Role) (*model.User, error) {
+	currentUserID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err // User not authenticated
+	}
+
+	// Stop admins from locking themselves out.
+	if userID == currentUserID {
+		return nil, fieldError(ctx, "userId", "you can't change your own role")
+	}
+
+	user, err := r.Store.SetUserRole(ctx, userID, storedRole(role))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fieldError(ctx, "userId", fmt.Sprintf("user '%s' not found", userID))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Access tokens carry the role, so sign the user out to apply the change.
+	if err := r.Sessions.RevokeUserSessions(ctx, userID); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *mutationResolver) DeleteUser(ctx context.Context, userID string) (bool, error) {
+	currentUserID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return false, err // User not authenticated
+	}
+
+	if userID == currentUserID {
+		return false, fieldError(ctx, "userId", "you can't delete your own account here")
+	}
+
+	err = r.Store.DeleteUser(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, fieldError(ctx, "userId", fmt.Sprintf("user '%s' not found", userID))
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // Query resolver
 func (r *queryResolver) Me(ctx context.Context) (*model.User, error) {
 	userID, err := GetUserIDFromContext(ctx)
@@ -107,34 +696,498 @@ func (r *queryResolver) Me(ctx context.Context) (*model.User, error) {
 }
 
 func (r *queryResolver) Podcast(ctx context.Context, date *string) (*model.Podcast, error) {
-	// Default to today
+	// Default to today where the user is
 	if date == nil {
-		now := time.Now().Format("2006-01-02")
+		today, err := r.userToday(ctx)
+		if err != nil {
+			return nil, err
+		}
+		now := today.Format(time.DateOnly)
 		date = &now
 	}
 
-	bucket := os.Getenv("S3_BUCKET")
-	key := fmt.Sprintf("general_podcast_%s.mp3", *date)
-
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion("us-east-1"))
+	day, err := parseDate(ctx, *date)
 	if err != nil {
 		return nil, err
 	}
 
-	client := s3.NewFromConfig(cfg)
-	presignClient := s3.NewPresignClient(client)
+	// Only hand out a URL for episodes the Lambda has actually published.
+	episode, err := r.Catalogue.GetEpisodeByDate(ctx, day, sharedEpisodeCountry, sharedEpisodeTopic)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if episode == nil || episode.Status != EpisodeStatusPublished {
+		return nil, r.episodeNotFound(ctx, day)
+	}
+
+	return &model.Podcast{
+		Date:    *date,
+		URL:     r.audioURL(episode.ID),
+		Episode: episode.toModel(),
+	}, nil
+}
+
+// parseDate parses a YYYY-MM-DD date argument.
+func parseDate(ctx context.Context, date string) (time.Time, error) {
+	day, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return time.Time{}, newError(ctx, CodeInvalidDate, fmt.Sprintf("date '%s' must be formatted as YYYY-MM-DD", date), nil)
+	}
+	return day, nil
+}
+
+// episodeNotFound builds the EPISODE_NOT_FOUND error, hinting at the closest
+// date that does have an episode.
+func (r *queryResolver) episodeNotFound(ctx context.Context, day time.Time) error {
+	nearest, err := r.Catalogue.NearestEpisodeDate(ctx, day, sharedEpisodeCountry, sharedEpisodeTopic)
+	if err != nil {
+		return err
+	}
+
+	extensions := map[string]any{}
+	if nearest != nil {
+		extensions["nearestDate"] = nearest.Format(time.DateOnly)
+	}
+	return newError(ctx, CodeEpisodeNotFound, fmt.Sprintf("no episode available for %s", day.Format(time.DateOnly)), extensions)
+}
+
+// Page sizes for the episodes connection.
+const (
+	defaultEpisodesPageSize = 20
+	maxEpisodesPageSize     = 100
+)
+
+func (r *queryResolver) Episodes(ctx context.Context, first *int32, after *string, country *string, topic *string, from *string, to *string) (*model.EpisodeConnection, error) {
+	limit := defaultEpisodesPageSize
+	if first != nil {
+		if *first < 1 || *first > maxEpisodesPageSize {
+			return nil, newError(ctx, CodeBadUserInput, fmt.Sprintf("first must be between 1 and %d", maxEpisodesPageSize), nil)
+		}
+		limit = int(*first)
+	}
 
-	url, err := presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
-		Bucket: &bucket,
-		Key:    &key,
-	}, s3.WithPresignExpires(15*time.Minute))
+	var cursor *EpisodeCursor
+	if after != nil {
+		var err error
+		cursor, err = parseEpisodeCursor(*after)
+		if err != nil {
+			return nil, newError(ctx, CodeInvalidCursor, fmt.Sprintf("cursor '%s' is not valid", *after), nil)
+		}
+	}
 
+	// Signed in users also see their personal episodes.
+	filter := EpisodeFilter{}
+	if userID, err := GetUserIDFromContext(ctx); err == nil {
+		filter.Owner = userID
+	}
+	if country != nil {
+		filter.Country = *country
+	}
+	if topic != nil {
+		filter.Topic = *topic
+	}
+	if from != nil {
+		day, err := parseDate(ctx, *from)
+		if err != nil {
+			return nil, err
+		}
+		filter.From = &day
+	}
+	if to != nil {
+		day, err := parseDate(ctx, *to)
+		if err != nil {
+			return nil, err
+		}
+		filter.To = &day
+	}
+
+	// Fetch one extra row to find out whether there is another page.
+	episodes, err := r.Catalogue.ListEpisodes(ctx, filter, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	total, err := r.Catalogue.CountEpisodes(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	return &model.Podcast{
-		Date: *date,
-		URL:  url.URL,
+	hasNextPage := len(episodes) > limit
+	if hasNextPage {
+		episodes = episodes[:limit]
+	}
+
+	connection := &model.EpisodeConnection{
+		Edges:      make([]*model.EpisodeEdge, len(episodes)),
+		PageInfo:   &model.PageInfo{HasNextPage: hasNextPage},
+		TotalCount: int32(total),
+	}
+	for i, episode := range episodes {
+		connection.Edges[i] = &model.EpisodeEdge{
+			Cursor: EpisodeCursor{Date: episode.Date, ID: episode.ID}.Encode(),
+			Node:   episode.toModel(),
+		}
+	}
+	if len(connection.Edges) > 0 {
+		connection.PageInfo.EndCursor = &connection.Edges[len(connection.Edges)-1].Cursor
+	}
+
+	return connection, nil
+}
+
+func (r *queryResolver) OidcProviders(ctx context.Context) ([]*model.OIDCProvider, error) {
+	providers := make([]*model.OIDCProvider, len(r.OIDCProviders))
+	for i, provider := range r.OIDCProviders {
+		providers[i] = &model.OIDCProvider{
+			ID:       provider.ID,
+			Name:     provider.Name,
+			LoginURL: r.PublicURL + "/auth/oidc/" + provider.ID + "/start",
+		}
+	}
+	return providers, nil
+}
+
+func (r *queryResolver) SupportedOptions(ctx context.Context) (*model.SupportedOptions, error) {
+	return &model.SupportedOptions{
+		Countries: supportedCountries,
+		Topics:    supportedTopics,
+		Languages: supportedLanguages,
 	}, nil
 }
+
+func (r *queryResolver) MyEpisodeJob(ctx context.Context, id string) (*model.EpisodeJob, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err // User not authenticated
+	}
+
+	job, err := r.Jobs.GetEpisodeJob(ctx, userID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, newError(ctx, CodeJobNotFound, "episode job not found", nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.episodeJobModel(ctx, job)
+}
+
+func (r *queryResolver) ContinueListening(ctx context.Context, first *int32) ([]*model.Episode, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err // User not authenticated
+	}
+
+	limit := defaultContinueListeningSize
+	if first != nil {
+		if *first < 1 || *first > maxContinueListeningSize {
+			return nil, newError(ctx, CodeBadUserInput, fmt.Sprintf("first must be between 1 and %d", maxContinueListeningSize), nil)
+		}
+		limit = int(*first)
+	}
+
+	episodes, err := r.Listens.ListInProgressEpisodes(ctx, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*model.Episode, len(episodes))
+	for i, episode := range episodes {
+		result[i] = episode.toModel()
+	}
+	return result, nil
+}
+
+func (r *queryResolver) ListeningHistory(ctx context.Context, first *int32, after *string) (*model.PlayEventConnection, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err // User not authenticated
+	}
+
+	limit := defaultHistoryPageSize
+	if first != nil {
+		if *first < 1 || *first > maxHistoryPageSize {
+			return nil, newError(ctx, CodeBadUserInput, fmt.Sprintf("first must be between 1 and %d", maxHistoryPageSize), nil)
+		}
+		limit = int(*first)
+	}
+
+	var cursor *PlayEventCursor
+	if after != nil {
+		cursor, err = parsePlayEventCursor(*after)
+		if err != nil {
+			return nil, newError(ctx, CodeInvalidCursor, fmt.Sprintf("cursor '%s' is not valid", *after), nil)
+		}
+	}
+
+	// Fetch one extra row to find out whether there is another page.
+	events, err := r.Listens.ListPlayEvents(ctx, userID, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	hasNextPage := len(events) > limit
+	if hasNextPage {
+		events = events[:limit]
+	}
+
+	connection := &model.PlayEventConnection{
+		Edges:    make([]*model.PlayEventEdge, len(events)),
+		PageInfo: &model.PageInfo{HasNextPage: hasNextPage},
+	}
+	for i, event := range events {
+		connection.Edges[i] = &model.PlayEventEdge{
+			Cursor: PlayEventCursor{StartedAt: event.StartedAt, ID: event.ID}.Encode(),
+			Node:   event.toModel(),
+		}
+	}
+	if len(connection.Edges) > 0 {
+		connection.PageInfo.EndCursor = &connection.Edges[len(connection.Edges)-1].Cursor
+	}
+
+	return connection, nil
+}
+
+func (r *queryResolver) MyStats(ctx context.Context) (*model.ListeningStats, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err // User not authenticated
+	}
+	return r.Listens.GetListeningStats(ctx, userID)
+}
+
+// Subscription resolver
+func (r *subscriptionResolver) EpisodeProgress(ctx context.Context, jobID string) (<-chan *model.EpisodeJob, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err // User not authenticated
+	}
+	if r.Progress == nil {
+		return nil, newError(ctx, CodeUnavailable, "episode progress is not available", nil)
+	}
+
+	// Subscribe before loading the job so no change in between is missed.
+	subCtx, cancel := context.WithCancel(ctx)
+	updates, err := r.Progress.Subscribe(subCtx, jobID)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	job, err := r.Jobs.GetEpisodeJob(ctx, userID, jobID)
+	if errors.Is(err, sql.ErrNoRows) {
+		cancel()
+		return nil, newError(ctx, CodeJobNotFound, "episode job not found", nil)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	progress := make(chan *model.EpisodeJob, 1)
+	go func() {
+		defer cancel()
+		defer close(progress)
+
+		for {
+			result, err := r.episodeJobModel(subCtx, job)
+			if err != nil {
+				log.Printf("could not load episode job %s: %v", job.ID, err)
+				return
+			}
+			select {
+			case progress <- result:
+			case <-subCtx.Done():
+				return
+			}
+			if job.finished() {
+				return
+			}
+
+			// Skip changes that have already been sent.
+			sent := job.UpdatedAt
+			for !job.UpdatedAt.After(sent) {
+				var ok bool
+				if job, ok = <-updates; !ok {
+					return
+				}
+			}
+		}
+	}()
+	return progress, nil
+}
+
+func (r *queryResolver) APIKeys(ctx context.Context) ([]*model.APIKey, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err // User not authenticated
+	}
+
+	keys, err := r.Keys.ListAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKeys := make([]*model.APIKey, len(keys))
+	for i, key := range keys {
+		apiKeys[i] = key.toModel()
+	}
+	return apiKeys, nil
+}
+
+func (r *queryResolver) ExportMyData(ctx context.Context) (string, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return "", err // User not authenticated
+	}
+
+	export, err := r.Exports.ExportUserData(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(export)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode data export: %w", err)
+	}
+	return string(data), nil
+}
+
+func (r *queryResolver) GenerationQueue(ctx context.Context, status *model.GenerationStatus, first *int32) ([]*model.QueuedGeneration, error) {
+	if r.Queue == nil {
+		return nil, newError(ctx, CodeUnavailable, "the generation queue is not configured", nil)
+	}
+
+	limit := defaultQueuePageSize
+	if first != nil {
+		if *first < 1 || *first > maxQueuePageSize {
+			return nil, newError(ctx, CodeBadUserInput, fmt.Sprintf("first must be between 1 and %d", maxQueuePageSize), nil)
+		}
+		limit = int(*first)
+	}
+
+	var filter string
+	if status != nil {
+		filter = strings.ToLower(string(*status))
+	}
+
+	queued, err := r.Queue.ListQueuedGenerations(ctx, filter, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*model.QueuedGeneration, len(queued))
+	for i, q := range queued {
+		result[i] = q.toModel()
+	}
+	return result, nil
+}
+
+func (r *queryResolver) FeedbackSummary(ctx context.Context, since *string) (*model.FeedbackSummary, error) {
+	var from time.Time
+	if since != nil {
+		day, err := parseDate(ctx, *since)
+		if err != nil {
+			return nil, err
+		}
+		from = day
+	}
+	return r.Feedback.SummarizeFeedback(ctx, from)
+}
+
+// Page sizes for the users connection.
+const (
+	defaultUsersPageSize = 20
+	maxUsersPageSize     = 100
+)
+
+func (r *queryResolver) Users(ctx context.Context, first *int32, after *string, search *string) (*model.UserConnection, error) {
+	limit := defaultUsersPageSize
+	if first != nil {
+		if *first < 1 || *first > maxUsersPageSize {
+			return nil, newError(ctx, CodeBadUserInput, fmt.Sprintf("first must be between 1 and %d", maxUsersPageSize), nil)
+		}
+		limit = int(*first)
+	}
+
+	// Users are ordered by email, so the cursor is the last email seen.
+	var afterEmail string
+	if after != nil {
+		email, err := base64.RawURLEncoding.DecodeString(*after)
+		if err != nil {
+			return nil, newError(ctx, CodeInvalidCursor, fmt.Sprintf("cursor '%s' is not valid", *after), nil)
+		}
+		afterEmail = string(email)
+	}
+
+	var term string
+	if search != nil {
+		term = normalizeEmail(*search)
+	}
+
+	// Fetch one extra row to find out whether there is another page.
+	users, err := r.Store.ListUsers(ctx, term, afterEmail, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	total, err := r.Store.CountUsers(ctx, term)
+	if err != nil {
+		return nil, err
+	}
+
+	hasNextPage := len(users) > limit
+	if hasNextPage {
+		users = users[:limit]
+	}
+
+	connection := &model.UserConnection{
+		Edges:      make([]*model.UserEdge, len(users)),
+		PageInfo:   &model.PageInfo{HasNextPage: hasNextPage},
+		TotalCount: int32(total),
+	}
+	for i, user := range users {
+		connection.Edges[i] = &model.UserEdge{
+			Cursor: base64.RawURLEncoding.EncodeToString([]byte(user.Email)),
+			Node:   user,
+		}
+	}
+	if len(connection.Edges) > 0 {
+		connection.PageInfo.EndCursor = &connection.Edges[len(connection.Edges)-1].Cursor
+	}
+
+	return connection, nil
+}
+
+// Episode resolver
+func (r *episodeResolver) AudioURL(ctx context.Context, obj *model.Episode) (string, error) {
+	return r.audioURL(obj.ID), nil
+}
+
+func (r *episodeResolver) PlaybackPosition(ctx context.Context, obj *model.Episode) (*model.PlaybackPosition, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, nil // Nobody is signed in
+	}
+
+	listen, err := r.getListen(ctx, userID, obj.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return listen.toModel(), nil
+}
+
+func (r *episodeResolver) TranscriptURL(ctx context.Context, obj *model.Episode) (*string, error) {
+	if obj.TranscriptKey == "" {
+		return nil, nil
+	}
+
+	url, err := r.Storage.PresignURL(ctx, obj.TranscriptKey, presignedURLExpiry)
+	if errors.Is(err, ErrPresignUnsupported) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &url, nil
+}
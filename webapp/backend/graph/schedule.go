@@ -0,0 +1,246 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ShavaizKhan/DailyNewsPodcast/webapp-backend/graph/model"
+)
+
+// deliveryTimeLayout is the format of a delivery time, in the user's
+// timezone.
+const deliveryTimeLayout = "15:04"
+
+// DeliveryStore defines the interface for scheduled episode deliveries.
+type DeliveryStore interface {
+	// ClaimDueDeliveries records and returns the users whose delivery time,
+	// in their timezone, is within lead of now and who haven't had a delivery
+	// for that local date yet. Users whose delivery time has already passed
+	// today are delivered to late rather than skipped.
+	ClaimDueDeliveries(ctx context.Context, lead time.Duration) ([]*DueDelivery, error)
+	// ReleaseDelivery undoes a claim whose episode could not be queued, so it
+	// is claimed again.
+	ReleaseDelivery(ctx context.Context, userID string, date time.Time) error
+}
+
+// DueDelivery is a user whose episode should be queued for a local date.
+type DueDelivery struct {
+	UserID string
+	Date   time.Time
+}
+
+// validateDeliverySchedule checks an updateDeliverySchedule request,
+// returning the delivery time normalized to HH:MM.
+func validateDeliverySchedule(ctx context.Context, timezone string, deliveryTime *string) (*string, error) {
+	if _, err := loadTimezone(timezone); err != nil {
+		return nil, fieldError(ctx, "timezone", fmt.Sprintf("'%s' is not a known timezone", timezone))
+	}
+	if deliveryTime == nil {
+		return nil, nil
+	}
+
+	t, err := time.Parse(deliveryTimeLayout, *deliveryTime)
+	if err != nil {
+		return nil, fieldError(ctx, "deliveryTime", "delivery time must be formatted as HH:MM")
+	}
+	normalized := t.Format(deliveryTimeLayout)
+	return &normalized, nil
+}
+
+// loadTimezone loads an IANA timezone. The server's local zone is not
+// accepted, as it means nothing to the user.
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return time.LoadLocation(name)
+}
+
+// userToday returns today's date in the signed in user's timezone, or in UTC
+// when nobody is signed in.
+func (r *Resolver) userToday(ctx context.Context) (time.Time, error) {
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return localToday(defaultTimezone), nil
+	}
+	user, err := r.Store.GetUserByID(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return localToday(user.Preferences.Timezone), nil
+}
+
+// localToday returns today's date in a timezone, as midnight UTC. Unknown
+// timezones fall back to UTC.
+func localToday(timezone string) time.Time {
+	location, err := loadTimezone(timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	now := time.Now().In(location)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// UpdateDeliverySchedule sets a user's timezone and delivery time. A nil
+// delivery time stops scheduled delivery.
+func (p *PGStore) UpdateDeliverySchedule(ctx context.Context, id, timezone string, deliveryTime *string) (*model.Preferences, error) {
+	query := `UPDATE users SET timezone = $1, delivery_time = $2::time WHERE id = $3`
+
+	if _, err := p.db.ExecContext(ctx, query, timezone, deliveryTime, id); err != nil {
+		return nil, fmt.Errorf("failed to update delivery schedule: %w", err)
+	}
+	return p.userPreferences(ctx, id)
+}
+
+// ClaimDueDeliveries records and returns the users due a delivery within lead.
+func (p *PGStore) ClaimDueDeliveries(ctx context.Context, lead time.Duration) ([]*DueDelivery, error) {
+	query := `
+		WITH due AS (
+			SELECT users.id, upcoming.local::date AS delivery_date
+			FROM users, LATERAL (
+				SELECT (now() AT TIME ZONE users.timezone) + make_interval(secs => $1) AS local
+			) upcoming
+			WHERE users.delivery_time IS NOT NULL
+				AND upcoming.local::time >= users.delivery_time
+				AND (users.last_delivery_date IS NULL OR users.last_delivery_date < upcoming.local::date)
+		)
+		UPDATE users
+		SET last_delivery_date = due.delivery_date
+		FROM due
+		WHERE users.id = due.id
+		RETURNING users.id, due.delivery_date`
+
+	rows, err := p.db.QueryContext(ctx, query, lead.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim due deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var due []*DueDelivery
+	for rows.Next() {
+		var delivery DueDelivery
+		if err := rows.Scan(&delivery.UserID, &delivery.Date); err != nil {
+			return nil, fmt.Errorf("failed to scan due delivery: %w", err)
+		}
+		due = append(due, &delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim due deliveries: %w", err)
+	}
+	return due, nil
+}
+
+// ReleaseDelivery undoes a claim made by ClaimDueDeliveries.
+func (p *PGStore) ReleaseDelivery(ctx context.Context, userID string, date time.Time) error {
+	query := `UPDATE users SET last_delivery_date = NULL WHERE id = $1 AND last_delivery_date = $2`
+
+	if _, err := p.db.ExecContext(ctx, query, userID, date.Format(time.DateOnly)); err != nil {
+		return fmt.Errorf("failed to release delivery: %w", err)
+	}
+	return nil
+}
+
+// SchedulerLock names the advisory lock held by the instance running the
+// delivery scheduler.
+const SchedulerLock = "delivery_scheduler"
+
+const (
+	// defaultScheduleInterval is how often the scheduler looks for due
+	// deliveries.
+	defaultScheduleInterval = time.Minute
+
+	// defaultDeliveryLead is how long before a user's delivery time their
+	// episode is queued, leaving time for it to be generated.
+	defaultDeliveryLead = 30 * time.Minute
+)
+
+// Scheduler queues the generation of users' personal episodes ahead of their
+// delivery time.
+type Scheduler struct {
+	Store      UserStore
+	Jobs       EpisodeJobStore
+	Deliveries DeliveryStore
+	Feedback   FeedbackStore
+	Generator  Generator
+	Interval   time.Duration
+	Lead       time.Duration
+}
+
+// Run queues due deliveries until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	interval := s.Interval
+	if interval == 0 {
+		interval = defaultScheduleInterval
+	}
+	lead := s.Lead
+	if lead == 0 {
+		lead = defaultDeliveryLead
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		due, err := s.Deliveries.ClaimDueDeliveries(ctx, lead)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("could not claim due deliveries: %v", err)
+		}
+		for _, delivery := range due {
+			if err := s.deliver(ctx, delivery); err != nil {
+				log.Printf("could not queue delivery to user %s for %s: %v", delivery.UserID, delivery.Date.Format(time.DateOnly), err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliver queues a job for a due delivery. The claim is released if the job
+// couldn't be created or started, so the next run tries again.
+func (s *Scheduler) deliver(ctx context.Context, delivery *DueDelivery) error {
+	job, created, err := s.createJob(ctx, delivery)
+	if err != nil {
+		return s.release(ctx, delivery, err)
+	}
+	if !created {
+		log.Printf("user %s already has episode job %s in progress, skipping delivery", delivery.UserID, job.ID)
+		return nil
+	}
+
+	if err := s.Generator.GenerateJob(ctx, job.ID); err != nil {
+		if failErr := s.Jobs.FailEpisodeJob(ctx, job.ID, "generation could not be started"); failErr != nil {
+			log.Printf("could not mark episode job %s as failed: %v", job.ID, failErr)
+		}
+		return s.release(ctx, delivery, err)
+	}
+	return nil
+}
+
+// release gives up the claim on a delivery that failed with err.
+func (s *Scheduler) release(ctx context.Context, delivery *DueDelivery, err error) error {
+	if releaseErr := s.Deliveries.ReleaseDelivery(ctx, delivery.UserID, delivery.Date); releaseErr != nil {
+		err = errors.Join(err, releaseErr)
+	}
+	return err
+}
+
+// createJob creates the job for a delivery from the user's current
+// preferences and feedback.
+func (s *Scheduler) createJob(ctx context.Context, delivery *DueDelivery) (*EpisodeJob, bool, error) {
+	user, err := s.Store.GetUserByID(ctx, delivery.UserID)
+	if err != nil {
+		return nil, false, err
+	}
+	demoted, err := s.Feedback.DemotedSources(ctx, delivery.UserID)
+	if err != nil {
+		return nil, false, err
+	}
+	request := newGenerationRequest(user.Preferences, demoted, delivery.Date)
+	return s.Jobs.CreateEpisodeJob(ctx, delivery.UserID, request)
+}
@@ -0,0 +1,202 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ShavaizKhan/DailyNewsPodcast/webapp-backend/graph/model"
+)
+
+// Errors returned when a refresh token can't be exchanged.
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+)
+
+// SessionStore defines the interface for login sessions and their refresh tokens.
+type SessionStore interface {
+	CreateSession(ctx context.Context, userID, refreshTokenHash string, expiresAt time.Time) (*Session, error)
+	RotateRefreshToken(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (*Session, error)
+	IsSessionActive(ctx context.Context, sessionID string) (bool, error)
+	RevokeSession(ctx context.Context, sessionID string) error
+	RevokeUserSessions(ctx context.Context, userID string) error
+}
+
+// Session is a login session, identified in access tokens by its ID. Role is
+// the user's current role, copied into each access token.
+type Session struct {
+	ID     string
+	UserID string
+	Role   string
+}
+
+// Authenticate verifies an access token and returns a context carrying its
+// user and session. Tokens for revoked sessions are rejected.
+func (r *Resolver) Authenticate(ctx context.Context, accessToken string) (context.Context, error) {
+	claims, err := r.JWT.ParseJWT(accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := r.Sessions.IsSessionActive(ctx, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, errors.New("session has been revoked")
+	}
+
+	ctx = NewContextWithUserID(ctx, claims.Subject)
+	ctx = newContextWithRole(ctx, claims.Role)
+	return newContextWithSessionID(ctx, claims.SessionID), nil
+}
+
+// startSession creates a new login session for a user and returns its tokens.
+func (r *Resolver) startSession(ctx context.Context, userID string) (*model.AuthPayload, error) {
+	refreshToken, err := newToken()
+	if err != nil {
+		return nil, fmt.Errorf("could not generate refresh token: %w", err)
+	}
+
+	session, err := r.Sessions.CreateSession(ctx, userID, hashToken(refreshToken), time.Now().Add(r.JWT.RefreshTTL()))
+	if err != nil {
+		return nil, err
+	}
+	return r.authPayload(session, refreshToken)
+}
+
+// authPayload issues an access token for a session alongside its refresh token.
+func (r *Resolver) authPayload(session *Session, refreshToken string) (*model.AuthPayload, error) {
+	accessToken, expiresAt, err := r.JWT.GenerateJWT(session.UserID, session.ID, session.Role)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate access token: %w", err)
+	}
+
+	return &model.AuthPayload{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    expiresAt.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// CreateSession starts a session for a user with its first refresh token.
+func (p *PGStore) CreateSession(ctx context.Context, userID, refreshTokenHash string, expiresAt time.Time) (*Session, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	session := Session{UserID: userID}
+	query := `
+		INSERT INTO sessions (user_id) VALUES ($1)
+		RETURNING id, (SELECT role FROM users WHERE id = $1)`
+	if err := tx.QueryRowContext(ctx, query, userID).Scan(&session.ID, &session.Role); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	insert := `INSERT INTO refresh_tokens (session_id, token_hash, expires_at) VALUES ($1, $2, $3)`
+	if _, err := tx.ExecContext(ctx, insert, session.ID, refreshTokenHash, expiresAt); err != nil {
+		return nil, fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	return &session, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one in the same
+// session. Presenting a token that was already exchanged means it has leaked,
+// so the whole session is revoked and ErrRefreshTokenReused is returned.
+func (p *PGStore) RotateRefreshToken(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (*Session, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var session Session
+	var tokenID string
+	var tokenExpiresAt time.Time
+	var usedAt, revokedAt sql.NullTime
+	query := `
+		SELECT rt.id, rt.expires_at, rt.used_at, s.id, s.user_id, u.role, s.revoked_at
+		FROM refresh_tokens rt
+		JOIN sessions s ON s.id = rt.session_id
+		JOIN users u ON u.id = s.user_id
+		WHERE rt.token_hash = $1
+		FOR UPDATE OF rt, s`
+
+	err = tx.QueryRowContext(ctx, query, oldHash).Scan(&tokenID, &tokenExpiresAt, &usedAt, &session.ID, &session.UserID, &session.Role, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up refresh token: %w", err)
+	}
+
+	if revokedAt.Valid || time.Now().After(tokenExpiresAt) {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	if usedAt.Valid {
+		revoke := `UPDATE sessions SET revoked_at = now() WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, revoke, session.ID); err != nil {
+			return nil, fmt.Errorf("failed to revoke session: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to revoke session: %w", err)
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	markUsed := `UPDATE refresh_tokens SET used_at = now() WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, markUsed, tokenID); err != nil {
+		return nil, fmt.Errorf("failed to use refresh token: %w", err)
+	}
+
+	insert := `INSERT INTO refresh_tokens (session_id, token_hash, expires_at) VALUES ($1, $2, $3)`
+	if _, err := tx.ExecContext(ctx, insert, session.ID, newHash, expiresAt); err != nil {
+		return nil, fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	return &session, nil
+}
+
+// IsSessionActive reports whether a session exists and has not been revoked.
+func (p *PGStore) IsSessionActive(ctx context.Context, sessionID string) (bool, error) {
+	var active bool
+	query := `SELECT EXISTS (SELECT 1 FROM sessions WHERE id = $1 AND revoked_at IS NULL)`
+
+	if err := p.db.QueryRowContext(ctx, query, sessionID).Scan(&active); err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+	return active, nil
+}
+
+// RevokeSession revokes a single session.
+func (p *PGStore) RevokeSession(ctx context.Context, sessionID string) error {
+	query := `UPDATE sessions SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`
+
+	if _, err := p.db.ExecContext(ctx, query, sessionID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes every session of a user.
+func (p *PGStore) RevokeUserSessions(ctx context.Context, userID string) error {
+	query := `UPDATE sessions SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`
+
+	if _, err := p.db.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// How long presigned download URLs stay valid.
+const presignedURLExpiry = 15 * time.Minute
+
+// ErrPresignUnsupported is returned by storage backends that cannot hand out
+// direct download URLs.
+var ErrPresignUnsupported = errors.New("storage backend does not support presigned URLs")
+
+// Storage gives access to the episode audio written by the Lambda.
+type Storage interface {
+	PresignURL(ctx context.Context, key string, expires time.Duration) (string, error)
+	Open(ctx context.Context, key string) (*Object, error)
+	// Delete removes the object stored under key. Deleting a key that
+	// doesn't exist is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Object is a stored file opened for reading. Content must be closed.
+type Object struct {
+	Content io.ReadSeekCloser
+	Size    int64
+	ModTime time.Time
+	ETag    string
+}
+
+// S3Storage implements Storage on top of an S3 bucket.
+type S3Storage struct {
+	bucket  string
+	client  *s3.Client
+	presign *s3.PresignClient
+}
+
+// NewS3Storage creates an S3Storage for the given bucket.
+func NewS3Storage(ctx context.Context, bucket string) (*S3Storage, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("S3_BUCKET environment variable is not set")
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion("us-east-1"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS config: %w", err)
+	}
+
+	client := s3.NewFromConfig(cfg)
+	return &S3Storage{
+		bucket:  bucket,
+		client:  client,
+		presign: s3.NewPresignClient(client),
+	}, nil
+}
+
+// PresignURL returns a temporary download URL for key.
+func (s *S3Storage) PresignURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	req, err := s.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign '%s': %w", key, err)
+	}
+	return req.URL, nil
+}
+
+// Open looks up key and returns a reader that fetches byte ranges from S3 on demand.
+func (s *S3Storage) Open(ctx context.Context, key string) (*Object, error) {
+	head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open '%s': %w", key, err)
+	}
+
+	reader := &s3Reader{ctx: ctx, storage: s, key: key}
+	if head.ContentLength != nil {
+		reader.size = *head.ContentLength
+	}
+	if head.ETag != nil {
+		reader.etag = *head.ETag
+	}
+
+	var modTime time.Time
+	if head.LastModified != nil {
+		modTime = *head.LastModified
+	}
+
+	return &Object{
+		Content: reader,
+		Size:    reader.size,
+		ModTime: modTime,
+		ETag:    reader.etag,
+	}, nil
+}
+
+// Delete removes key from the bucket.
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete '%s': %w", key, err)
+	}
+	return nil
+}
+
+// s3Reader is an io.ReadSeekCloser over an S3 object. Each seek starts a new
+// ranged GET, so only the bytes that are actually read get transferred.
+type s3Reader struct {
+	ctx     context.Context
+	storage *S3Storage
+	key     string
+	etag    string
+	size    int64
+	offset  int64
+	body    io.ReadCloser
+}
+
+func (r *s3Reader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		byteRange := fmt.Sprintf("bytes=%d-", r.offset)
+		input := &s3.GetObjectInput{
+			Bucket: &r.storage.bucket,
+			Key:    &r.key,
+			Range:  &byteRange,
+		}
+		// Make sure every range comes from the same version of the object.
+		if r.etag != "" {
+			input.IfMatch = &r.etag
+		}
+
+		out, err := r.storage.client.GetObject(r.ctx, input)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read '%s': %w", r.key, err)
+		}
+		r.body = out.Body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *s3Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.New("seek before start of object")
+	}
+
+	if offset != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *s3Reader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	return r.body.Close()
+}
+
+// LocalStorage implements Storage on a directory of the local filesystem.
+type LocalStorage struct {
+	dir string
+}
+
+// NewLocalStorage creates a LocalStorage rooted at dir.
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("LOCAL_STORAGE_DIR environment variable is not set")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create storage directory: %w", err)
+	}
+	return &LocalStorage{dir: dir}, nil
+}
+
+// PresignURL is not supported by the local filesystem; files are served
+// through the backend instead.
+func (s *LocalStorage) PresignURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	return "", ErrPresignUnsupported
+}
+
+// Open opens the file stored under key.
+func (s *LocalStorage) Open(ctx context.Context, key string) (*Object, error) {
+	file, err := os.Open(s.path(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open '%s': %w", key, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open '%s': %w", key, err)
+	}
+
+	return &Object{
+		Content: file,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		ETag:    fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()),
+	}, nil
+}
+
+// Delete removes the file stored under key.
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete '%s': %w", key, err)
+	}
+	return nil
+}
+
+// path returns where key is stored, cleaned against the root so keys can't
+// escape the storage directory.
+func (s *LocalStorage) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(filepath.Clean("/"+key)))
+}
@@ -0,0 +1,67 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode"
+)
+
+// Password policy. bcrypt ignores everything past 72 bytes, so longer
+// passwords are rejected rather than silently truncated.
+const (
+	minPasswordLength = 8
+	maxPasswordBytes  = 72
+)
+
+// normalizeEmail trims and lower-cases an email address so the same mailbox
+// always maps to the same account.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// validateEmail checks that a normalized email is a bare address such as
+// "name@example.com".
+func validateEmail(ctx context.Context, email string) error {
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return fieldError(ctx, "email", "email address is not valid")
+	}
+
+	// Require a domain like "example.com" rather than a bare host name.
+	domain := email[strings.LastIndex(email, "@")+1:]
+	if !strings.Contains(strings.Trim(domain, "."), ".") {
+		return fieldError(ctx, "email", "email address is not valid")
+	}
+	return nil
+}
+
+// validatePassword enforces the password policy for new passwords.
+func validatePassword(ctx context.Context, field, password, email string) error {
+	if len([]rune(password)) < minPasswordLength {
+		return fieldError(ctx, field, fmt.Sprintf("password must be at least %d characters long", minPasswordLength))
+	}
+	if len(password) > maxPasswordBytes {
+		return fieldError(ctx, field, fmt.Sprintf("password must be at most %d bytes long", maxPasswordBytes))
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		hasLetter = hasLetter || unicode.IsLetter(r)
+		hasDigit = hasDigit || unicode.IsDigit(r)
+	}
+	if !hasLetter || !hasDigit {
+		return fieldError(ctx, field, "password must contain both letters and numbers")
+	}
+
+	if email != "" && strings.EqualFold(password, email) {
+		return fieldError(ctx, field, "password must not be the same as your email address")
+	}
+	return nil
+}
+
+// fieldError builds a BAD_USER_INPUT error pointing at the offending argument.
+func fieldError(ctx context.Context, field, message string) error {
+	return newError(ctx, CodeBadUserInput, message, map[string]any{"field": field})
+}
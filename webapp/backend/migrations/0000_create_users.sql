@@ -0,0 +1,9 @@
+-- Baseline users table the API was originally deployed against. Later
+-- migrations reference users (id).
+CREATE TABLE IF NOT EXISTS users (
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    email         TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    country       TEXT NOT NULL DEFAULT 'us',
+    topic         TEXT NOT NULL DEFAULT 'general'
+);
@@ -0,0 +1,18 @@
+-- Catalogue of generated podcast episodes. Rows are written by the generation
+-- Lambda and read by the GraphQL API.
+CREATE TABLE IF NOT EXISTS episodes (
+    id               UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    episode_date     DATE NOT NULL,
+    country          TEXT NOT NULL,
+    topic            TEXT NOT NULL,
+    storage_key      TEXT NOT NULL,
+    duration_seconds INTEGER NOT NULL DEFAULT 0,
+    status           TEXT NOT NULL DEFAULT 'generating'
+                     CHECK (status IN ('generating', 'published', 'failed')),
+    manifest         JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (episode_date, country, topic)
+);
+
+CREATE INDEX IF NOT EXISTS episodes_status_date_idx ON episodes (status, episode_date DESC);
@@ -0,0 +1,11 @@
+-- Private podcast feed tokens. Only a SHA-256 hash of each token is stored;
+-- a user has at most one active token at a time.
+CREATE TABLE IF NOT EXISTS feed_tokens (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS feed_tokens_active_user_idx ON feed_tokens (user_id) WHERE revoked_at IS NULL;
@@ -0,0 +1,4 @@
+-- Emails are normalized to lower case, so look them up and keep them unique
+-- case-insensitively. This fails if existing accounts differ only by case;
+-- merge or rename those first.
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_lower_idx ON users (lower(email));
@@ -0,0 +1,7 @@
+-- Shared rate limit counters, used when RATE_LIMIT_BACKEND=postgres. Each row
+-- is a fixed window counter; expired rows are restarted on their next hit.
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limits (
+    key      TEXT PRIMARY KEY,
+    count    INTEGER NOT NULL,
+    reset_at TIMESTAMPTZ NOT NULL
+);
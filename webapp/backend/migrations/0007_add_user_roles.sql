@@ -0,0 +1,3 @@
+-- Roles for access control. Promote the first admin by hand:
+--   UPDATE users SET role = 'admin' WHERE email = 'you@example.com';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin'));
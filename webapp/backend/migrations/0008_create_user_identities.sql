@@ -0,0 +1,24 @@
+-- External OIDC identities linked to users. Users who only sign in through a
+-- provider have an empty password_hash.
+CREATE TABLE IF NOT EXISTS user_identities (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    provider   TEXT NOT NULL,
+    subject    TEXT NOT NULL,
+    email      TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS user_identities_user_idx ON user_identities (user_id);
+
+-- Sign ins that have been sent to a provider, keyed by a SHA-256 hash of the
+-- OAuth state. Rows are deleted when the provider redirects back.
+CREATE TABLE IF NOT EXISTS oidc_login_requests (
+    state_hash    TEXT PRIMARY KEY,
+    provider      TEXT NOT NULL,
+    nonce         TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at    TIMESTAMPTZ NOT NULL
+);
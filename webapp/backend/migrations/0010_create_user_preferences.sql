@@ -0,0 +1,24 @@
+-- Weighted topic and country preferences, replacing the single country and
+-- topic columns on users. Weights are relative to the user's other choices.
+CREATE TABLE IF NOT EXISTS user_topics (
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    topic   TEXT NOT NULL,
+    weight  INT NOT NULL DEFAULT 1 CHECK (weight BETWEEN 1 AND 10),
+    PRIMARY KEY (user_id, topic)
+);
+
+CREATE TABLE IF NOT EXISTS user_countries (
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    country TEXT NOT NULL,
+    weight  INT NOT NULL DEFAULT 1 CHECK (weight BETWEEN 1 AND 10),
+    PRIMARY KEY (user_id, country)
+);
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'users' AND column_name = 'topic') THEN
+        INSERT INTO user_topics (user_id, topic) SELECT id, topic FROM users ON CONFLICT DO NOTHING;
+        INSERT INTO user_countries (user_id, country) SELECT id, country FROM users ON CONFLICT DO NOTHING;
+        ALTER TABLE users DROP COLUMN topic, DROP COLUMN country;
+    END IF;
+END $$;
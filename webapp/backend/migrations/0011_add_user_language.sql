@@ -0,0 +1,3 @@
+-- Language episodes are written and read in, as a code from the supported
+-- options registry.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS language TEXT NOT NULL DEFAULT 'en';
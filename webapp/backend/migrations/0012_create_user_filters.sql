@@ -0,0 +1,8 @@
+-- Per-user article filters, stored alongside the weighted preferences.
+-- Keywords are lower-case; sources are bare domains such as "example.com".
+CREATE TABLE IF NOT EXISTS user_filters (
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind    TEXT NOT NULL CHECK (kind IN ('include_keyword', 'exclude_keyword', 'blocked_source', 'preferred_source')),
+    value   TEXT NOT NULL,
+    PRIMARY KEY (user_id, kind, value)
+);
@@ -0,0 +1,37 @@
+-- Personal episodes belong to the user they were generated for. Shared
+-- episodes have no owner and stay unique per date, country and topic.
+ALTER TABLE episodes ADD COLUMN IF NOT EXISTS user_id UUID REFERENCES users (id) ON DELETE CASCADE;
+ALTER TABLE episodes DROP CONSTRAINT IF EXISTS episodes_episode_date_country_topic_key;
+CREATE UNIQUE INDEX IF NOT EXISTS episodes_shared_key ON episodes (episode_date, country, topic) WHERE user_id IS NULL;
+CREATE INDEX IF NOT EXISTS episodes_user_idx ON episodes (user_id, episode_date DESC) WHERE user_id IS NOT NULL;
+
+-- On-demand generation of personal episodes. The request holds the
+-- preferences the episode is generated from, as sent to the Lambda.
+CREATE TABLE IF NOT EXISTS episode_jobs (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    status     TEXT NOT NULL DEFAULT 'queued'
+               CHECK (status IN ('queued', 'fetching', 'scripting', 'synthesizing', 'published', 'failed')),
+    request    JSONB NOT NULL,
+    episode_id UUID REFERENCES episodes (id) ON DELETE SET NULL,
+    error      TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS episode_jobs_user_idx ON episode_jobs (user_id, created_at DESC);
+-- A user has at most one job in progress.
+CREATE UNIQUE INDEX IF NOT EXISTS episode_jobs_active_idx ON episode_jobs (user_id)
+    WHERE status NOT IN ('published', 'failed');
+
+-- Scripts and audio for individual stories, keyed by a hash of the article
+-- URL, so a story that appears in several episodes is only generated once.
+CREATE TABLE IF NOT EXISTS segments (
+    article_hash TEXT NOT NULL,
+    language     TEXT NOT NULL,
+    model        TEXT NOT NULL,
+    script       TEXT NOT NULL,
+    audio_key    TEXT NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (article_hash, language)
+);
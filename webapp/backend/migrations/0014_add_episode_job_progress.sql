@@ -0,0 +1,5 @@
+-- How far along a job is, from 0 to 100, reported as it moves through its
+-- stages. The backend watches recently updated jobs to stream their progress.
+ALTER TABLE episode_jobs ADD COLUMN IF NOT EXISTS progress SMALLINT NOT NULL DEFAULT 0
+    CHECK (progress BETWEEN 0 AND 100);
+CREATE INDEX IF NOT EXISTS episode_jobs_updated_idx ON episode_jobs (updated_at);
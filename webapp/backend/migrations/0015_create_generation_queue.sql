@@ -0,0 +1,22 @@
+-- Durable queue of generation requests, worked through by the worker process
+-- as an alternative to invoking the Lambda. The payload is the event the
+-- Lambda accepts. Failed requests are retried at run_at with a growing delay
+-- and dead-lettered once they run out of attempts.
+CREATE TABLE IF NOT EXISTS generation_queue (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    payload      JSONB NOT NULL,
+    status       TEXT NOT NULL DEFAULT 'pending'
+                 CHECK (status IN ('pending', 'running', 'done', 'dead')),
+    attempts     INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL DEFAULT 5,
+    run_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    locked_by    TEXT,
+    locked_at    TIMESTAMPTZ,
+    last_error   TEXT,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS generation_queue_pending_idx ON generation_queue (run_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS generation_queue_running_idx ON generation_queue (locked_at) WHERE status = 'running';
+CREATE INDEX IF NOT EXISTS generation_queue_status_idx ON generation_queue (status, updated_at DESC);
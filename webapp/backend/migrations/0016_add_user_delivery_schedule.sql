@@ -0,0 +1,8 @@
+-- When a user wants their episode. The scheduler queues generation ahead of
+-- delivery_time in the user's IANA timezone, and records the local date it
+-- last did so in last_delivery_date. Users without a delivery time are not
+-- scheduled.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'UTC';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS delivery_time TIME;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_delivery_date DATE;
+CREATE INDEX IF NOT EXISTS users_delivery_time_idx ON users (delivery_time) WHERE delivery_time IS NOT NULL;
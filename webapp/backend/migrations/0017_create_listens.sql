@@ -0,0 +1,15 @@
+-- Where each user is in each episode they have played. completed_at is set
+-- the first time they get close enough to the end, and kept when they play
+-- the episode again.
+CREATE TABLE IF NOT EXISTS listens (
+    user_id          UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    episode_id       UUID NOT NULL REFERENCES episodes (id) ON DELETE CASCADE,
+    position_seconds INT NOT NULL DEFAULT 0 CHECK (position_seconds >= 0),
+    completed_at     TIMESTAMPTZ,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, episode_id)
+);
+
+CREATE INDEX IF NOT EXISTS listens_in_progress_idx ON listens (user_id, updated_at DESC) WHERE completed_at IS NULL;
+CREATE INDEX IF NOT EXISTS listens_episode_idx ON listens (episode_id);
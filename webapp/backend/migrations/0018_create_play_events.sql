@@ -0,0 +1,16 @@
+-- Listening sessions. Playback reports for the same episode are merged into
+-- the latest session while they keep coming, adding up the seconds of audio
+-- actually played. Listening history and statistics are computed from them.
+CREATE TABLE IF NOT EXISTS play_events (
+    id               UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id          UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    episode_id       UUID NOT NULL REFERENCES episodes (id) ON DELETE CASCADE,
+    listened_seconds INT NOT NULL DEFAULT 0 CHECK (listened_seconds >= 0),
+    position_seconds INT NOT NULL DEFAULT 0 CHECK (position_seconds >= 0),
+    started_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ended_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS play_events_user_started_idx ON play_events (user_id, started_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS play_events_user_episode_idx ON play_events (user_id, episode_id, ended_at DESC);
+CREATE INDEX IF NOT EXISTS play_events_episode_idx ON play_events (episode_id);
@@ -0,0 +1,34 @@
+-- Listener feedback. Episodes get one star rating per user and stories a
+-- thumbs up or down. Story ratings keep the article's outlet, domain and
+-- topic and the episode's model as they were when rated, so feedback can be
+-- summarized without reading manifests. Thumbs-down domains are ranked last
+-- in the user's later episodes.
+CREATE TABLE IF NOT EXISTS episode_ratings (
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    episode_id UUID NOT NULL REFERENCES episodes (id) ON DELETE CASCADE,
+    stars      SMALLINT NOT NULL CHECK (stars BETWEEN 1 AND 5),
+    comment    TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, episode_id)
+);
+
+CREATE TABLE IF NOT EXISTS story_ratings (
+    user_id     UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    episode_id  UUID NOT NULL REFERENCES episodes (id) ON DELETE CASCADE,
+    story_index INT NOT NULL CHECK (story_index >= 0),
+    thumbs      TEXT NOT NULL CHECK (thumbs IN ('up', 'down')),
+    source      TEXT NOT NULL DEFAULT '',
+    domain      TEXT NOT NULL DEFAULT '',
+    topic       TEXT NOT NULL DEFAULT '',
+    model       TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, episode_id, story_index)
+);
+
+CREATE INDEX IF NOT EXISTS episode_ratings_updated_idx ON episode_ratings (updated_at);
+CREATE INDEX IF NOT EXISTS episode_ratings_episode_idx ON episode_ratings (episode_id);
+CREATE INDEX IF NOT EXISTS story_ratings_updated_idx ON story_ratings (updated_at);
+CREATE INDEX IF NOT EXISTS story_ratings_episode_idx ON story_ratings (episode_id);
+CREATE INDEX IF NOT EXISTS story_ratings_user_domain_idx ON story_ratings (user_id, domain, updated_at);
@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/handler/extension"
@@ -13,6 +17,7 @@ import (
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/ShavaizKhan/DailyNewsPodcast/webapp-backend/graph"
+	"github.com/gorilla/websocket"
 	"github.com/vektah/gqlparser/v2/ast"
 )
 
@@ -24,7 +29,7 @@ func CORSMiddleware(next http.Handler) http.Handler {
 		// Set headers to allow cross-origin requests from your React app.
 		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:8081")
 		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
 
 		// Handle preflight OPTIONS requests.
 		if r.Method == "OPTIONS" {
@@ -37,9 +42,21 @@ func CORSMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// The AuthMiddleware function injects the user ID into the request context.
-func AuthMiddleware(next http.Handler) http.Handler {
+// The AuthMiddleware function injects the user and session IDs into the request context.
+// Scripts can authenticate with a personal API key in the X-API-Key header instead.
+func AuthMiddleware(resolver *graph.Resolver, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
+			ctx, err := resolver.AuthenticateAPIKey(r.Context(), apiKey)
+			if err != nil {
+				http.Error(w, "invalid API key", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
 			next.ServeHTTP(w, r)
@@ -52,43 +69,254 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		userID, err := graph.ParseJWT(bearerToken[1])
+		ctx, err := resolver.Authenticate(r.Context(), bearerToken[1])
 		if err != nil {
 			http.Error(w, "invalid token", http.StatusUnauthorized)
 			return
 		}
 
-		ctx := graph.NewContextWithUserID(r.Context(), userID)
 		r = r.WithContext(ctx)
 
 		next.ServeHTTP(w, r)
 	})
 }
 
+// WebsocketInit authenticates a WebSocket connection from the Authorization or
+// X-API-Key entry of its connection_init payload, like AuthMiddleware does for
+// HTTP requests.
+func WebsocketInit(resolver *graph.Resolver) transport.WebsocketInitFunc {
+	return func(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+		if apiKey := payload.GetString("X-API-Key"); apiKey != "" {
+			ctx, err := resolver.AuthenticateAPIKey(ctx, apiKey)
+			if err != nil {
+				return nil, nil, errors.New("invalid API key")
+			}
+			return ctx, &payload, nil
+		}
+
+		authHeader := payload.Authorization()
+		if authHeader == "" {
+			return ctx, &payload, nil
+		}
+
+		bearerToken := strings.Split(authHeader, " ")
+		if len(bearerToken) != 2 || strings.ToLower(bearerToken[0]) != "bearer" {
+			return nil, nil, errors.New("invalid token format")
+		}
+
+		ctx, err := resolver.Authenticate(ctx, bearerToken[1])
+		if err != nil {
+			return nil, nil, errors.New("invalid token")
+		}
+		return ctx, &payload, nil
+	}
+}
+
+// ClientIPMiddleware records the client's IP address in the request context.
+// X-Forwarded-For is only trusted when running behind a proxy, in which case
+// the last entry is the address the proxy saw.
+func ClientIPMiddleware(trustProxy bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+
+		if forwarded := r.Header.Get("X-Forwarded-For"); trustProxy && forwarded != "" {
+			hops := strings.Split(forwarded, ",")
+			ip = strings.TrimSpace(hops[len(hops)-1])
+		}
+
+		next.ServeHTTP(w, r.WithContext(graph.NewContextWithClientIP(r.Context(), ip)))
+	})
+}
+
 func main() {
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = defaultPort
 	}
 
+	// Base URL used in links handed out to clients, such as feed URLs.
+	publicURL := strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+	if publicURL == "" {
+		publicURL = "http://localhost:" + port
+	}
+
+	// Base URL of the web app, used for links in emails.
+	appURL := strings.TrimSuffix(os.Getenv("APP_URL"), "/")
+	if appURL == "" {
+		appURL = "http://localhost:8081"
+	}
+
 	ctx := context.Background()
 
+	jwtConfig, err := graph.LoadJWTConfig()
+	if err != nil {
+		log.Fatalf("failed to load JWT configuration: %v", err)
+	}
+	tokens, err := graph.NewJWTManager(jwtConfig)
+	if err != nil {
+		log.Fatalf("invalid JWT configuration: %v", err)
+	}
+
+	oidcProviders, err := graph.LoadOIDCProviders()
+	if err != nil {
+		log.Fatalf("invalid OIDC configuration: %v", err)
+	}
+
 	pgStore, err := graph.NewPGStore(ctx)
 	if err != nil {
 		log.Fatalf("failed to connect to database: %v", err)
 	}
 	defer pgStore.Close()
 
+	var storage graph.Storage
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "s3":
+		storage, err = graph.NewS3Storage(ctx, os.Getenv("S3_BUCKET"))
+	case "local":
+		storage, err = graph.NewLocalStorage(os.Getenv("LOCAL_STORAGE_DIR"))
+	default:
+		log.Fatalf("unknown STORAGE_BACKEND %q", backend)
+	}
+	if err != nil {
+		log.Fatalf("failed to configure storage: %v", err)
+	}
+
+	var mailer graph.Mailer
+	switch backend := os.Getenv("MAIL_BACKEND"); backend {
+	case "", "log":
+		mailer, err = graph.NewLogMailer(os.Getenv("MAIL_DIR"))
+	case "smtp":
+		mailer, err = graph.NewSMTPMailer(os.Getenv("SMTP_HOST"), os.Getenv("SMTP_PORT"), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), os.Getenv("MAIL_FROM"))
+	default:
+		log.Fatalf("unknown MAIL_BACKEND %q", backend)
+	}
+	if err != nil {
+		log.Fatalf("failed to configure mail: %v", err)
+	}
+
+	// Episodes are generated by invoking the Lambda, when its name is
+	// configured, or by the worker process through the generation queue.
+	var generator graph.Generator
+	switch backend := os.Getenv("GENERATOR_BACKEND"); backend {
+	case "", "lambda":
+		if function := os.Getenv("GENERATOR_FUNCTION"); function != "" {
+			generator, err = graph.NewLambdaGenerator(ctx, function)
+			if err != nil {
+				log.Fatalf("failed to configure generator: %v", err)
+			}
+		}
+	case "queue":
+		generator = graph.NewQueueGenerator(pgStore)
+	default:
+		log.Fatalf("unknown GENERATOR_BACKEND %q", backend)
+	}
+
+	audioURLSecret := []byte(os.Getenv("AUDIO_URL_SECRET"))
+	if len(audioURLSecret) == 0 {
+		log.Println("AUDIO_URL_SECRET is not set, audio URLs will stop working when the server restarts")
+		audioURLSecret = make([]byte, 32)
+		if _, err := rand.Read(audioURLSecret); err != nil {
+			log.Fatalf("failed to generate audio URL secret: %v", err)
+		}
+	}
+
+	// Episode job progress is delivered to subscriptions in memory unless
+	// several instances share it through Postgres. Either way the generator's
+	// changes are picked up by polling the database, which only one instance
+	// does when progress is shared.
+	var progress graph.ProgressBroker
+	watcher := &graph.JobWatcher{Jobs: pgStore}
+	switch backend := os.Getenv("PUBSUB_BACKEND"); backend {
+	case "", "memory":
+		progress = graph.NewMemoryProgressBroker()
+		watcher.Broker = progress
+		go watcher.Run(ctx)
+	case "postgres":
+		broker := graph.NewPGProgressBroker(pgStore)
+		go broker.Listen(ctx)
+		progress = broker
+		watcher.Broker = progress
+		go pgStore.RunExclusively(ctx, graph.JobWatcherLock, watcher.Run)
+	default:
+		log.Fatalf("unknown PUBSUB_BACKEND %q", backend)
+	}
+
+	// Scheduled deliveries are queued by one instance at a time, and only
+	// when episodes can be generated.
+	if generator != nil {
+		scheduler := &graph.Scheduler{Store: pgStore, Jobs: pgStore, Deliveries: pgStore, Feedback: pgStore, Generator: generator}
+		go pgStore.RunExclusively(ctx, graph.SchedulerLock, scheduler.Run)
+	} else {
+		log.Println("no generator is configured, scheduled deliveries are disabled")
+	}
+
 	resolver := &graph.Resolver{
-		Store: pgStore,
+		JWT:        tokens,
+		Store:      pgStore,
+		Sessions:   pgStore,
+		Catalogue:  pgStore,
+		Feeds:      pgStore,
+		Tokens:     pgStore,
+		Identities: pgStore,
+		Keys:       pgStore,
+		Exports:    pgStore,
+		Jobs:       pgStore,
+		Listens:    pgStore,
+		Feedback:   pgStore,
+		Progress:   progress,
+		Queue:      pgStore,
+		Storage:    storage,
+		Mailer:     mailer,
+		Generator:  generator,
+		PublicURL:  publicURL,
+		AppURL:     appURL,
+
+		OIDCProviders: oidcProviders,
+
+		AudioURLSecret: audioURLSecret,
 	}
 
-	srv := handler.New(graph.NewExecutableSchema(graph.Config{Resolvers: resolver}))
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{Resolvers: resolver, Directives: graph.NewDirectives()}))
 
+	// Subscriptions are served over WebSockets. Browsers can't set headers on
+	// them, so the access token or API key is sent in the connection_init
+	// payload instead.
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		Upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				return origin == "" || origin == appURL
+			},
+		},
+		InitFunc: WebsocketInit(resolver),
+	})
 	srv.AddTransport(transport.Options{})
 	srv.AddTransport(transport.GET{})
 	srv.AddTransport(transport.POST{})
 
+	// Hide internal errors from clients and give every error a code.
+	srv.SetErrorPresenter(graph.ErrorPresenter)
+
+	// Throttle authentication mutations. Counters are kept in memory unless
+	// several instances need to share them through Postgres.
+	var rateLimitStore graph.RateLimitStore
+	switch backend := os.Getenv("RATE_LIMIT_BACKEND"); backend {
+	case "", "memory":
+		rateLimitStore = graph.NewMemoryRateLimitStore()
+	case "postgres":
+		rateLimitStore = pgStore
+	default:
+		log.Fatalf("unknown RATE_LIMIT_BACKEND %q", backend)
+	}
+	srv.AroundFields(graph.NewRateLimiter(rateLimitStore).Middleware)
+
+	// Playback positions of the episodes in a response are fetched together.
+	srv.AroundOperations(resolver.LoadListens)
+
 	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
 
 	srv.Use(extension.Introspection{})
@@ -97,9 +325,27 @@ func main() {
 	})
 
 	// Add the CORS middleware before the AuthMiddleware.
-	http.Handle("/query", CORSMiddleware(AuthMiddleware(srv)))
+	trustProxy := os.Getenv("TRUST_PROXY") == "true"
+	http.Handle("/query", CORSMiddleware(ClientIPMiddleware(trustProxy, AuthMiddleware(resolver, srv))))
 	http.Handle("/", playground.Handler("GraphQL playground", "/query"))
 
+	// Public keys for verifying access tokens.
+	http.HandleFunc("GET /.well-known/jwks.json", tokens.ServeJWKS)
+
+	// Sign in with external OIDC providers.
+	http.HandleFunc("GET /auth/oidc/{provider}/start", resolver.ServeOIDCStart)
+	http.HandleFunc("GET /auth/oidc/{provider}/callback", resolver.ServeOIDCCallback)
+
+	// Signed in users can download everything stored about them.
+	http.Handle("GET /me/export", CORSMiddleware(AuthMiddleware(resolver, http.HandlerFunc(resolver.ServeDataExport))))
+
+	// Audio is streamed with Range support for either a Bearer token or a signed URL.
+	http.Handle("GET /audio/{episodeID}", AuthMiddleware(resolver, http.HandlerFunc(resolver.ServeAudio)))
+
+	// Private podcast feeds are authenticated by the token in the URL.
+	http.HandleFunc("GET /feed/{token}/podcast.xml", resolver.ServeFeed)
+	http.HandleFunc("GET /feed/{token}/episodes/{file}", resolver.ServeFeedAudio)
+
 	log.Printf("connect to http://localhost:%s/ for GraphQL playground", port)
 	log.Fatal(http.ListenAndServe(":"+port, nil))
 }
@@ -0,0 +1,97 @@
+import { useEffect, useState } from "react";
+import { CombinedGraphQLErrors } from "@apollo/client";
+import { useMutation } from "@apollo/client/react";
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+import { SlidersHorizontal } from "lucide-react";
+import { useToast } from "@/hooks/use-toast";
+import { UPDATE_ARTICLE_FILTERS } from "@/lib/mutations";
+
+const FIELDS = [
+  { name: "includeKeywords", label: "Only stories mentioning", placeholder: "climate, elections" },
+  { name: "excludeKeywords", label: "Never stories mentioning", placeholder: "celebrity, gossip" },
+  { name: "preferredSources", label: "Preferred outlets", placeholder: "apnews.com, reuters.com" },
+  { name: "blockedSources", label: "Blocked outlets", placeholder: "example.com" },
+];
+
+const toText = (values) => (values ?? []).join(", ");
+const toList = (text) => text.split(",").map((value) => value.trim()).filter(Boolean);
+
+// ArticleFiltersForm edits the keyword and outlet rules applied when
+// stories are picked for the user's episodes.
+const ArticleFiltersForm = ({ filters }) => {
+  const { toast } = useToast();
+  const [values, setValues] = useState({});
+
+  useEffect(() => {
+    if (filters) {
+      setValues(Object.fromEntries(FIELDS.map(({ name }) => [name, toText(filters[name])])));
+    }
+  }, [filters]);
+
+  const [updateArticleFilters, { loading }] = useMutation(UPDATE_ARTICLE_FILTERS, {
+    onCompleted: (data) => {
+      const saved = data.updateArticleFilters;
+      setValues(Object.fromEntries(FIELDS.map(({ name }) => [name, toText(saved[name])])));
+      toast({
+        title: "Filters Saved",
+        description: "Your next episodes will follow these rules.",
+      });
+    },
+    onError: (error) => {
+      const code = CombinedGraphQLErrors.is(error) ? error.errors[0]?.extensions?.code : null;
+      toast({
+        title: "Error",
+        description: code === "BAD_USER_INPUT" ? error.errors[0].message : "Failed to save filters",
+        variant: "destructive",
+      });
+    },
+  });
+
+  const handleSave = () => {
+    updateArticleFilters({
+      variables: {
+        filters: Object.fromEntries(FIELDS.map(({ name }) => [name, toList(values[name] ?? "")])),
+      },
+    });
+  };
+
+  return (
+    <Card className="bg-glass-bg border-glass-border backdrop-blur-sm">
+      <CardHeader>
+        <CardTitle className="flex items-center gap-2">
+          <SlidersHorizontal className="h-5 w-5 text-primary" />
+          Story Filters
+        </CardTitle>
+        <CardDescription>
+          Separate keywords and outlets with commas. Outlets are matched by domain.
+        </CardDescription>
+      </CardHeader>
+
+      <CardContent className="space-y-4">
+        <div className="grid md:grid-cols-2 gap-4">
+          {FIELDS.map(({ name, label, placeholder }) => (
+            <div key={name} className="space-y-2">
+              <Label htmlFor={name}>{label}</Label>
+              <Input
+                id={name}
+                placeholder={placeholder}
+                value={values[name] ?? ""}
+                onChange={(e) => setValues({ ...values, [name]: e.target.value })}
+                className="bg-background/50 border-border"
+              />
+            </div>
+          ))}
+        </div>
+
+        <Button variant="outline" className="w-full" onClick={handleSave} disabled={loading}>
+          {loading ? "Saving..." : "Save Filters"}
+        </Button>
+      </CardContent>
+    </Card>
+  );
+};
+
+export default ArticleFiltersForm;
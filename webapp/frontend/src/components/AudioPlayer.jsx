@@ -0,0 +1,83 @@
+import { useEffect, useRef } from "react";
+import { useMutation } from "@apollo/client/react";
+import { UPDATE_PLAYBACK_POSITION } from "@/lib/mutations";
+
+// How often, in seconds of playback, the position is saved while playing.
+const REPORT_INTERVAL = 15;
+
+// Jumps in the playback time larger than this are seeks, not listening.
+const MAX_TIME_STEP = 2;
+
+// AudioPlayer plays an episode from where the user left off and saves their
+// position as they listen, when paused and when the episode ends, together
+// with how long they actually listened. Render it with a key per episode so
+// switching episodes saves the previous position.
+const AudioPlayer = ({ episodeId, startAt = 0, src, className }) => {
+  const audioRef = useRef(null);
+  const reportedRef = useRef(0);
+  const lastTimeRef = useRef(null);
+  const listenedRef = useRef(0);
+  const [updatePlaybackPosition] = useMutation(UPDATE_PLAYBACK_POSITION);
+
+  // Pausing or finishing can change which episodes are in progress
+  const report = (seconds, { refetch = false } = {}) => {
+    if (!episodeId) return;
+    reportedRef.current = seconds;
+    const listenedSeconds = Math.floor(listenedRef.current);
+    listenedRef.current -= listenedSeconds;
+    updatePlaybackPosition({
+      variables: { episodeId, seconds: Math.floor(seconds), listenedSeconds },
+      refetchQueries: refetch ? ["ContinueListening"] : [],
+    }).catch(() => {
+      // The position is replaced by the next report; keep the listening time
+      listenedRef.current += listenedSeconds;
+    });
+  };
+
+  // Save the position when the player goes away
+  useEffect(() => {
+    reportedRef.current = startAt;
+    const audio = audioRef.current;
+    return () => {
+      if (audio && audio.currentTime > 0 && Math.floor(audio.currentTime) !== Math.floor(reportedRef.current)) {
+        report(audio.currentTime);
+      }
+    };
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [episodeId]);
+
+  const handleLoadedMetadata = () => {
+    if (startAt > 0 && startAt < audioRef.current.duration) {
+      audioRef.current.currentTime = startAt;
+    }
+  };
+
+  const handleTimeUpdate = () => {
+    const { currentTime, paused } = audioRef.current;
+    const step = currentTime - (lastTimeRef.current ?? currentTime);
+    if (!paused && step > 0 && step <= MAX_TIME_STEP) {
+      listenedRef.current += step;
+    }
+    lastTimeRef.current = currentTime;
+
+    if (Math.abs(currentTime - reportedRef.current) >= REPORT_INTERVAL) {
+      report(currentTime);
+    }
+  };
+
+  return (
+    <audio
+      ref={audioRef}
+      controls
+      src={src}
+      className={className}
+      onLoadedMetadata={handleLoadedMetadata}
+      onTimeUpdate={handleTimeUpdate}
+      onSeeked={() => (lastTimeRef.current = audioRef.current.currentTime)}
+      onPause={() => report(audioRef.current.currentTime, { refetch: true })}
+      onEnded={() => report(audioRef.current.duration, { refetch: true })}
+    />
+  );
+};
+
+export default AudioPlayer;
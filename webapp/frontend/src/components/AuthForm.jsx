@@ -1,14 +1,35 @@
-import { useState } from "react";
+import { useState, useEffect } from "react";
 import { Button } from "@/components/ui/button";
 import { Input } from "@/components/ui/input";
 import { Card, CardContent, CardDescription, CardFooter, CardHeader, CardTitle } from "@/components/ui/card";
 import { Label } from "@/components/ui/label";
-import { useNavigate } from "react-router-dom";
-import { setToken } from "@/lib/auth";
+import { useNavigate, useSearchParams } from "react-router-dom";
+import { setSession } from "@/lib/auth";
 import { useToast } from "@/hooks/use-toast";
-import { gql} from "@apollo/client";
-import { useMutation } from "@apollo/client/react";
-import { LOGIN, SIGNUP } from "@/lib/mutations";
+import { gql, CombinedGraphQLErrors } from "@apollo/client";
+import { useMutation, useQuery } from "@apollo/client/react";
+import { LOGIN, SIGNUP, OIDC_PROVIDERS_QUERY } from "@/lib/mutations";
+
+// authErrorMessage picks a message for the error codes returned by the API
+const authErrorMessage = (err) => {
+  if (!CombinedGraphQLErrors.is(err)) return "Something went wrong, please try again.";
+  const error = err.errors[0];
+  switch (error?.extensions?.code) {
+    case "INVALID_CREDENTIALS":
+      return "Incorrect email or password.";
+    case "EMAIL_TAKEN":
+      return "An account with this email already exists. Try signing in instead.";
+    case "RATE_LIMITED":
+    case "ACCOUNT_LOCKED":
+      return `Too many attempts. Try again in ${Math.ceil((error.extensions.retryAfter ?? 60) / 60)} minute(s).`;
+    case "EMAIL_NOT_VERIFIED":
+      return "Your account at that provider has no verified email address.";
+    case "BAD_USER_INPUT":
+      return error.message.charAt(0).toUpperCase() + error.message.slice(1) + ".";
+    default:
+      return "Something went wrong, please try again.";
+  }
+};
 
 const AuthForm = ({ mode }) => {
   const [email, setEmail] = useState("");
@@ -17,6 +38,26 @@ const AuthForm = ({ mode }) => {
   const [loading, setLoading] = useState(false);
   const [loginMutation] = useMutation(LOGIN);
   const [signupMutation] = useMutation(SIGNUP);
+  const { toast } = useToast();
+  const { data: providerData } = useQuery(OIDC_PROVIDERS_QUERY);
+  const [searchParams] = useSearchParams();
+
+  // Provider sign in redirects back here with an error code when it fails
+  const providerError = searchParams.get("error");
+  useEffect(() => {
+    if (!providerError) return;
+    toast({
+      title: "Sign in failed",
+      description: providerError === "LOGIN_CANCELLED"
+        ? "Sign in was cancelled."
+        : providerError === "EMAIL_NOT_VERIFIED"
+          ? "Your account at that provider has no verified email address."
+          : providerError === "UNVERIFIED_ACCOUNT"
+            ? "An account with this email already exists. Sign in with its password and verify your email first."
+            : "Could not sign you in with that provider, please try again.",
+      variant: "destructive",
+    });
+  }, [providerError, toast]);
 
   const handleSubmit = async (e) => {
     e.preventDefault();
@@ -24,16 +65,20 @@ const AuthForm = ({ mode }) => {
       let result;
       if (mode === "login") {
         result = await loginMutation({ variables: { email, password } });
-        setToken(result.data.login); // save JWT
+        setSession(result.data.login); // save access and refresh tokens
       } else {
         result = await signupMutation({ variables: { email, password } });
-        setToken(result.data.signup); // save JWT
+        setSession(result.data.signup); // save access and refresh tokens
       }
 
       navigate("/dashboard"); // redirect after success
     } catch (err) {
       console.error("Auth error:", err);
-      alert("Something went wrong: " + err.message);
+      toast({
+        title: mode === "login" ? "Sign in failed" : "Sign up failed",
+        description: authErrorMessage(err),
+        variant: "destructive",
+      });
     }
   };
 
@@ -68,11 +113,22 @@ const AuthForm = ({ mode }) => {
             </div>
             
             <div className="space-y-2">
-              <Label htmlFor="password">Password</Label>
+              <div className="flex items-center justify-between">
+                <Label htmlFor="password">Password</Label>
+                {mode === 'login' && (
+                  <button
+                    type="button"
+                    onClick={() => navigate('/forgot-password')}
+                    className="text-sm text-primary hover:underline"
+                  >
+                    Forgot password?
+                  </button>
+                )}
+              </div>
               <Input
                 id="password"
                 type="password"
-                placeholder="Enter your password"
+                placeholder={mode === 'login' ? "Enter your password" : "At least 8 characters, with letters and numbers"}
                 value={password}
                 onChange={(e) => setPassword(e.target.value)}
                 required
@@ -91,6 +147,18 @@ const AuthForm = ({ mode }) => {
               {loading ? 'Please wait...' : (mode === 'login' ? 'Sign In' : 'Create Account')}
             </Button>
             
+            {providerData?.oidcProviders?.map((provider) => (
+              <Button
+                key={provider.id}
+                type="button"
+                variant="outline"
+                className="w-full"
+                onClick={() => { window.location.href = provider.loginUrl; }}
+              >
+                Continue with {provider.name}
+              </Button>
+            ))}
+
             <p className="text-sm text-muted-foreground text-center">
               {mode === 'login' 
                 ? "Don't have an account? " 
@@ -0,0 +1,120 @@
+import { useEffect, useMemo, useState } from "react";
+import { CombinedGraphQLErrors } from "@apollo/client";
+import { useMutation } from "@apollo/client/react";
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+import { Switch } from "@/components/ui/switch";
+import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@/components/ui/select";
+import { Clock } from "lucide-react";
+import { useToast } from "@/hooks/use-toast";
+import { UPDATE_DELIVERY_SCHEDULE } from "@/lib/mutations";
+
+const browserTimezone = () => Intl.DateTimeFormat().resolvedOptions().timeZone || "UTC";
+
+const timezones = () => {
+  const zones = typeof Intl.supportedValuesOf === "function" ? Intl.supportedValuesOf("timeZone") : [];
+  return zones.includes("UTC") ? zones : ["UTC", ...zones];
+};
+
+// DeliveryScheduleForm sets the timezone and time of day by which the
+// user's personal episode is ready each day.
+const DeliveryScheduleForm = ({ preferences }) => {
+  const { toast } = useToast();
+  const [timezone, setTimezone] = useState("UTC");
+  const [enabled, setEnabled] = useState(false);
+  const [deliveryTime, setDeliveryTime] = useState("07:00");
+  const zones = useMemo(timezones, []);
+
+  useEffect(() => {
+    if (preferences) {
+      // Suggest the browser's timezone until a schedule has been saved
+      const saved = preferences.deliveryTime != null || preferences.timezone !== "UTC";
+      setTimezone(saved ? preferences.timezone : browserTimezone());
+      setEnabled(preferences.deliveryTime != null);
+      setDeliveryTime(preferences.deliveryTime ?? "07:00");
+    }
+  }, [preferences]);
+
+  const [updateDeliverySchedule, { loading }] = useMutation(UPDATE_DELIVERY_SCHEDULE, {
+    onCompleted: (data) => {
+      const saved = data.updateDeliverySchedule;
+      toast({
+        title: "Schedule Saved",
+        description: saved.deliveryTime
+          ? `Your episode will be ready by ${saved.deliveryTime} (${saved.timezone}) every day.`
+          : "Daily delivery is off.",
+      });
+    },
+    onError: (error) => {
+      const code = CombinedGraphQLErrors.is(error) ? error.errors[0]?.extensions?.code : null;
+      toast({
+        title: "Error",
+        description: code === "BAD_USER_INPUT" ? error.errors[0].message : "Failed to save schedule",
+        variant: "destructive",
+      });
+    },
+  });
+
+  const handleSave = () => {
+    updateDeliverySchedule({
+      variables: { timezone, deliveryTime: enabled ? deliveryTime : null },
+    });
+  };
+
+  return (
+    <Card className="bg-glass-bg border-glass-border backdrop-blur-sm">
+      <CardHeader>
+        <CardTitle className="flex items-center gap-2">
+          <Clock className="h-5 w-5 text-primary" />
+          Daily Delivery
+        </CardTitle>
+        <CardDescription>
+          Get a personal episode every day. It is generated shortly before the time you pick.
+        </CardDescription>
+      </CardHeader>
+
+      <CardContent className="space-y-4">
+        <div className="grid md:grid-cols-2 gap-4">
+          <div className="space-y-2">
+            <Label htmlFor="timezone">Timezone</Label>
+            <Select value={timezone} onValueChange={setTimezone}>
+              <SelectTrigger id="timezone" className="bg-background/50 border-border">
+                <SelectValue placeholder="Select a timezone" />
+              </SelectTrigger>
+              <SelectContent className="bg-popover border-border">
+                {zones.map((zone) => (
+                  <SelectItem key={zone} value={zone}>
+                    {zone.replaceAll("_", " ")}
+                  </SelectItem>
+                ))}
+              </SelectContent>
+            </Select>
+          </div>
+
+          <div className="space-y-2">
+            <Label htmlFor="deliveryTime">Ready by</Label>
+            <div className="flex items-center gap-3">
+              <Switch checked={enabled} onCheckedChange={setEnabled} aria-label="Deliver daily" />
+              <Input
+                id="deliveryTime"
+                type="time"
+                value={deliveryTime}
+                onChange={(e) => setDeliveryTime(e.target.value)}
+                disabled={!enabled}
+                className="bg-background/50 border-border"
+              />
+            </div>
+          </div>
+        </div>
+
+        <Button variant="outline" className="w-full" onClick={handleSave} disabled={loading || (enabled && !deliveryTime)}>
+          {loading ? "Saving..." : "Save Schedule"}
+        </Button>
+      </CardContent>
+    </Card>
+  );
+};
+
+export default DeliveryScheduleForm;
@@ -0,0 +1,126 @@
+import { useState } from "react";
+import { CombinedGraphQLErrors } from "@apollo/client";
+import { useMutation } from "@apollo/client/react";
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card";
+import { Button } from "@/components/ui/button";
+import { Textarea } from "@/components/ui/textarea";
+import { MessageSquare, Star, ThumbsDown, ThumbsUp } from "lucide-react";
+import { useToast } from "@/hooks/use-toast";
+import { cn } from "@/lib/utils";
+import { RATE_EPISODE, RATE_STORY } from "@/lib/mutations";
+
+// EpisodeFeedback lets the user rate an episode and give each of its stories
+// a thumbs up or down. Outlets they dislike are used last in their episodes.
+const EpisodeFeedback = ({ episode }) => {
+  const { toast } = useToast();
+  const [stars, setStars] = useState(0);
+  const [comment, setComment] = useState("");
+  const [thumbs, setThumbs] = useState({});
+
+  const showError = (error, fallback) => {
+    const code = CombinedGraphQLErrors.is(error) ? error.errors[0]?.extensions?.code : null;
+    toast({
+      title: "Error",
+      description: code === "BAD_USER_INPUT" ? error.errors[0].message : fallback,
+      variant: "destructive",
+    });
+  };
+
+  const [rateEpisode, { loading }] = useMutation(RATE_EPISODE, {
+    onCompleted: () => {
+      toast({ title: "Thanks for the feedback", description: "Your rating has been saved." });
+    },
+    onError: (error) => showError(error, "Failed to save your rating"),
+  });
+
+  const [rateStory] = useMutation(RATE_STORY);
+
+  const handleThumbs = (storyIndex, value) => {
+    const previous = thumbs[storyIndex];
+    setThumbs((current) => ({ ...current, [storyIndex]: value }));
+    rateStory({ variables: { episodeId: episode.id, storyIndex, thumbs: value } }).catch((error) => {
+      setThumbs((current) => ({ ...current, [storyIndex]: previous }));
+      showError(error, "Failed to save your rating");
+    });
+  };
+
+  const handleSubmit = () => {
+    rateEpisode({ variables: { id: episode.id, stars, comment: comment.trim() || null } });
+  };
+
+  return (
+    <Card className="bg-glass-bg border-glass-border backdrop-blur-sm">
+      <CardHeader>
+        <CardTitle className="flex items-center gap-2">
+          <MessageSquare className="h-5 w-5 text-primary" />
+          How was this episode?
+        </CardTitle>
+        <CardDescription>Your ratings help pick the stories in your future episodes.</CardDescription>
+      </CardHeader>
+
+      <CardContent className="space-y-6">
+        <div className="space-y-3">
+          <div className="flex gap-1" role="radiogroup" aria-label="Stars">
+            {[1, 2, 3, 4, 5].map((value) => (
+              <button
+                key={value}
+                type="button"
+                role="radio"
+                aria-checked={stars === value}
+                aria-label={`${value} star${value === 1 ? "" : "s"}`}
+                onClick={() => setStars(value)}
+              >
+                <Star className={cn("h-6 w-6", value <= stars ? "fill-primary text-primary" : "text-muted-foreground")} />
+              </button>
+            ))}
+          </div>
+          <Textarea
+            placeholder="Anything boring or inaccurate? (optional)"
+            value={comment}
+            onChange={(e) => setComment(e.target.value)}
+            maxLength={2000}
+            className="bg-background/50 border-border"
+          />
+          <Button variant="outline" className="w-full" onClick={handleSubmit} disabled={loading || stars === 0}>
+            {loading ? "Saving..." : "Rate Episode"}
+          </Button>
+        </div>
+
+        {episode.sources.length > 0 && (
+          <ul className="divide-y divide-border">
+            {episode.sources.map((source, index) => (
+              <li key={index} className="flex items-center justify-between gap-4 py-2 text-sm">
+                <div className="min-w-0">
+                  <a href={source.url} target="_blank" rel="noreferrer" className="text-foreground hover:underline">
+                    {source.title}
+                  </a>
+                  {source.outlet && <p className="text-muted-foreground">{source.outlet}</p>}
+                </div>
+                <div className="flex shrink-0 gap-1">
+                  <Button
+                    variant={thumbs[index] === "UP" ? "default" : "ghost"}
+                    size="icon"
+                    aria-label="Thumbs up"
+                    onClick={() => handleThumbs(index, "UP")}
+                  >
+                    <ThumbsUp className="h-4 w-4" />
+                  </Button>
+                  <Button
+                    variant={thumbs[index] === "DOWN" ? "default" : "ghost"}
+                    size="icon"
+                    aria-label="Thumbs down"
+                    onClick={() => handleThumbs(index, "DOWN")}
+                  >
+                    <ThumbsDown className="h-4 w-4" />
+                  </Button>
+                </div>
+              </li>
+            ))}
+          </ul>
+        )}
+      </CardContent>
+    </Card>
+  );
+};
+
+export default EpisodeFeedback;
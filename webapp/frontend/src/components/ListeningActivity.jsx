@@ -0,0 +1,94 @@
+import { useQuery } from "@apollo/client/react";
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card";
+import { Button } from "@/components/ui/button";
+import { Headphones } from "lucide-react";
+import { LISTENING_HISTORY, MY_STATS } from "@/lib/mutations";
+import { formatDuration } from "@/lib/utils";
+
+const Stat = ({ label, value }) => (
+  <div className="rounded-md border border-border bg-background/50 p-3">
+    <p className="text-2xl font-semibold text-foreground">{value}</p>
+    <p className="text-sm text-muted-foreground">{label}</p>
+  </div>
+);
+
+// ListeningActivity shows the user's listening statistics and their
+// listening sessions, most recent first.
+const ListeningActivity = () => {
+  const { data: statsData } = useQuery(MY_STATS, { fetchPolicy: "cache-and-network" });
+  const { data: historyData, fetchMore } = useQuery(LISTENING_HISTORY, {
+    variables: { first: 10 },
+    fetchPolicy: "cache-and-network",
+  });
+
+  const stats = statsData?.myStats;
+  const sessions = historyData?.listeningHistory?.edges?.map((edge) => edge.node) ?? [];
+  const pageInfo = historyData?.listeningHistory?.pageInfo;
+
+  const handleLoadMore = () => {
+    fetchMore({
+      variables: { after: pageInfo.endCursor },
+      updateQuery: (previous, { fetchMoreResult }) => ({
+        listeningHistory: {
+          ...fetchMoreResult.listeningHistory,
+          edges: [...previous.listeningHistory.edges, ...fetchMoreResult.listeningHistory.edges],
+        },
+      }),
+    });
+  };
+
+  return (
+    <Card className="bg-glass-bg border-glass-border backdrop-blur-sm">
+      <CardHeader>
+        <CardTitle className="flex items-center gap-2">
+          <Headphones className="h-5 w-5 text-primary" />
+          Listening
+        </CardTitle>
+        <CardDescription>What you have listened to in the web player.</CardDescription>
+      </CardHeader>
+
+      <CardContent className="space-y-6">
+        {stats && (
+          <div className="grid grid-cols-2 md:grid-cols-3 gap-3">
+            <Stat label="Minutes listened" value={stats.totalMinutes} />
+            <Stat label={stats.streakDays === 1 ? "Day streak" : "Days streak"} value={stats.streakDays} />
+            <Stat label="Episodes finished" value={`${Math.round(stats.completionRate * 100)}%`} />
+          </div>
+        )}
+
+        {stats?.favouriteTopics?.length > 0 && (
+          <div className="space-y-1">
+            <p className="text-sm font-medium text-foreground">Favourite topics</p>
+            <p className="text-sm text-muted-foreground">
+              {stats.favouriteTopics.map(({ topic, minutes }) => `${topic} (${minutes} min)`).join(", ")}
+            </p>
+          </div>
+        )}
+
+        {sessions.length > 0 ? (
+          <ul className="divide-y divide-border">
+            {sessions.map((session) => (
+              <li key={session.id} className="flex items-center justify-between py-2 text-sm">
+                <div>
+                  <p className="text-foreground">{session.episode.title}</p>
+                  <p className="text-muted-foreground">{new Date(session.startedAt).toLocaleString()}</p>
+                </div>
+                <span className="text-muted-foreground">{formatDuration(session.listenedSeconds)}</span>
+              </li>
+            ))}
+          </ul>
+        ) : (
+          <p className="text-sm text-muted-foreground">Nothing played yet.</p>
+        )}
+
+        {pageInfo?.hasNextPage && (
+          <Button variant="outline" className="w-full" onClick={handleLoadMore}>
+            Load more
+          </Button>
+        )}
+      </CardContent>
+    </Card>
+  );
+};
+
+export default ListeningActivity;
@@ -1,13 +1,22 @@
 import { Button } from "@/components/ui/button";
-import { Headphones, LogOut, User } from "lucide-react";
+import { Headphones, LogOut, Settings, User } from "lucide-react";
 import { useNavigate } from "react-router-dom";
+import { useMutation } from "@apollo/client/react";
 import { isAuthenticated, removeToken } from "@/lib/auth";
+import { LOGOUT } from "@/lib/mutations";
 
 const NavBar = () => {
   const navigate = useNavigate();
   const authenticated = isAuthenticated();
+  const [logout] = useMutation(LOGOUT);
 
-  const handleLogout = () => {
+  const handleLogout = async () => {
+    // Revoke the session server-side; log out locally even if that fails
+    try {
+      await logout();
+    } catch (err) {
+      console.error("Logout error:", err);
+    }
     removeToken();
     navigate('/');
   };
@@ -34,6 +43,14 @@ const NavBar = () => {
                 <User className="h-4 w-4" />
                 Dashboard
               </Button>
+              <Button 
+                variant="ghost" 
+                onClick={() => navigate('/account')}
+                className="flex items-center gap-2"
+              >
+                <Settings className="h-4 w-4" />
+                Account
+              </Button>
               <Button 
                 variant="outline" 
                 onClick={handleLogout}
@@ -1,37 +1,80 @@
-import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@/components/ui/select";
 import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card";
 import { Button } from "@/components/ui/button";
+import { Checkbox } from "@/components/ui/checkbox";
+import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@/components/ui/select";
+import { Slider } from "@/components/ui/slider";
 import { Filter } from "lucide-react";
+import { useQuery } from "@apollo/client/react";
+import { SUPPORTED_OPTIONS } from "@/lib/mutations";
+
+const MAX_WEIGHT = 10;
 
-const COUNTRIES = [
-  { value: 'ca', label: 'Canada' },
-  { value: 'us', label: 'United States' },
-  { value: 'uk', label: 'United Kingdom' },
-  { value: 'au', label: 'Australia' },
-  { value: 'de', label: 'Germany' },
-  { value: 'fr', label: 'France' },
-  { value: 'jp', label: 'Japan' },
-];
+// WeightedOptions lets the user pick several options and how much each one
+// matters relative to the others.
+const WeightedOptions = ({ label, options, selected, field, onChange }) => {
+  const weightOf = (value) => selected.find((item) => item[field] === value)?.weight;
 
-const TOPICS = [
-  { value: 'sports', label: 'Sports' },
-  { value: 'technology', label: 'Technology' },
-  { value: 'business', label: 'Business' },
-  { value: 'science', label: 'Science' },
-  { value: 'entertainment', label: 'Entertainment' },
-  { value: 'health', label: 'Health & Wellness' },
-  { value: 'politics', label: 'Politics' },
-  { value: 'education', label: 'Education' },
-];
+  const toggle = (value, checked) => {
+    onChange(checked
+      ? [...selected, { [field]: value, weight: 1 }]
+      : selected.filter((item) => item[field] !== value));
+  };
+
+  const setWeight = (value, weight) => {
+    onChange(selected.map((item) => (item[field] === value ? { ...item, weight } : item)));
+  };
+
+  return (
+    <div className="space-y-2">
+      <label className="text-sm font-medium text-foreground">{label}</label>
+      <div className="space-y-3">
+        {options.map((option) => {
+          const weight = weightOf(option.value);
+          return (
+            <div key={option.value} className="flex items-center gap-3">
+              <Checkbox
+                id={`${field}-${option.value}`}
+                checked={weight !== undefined}
+                onCheckedChange={(checked) => toggle(option.value, checked === true)}
+              />
+              <label htmlFor={`${field}-${option.value}`} className="w-40 text-sm text-foreground">
+                {option.label}
+              </label>
+              {weight !== undefined && (
+                <>
+                  <Slider
+                    min={1}
+                    max={MAX_WEIGHT}
+                    step={1}
+                    value={[weight]}
+                    onValueChange={([value]) => setWeight(option.value, value)}
+                    className="flex-1"
+                  />
+                  <span className="w-6 text-right text-sm text-muted-foreground">{weight}</span>
+                </>
+              )}
+            </div>
+          );
+        })}
+      </div>
+    </div>
+  );
+};
 
 const PreferenceSelector = ({ 
-  country, 
-  topic, 
-  onCountryChange, 
-  onTopicChange, 
+  countries, 
+  topics, 
+  language,
+  onCountriesChange, 
+  onTopicsChange, 
+  onLanguageChange,
   onApply,
   loading 
 }) => {
+  // The choices come from the server so they always match what it accepts
+  const { data } = useQuery(SUPPORTED_OPTIONS);
+  const options = data?.supportedOptions;
+
   return (
     <Card className="bg-glass-bg border-glass-border backdrop-blur-sm">
       <CardHeader>
@@ -40,56 +83,55 @@ const PreferenceSelector = ({
           Podcast Preferences
         </CardTitle>
         <CardDescription>
-          Select your preferred country and topic to discover personalized AI-generated podcasts
+          Pick the countries and topics you care about. Heavier weights get more stories in your episodes.
         </CardDescription>
       </CardHeader>
       
       <CardContent className="space-y-4">
-        <div className="grid md:grid-cols-2 gap-4">
-          <div className="space-y-2">
-            <label className="text-sm font-medium text-foreground">Country</label>
-            <Select value={country} onValueChange={onCountryChange}>
-              <SelectTrigger className="bg-background/50 border-border">
-                <SelectValue placeholder="Select a country" />
-              </SelectTrigger>
-              <SelectContent className="bg-popover border-border">
-                {COUNTRIES.map((c) => (
-                  <SelectItem key={c.value} value={c.value}>
-                    {c.label}
-                  </SelectItem>
-                ))}
-              </SelectContent>
-            </Select>
-          </div>
-          
-          <div className="space-y-2">
-            <label className="text-sm font-medium text-foreground">Topic</label>
-            <Select value={topic} onValueChange={onTopicChange}>
-              <SelectTrigger className="bg-background/50 border-border">
-                <SelectValue placeholder="Select a topic" />
-              </SelectTrigger>
-              <SelectContent className="bg-popover border-border">
-                {TOPICS.map((t) => (
-                  <SelectItem key={t.value} value={t.value}>
-                    {t.label}
-                  </SelectItem>
-                ))}
-              </SelectContent>
-            </Select>
-          </div>
+        <div className="grid md:grid-cols-2 gap-8">
+          <WeightedOptions
+            label="Countries"
+            options={options?.countries ?? []}
+            selected={countries}
+            field="country"
+            onChange={onCountriesChange}
+          />
+          <WeightedOptions
+            label="Topics"
+            options={options?.topics ?? []}
+            selected={topics}
+            field="topic"
+            onChange={onTopicsChange}
+          />
+        </div>
+
+        <div className="space-y-2 md:w-1/2">
+          <label className="text-sm font-medium text-foreground">Language</label>
+          <Select value={language} onValueChange={onLanguageChange}>
+            <SelectTrigger className="bg-background/50 border-border">
+              <SelectValue placeholder="Select a language" />
+            </SelectTrigger>
+            <SelectContent className="bg-popover border-border">
+              {(options?.languages ?? []).map((l) => (
+                <SelectItem key={l.value} value={l.value}>
+                  {l.label}
+                </SelectItem>
+              ))}
+            </SelectContent>
+          </Select>
         </div>
         
         <Button 
           onClick={onApply}
           variant="hero"
           className="w-full"
-          disabled={!country || !topic || loading}
+          disabled={countries.length === 0 || topics.length === 0 || loading}
         >
-          {loading ? 'Loading Podcasts...' : 'Find Podcasts'}
+          {loading ? 'Loading Podcasts...' : 'Save Preferences'}
         </Button>
       </CardContent>
     </Card>
   );
 };
 
-export default PreferenceSelector;
\ No newline at end of file
+export default PreferenceSelector;
@@ -1,25 +1,96 @@
-import { ApolloClient, InMemoryCache, ApolloLink, HttpLink } from "@apollo/client";
+import { ApolloClient, InMemoryCache, HttpLink, ApolloLink } from "@apollo/client";
+import { SetContextLink } from "@apollo/client/link/context";
+import { GraphQLWsLink } from "@apollo/client/link/subscriptions";
+import { getMainDefinition } from "@apollo/client/utilities";
+import { createClient } from "graphql-ws";
+import { getRefreshToken, getToken, removeToken, setSession, tokenExpiresSoon } from "./auth";
+
+const GRAPHQL_URL = "http://localhost:8080/query"; // Replace with your Go GraphQL server
+const GRAPHQL_WS_URL = GRAPHQL_URL.replace(/^http/, "ws");
 
 // Http link for GraphQL endpoint
 const httpLink = new HttpLink({
-  uri: "http://localhost:8080/query", // Replace with your Go GraphQL server
+  uri: GRAPHQL_URL,
 });
 
-// Auth link using ApolloLink
-const authLink = new ApolloLink((operation, forward) => {
-  const token = localStorage.getItem("token");
-  if (token) {
-    operation.setContext({
-      headers: {
-        Authorization: `Bearer ${token}`,
-      },
-    });
+// Exchanges the refresh token for a new access token. Concurrent callers
+// share one request, since each refresh token can only be used once.
+let refreshing = null;
+const refreshAccessToken = () => {
+  if (!refreshing) {
+    refreshing = fetch(GRAPHQL_URL, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({
+        query: `mutation RefreshToken($refreshToken: String!) {
+          refreshToken(refreshToken: $refreshToken) { accessToken refreshToken expiresAt }
+        }`,
+        variables: { refreshToken: getRefreshToken() },
+      }),
+    })
+      .then((res) => res.json())
+      .then(({ data }) => {
+        if (data?.refreshToken) {
+          setSession(data.refreshToken);
+        } else {
+          removeToken();
+        }
+      })
+      .catch(() => removeToken())
+      .finally(() => {
+        refreshing = null;
+      });
+  }
+  return refreshing;
+};
+
+// Auth link, refreshing the access token shortly before it expires
+const authLink = new SetContextLink(async (prevContext) => {
+  if (getRefreshToken() && tokenExpiresSoon()) {
+    await refreshAccessToken();
+  }
+
+  const token = getToken();
+  if (!token) {
+    return {};
   }
-  return forward(operation);
+  return {
+    headers: {
+      ...prevContext.headers,
+      Authorization: `Bearer ${token}`,
+    },
+  };
 });
 
+// WebSocket link for subscriptions. Browsers can't set headers on WebSockets,
+// so the access token is sent when the connection is opened.
+const wsLink = new GraphQLWsLink(
+  createClient({
+    url: GRAPHQL_WS_URL,
+    lazy: true,
+    connectionParams: async () => {
+      if (getRefreshToken() && tokenExpiresSoon()) {
+        await refreshAccessToken();
+      }
+
+      const token = getToken();
+      return token ? { Authorization: `Bearer ${token}` } : {};
+    },
+  })
+);
+
+// Send subscriptions over the WebSocket and everything else over HTTP
+const splitLink = ApolloLink.split(
+  ({ query }) => {
+    const definition = getMainDefinition(query);
+    return definition.kind === "OperationDefinition" && definition.operation === "subscription";
+  },
+  wsLink,
+  authLink.concat(httpLink)
+);
+
 // Apollo Client
 export const apolloClient = new ApolloClient({
-  link: authLink.concat(httpLink),
+  link: splitLink,
   cache: new InMemoryCache(),
 });
\ No newline at end of file
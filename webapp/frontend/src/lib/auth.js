@@ -2,18 +2,37 @@ export const getToken = () => {
   return localStorage.getItem('token');
 };
 
+export const getRefreshToken = () => {
+  return localStorage.getItem('refreshToken');
+};
+
 export const setToken = (token) => {
   localStorage.setItem('token', token);
 };
 
+// Stores the tokens returned by login, signup and refreshToken.
+export const setSession = ({ accessToken, refreshToken, expiresAt }) => {
+  localStorage.setItem('token', accessToken);
+  localStorage.setItem('refreshToken', refreshToken);
+  localStorage.setItem('tokenExpiresAt', expiresAt);
+};
+
 export const removeToken = () => {
   localStorage.removeItem('token');
+  localStorage.removeItem('refreshToken');
+  localStorage.removeItem('tokenExpiresAt');
 };
 
 export const isAuthenticated = () => {
   return !!getToken();
 };
 
+// True when the access token expires within the next 30 seconds.
+export const tokenExpiresSoon = () => {
+  const expiresAt = localStorage.getItem('tokenExpiresAt');
+  return !!expiresAt && new Date(expiresAt).getTime() - Date.now() < 30 * 1000;
+};
+
 export const decodeToken = (token) => {
   try {
     const base64Url = token.split('.')[1];
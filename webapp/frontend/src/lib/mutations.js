@@ -2,13 +2,27 @@ import { gql } from "@apollo/client";
 
 export const LOGIN = gql`
   mutation Login($email: String!, $password: String!) {
-    login(email: $email, password: $password)
+    login(email: $email, password: $password) {
+      accessToken
+      refreshToken
+      expiresAt
+    }
   }
 `;
 
 export const SIGNUP = gql`
   mutation Signup($email: String!, $password: String!) {
-    signup(email: $email, password: $password)
+    signup(email: $email, password: $password) {
+      accessToken
+      refreshToken
+      expiresAt
+    }
+  }
+`;
+
+export const LOGOUT = gql`
+  mutation Logout {
+    logout
   }
 `;
 
@@ -17,9 +31,25 @@ export const ME_QUERY = gql`
     me {
       id
       email
+      emailVerified
       preferences {
-        country
-        topic
+        language
+        timezone
+        deliveryTime
+        filters {
+          includeKeywords
+          excludeKeywords
+          blockedSources
+          preferredSources
+        }
+        countries {
+          country
+          weight
+        }
+        topics {
+          topic
+          weight
+        }
       }
     }
   }
@@ -30,15 +60,284 @@ export const PODCAST_QUERY = gql`
     podcast(date: $date) {
       date
       url
+      episode {
+        id
+        country
+        topic
+        title
+        summary
+        durationSeconds
+        coverUrl
+        playbackPosition {
+          seconds
+          completed
+        }
+        chapters {
+          title
+          startSeconds
+          sourceUrl
+        }
+        sources {
+          title
+          url
+          outlet
+        }
+      }
     }
   }
 `;
 
 export const UPDATE_PREFS = gql`
-  mutation UpdatePreferences($country: String!, $topic: String!) {
-    updatePreferences(country: $country, topic: $topic) {
+  mutation UpdatePreferences($topics: [TopicWeightInput!]!, $countries: [CountryWeightInput!]!, $language: String) {
+    updatePreferences(topics: $topics, countries: $countries, language: $language) {
+      language
+      countries {
+        country
+        weight
+      }
+      topics {
+        topic
+        weight
+      }
+    }
+  }
+`;
+
+export const EPISODES_QUERY = gql`
+  query Episodes($first: Int, $after: String, $country: String, $topic: String) {
+    episodes(first: $first, after: $after, country: $country, topic: $topic) {
+      edges {
+        cursor
+        node {
+          id
+          date
+          country
+          topic
+          title
+          description
+          durationSeconds
+          audioUrl
+          playbackPosition {
+            seconds
+            completed
+          }
+        }
+      }
+      pageInfo {
+        hasNextPage
+        endCursor
+      }
+      totalCount
+    }
+  }
+`;
+
+export const ROTATE_FEED_TOKEN = gql`
+  mutation RotateFeedToken {
+    rotateFeedToken
+  }
+`;
+
+export const REQUEST_PASSWORD_RESET = gql`
+  mutation RequestPasswordReset($email: String!) {
+    requestPasswordReset(email: $email)
+  }
+`;
+
+export const RESET_PASSWORD = gql`
+  mutation ResetPassword($token: String!, $newPassword: String!) {
+    resetPassword(token: $token, newPassword: $newPassword)
+  }
+`;
+
+export const SEND_VERIFICATION_EMAIL = gql`
+  mutation SendVerificationEmail {
+    sendVerificationEmail
+  }
+`;
+
+export const VERIFY_EMAIL = gql`
+  mutation VerifyEmail($token: String!) {
+    verifyEmail(token: $token)
+  }
+`;
+
+export const OIDC_PROVIDERS_QUERY = gql`
+  query OidcProviders {
+    oidcProviders {
+      id
+      name
+      loginUrl
+    }
+  }
+`;
+
+export const EXPORT_MY_DATA = gql`
+  query ExportMyData {
+    exportMyData
+  }
+`;
+
+export const DELETE_ACCOUNT = gql`
+  mutation DeleteAccount($password: String) {
+    deleteAccount(password: $password)
+  }
+`;
+
+export const SUPPORTED_OPTIONS = gql`
+  query SupportedOptions {
+    supportedOptions {
+      countries {
+        value
+        label
+      }
+      topics {
+        value
+        label
+      }
+      languages {
+        value
+        label
+      }
+    }
+  }
+`;
+
+export const UPDATE_ARTICLE_FILTERS = gql`
+  mutation UpdateArticleFilters($filters: ArticleFiltersInput!) {
+    updateArticleFilters(filters: $filters) {
+      includeKeywords
+      excludeKeywords
+      blockedSources
+      preferredSources
+    }
+  }
+`;
+
+export const UPDATE_DELIVERY_SCHEDULE = gql`
+  mutation UpdateDeliverySchedule($timezone: String!, $deliveryTime: String) {
+    updateDeliverySchedule(timezone: $timezone, deliveryTime: $deliveryTime) {
+      timezone
+      deliveryTime
+    }
+  }
+`;
+
+export const UPDATE_PLAYBACK_POSITION = gql`
+  mutation UpdatePlaybackPosition($episodeId: ID!, $seconds: Int!, $listenedSeconds: Int) {
+    updatePlaybackPosition(episodeId: $episodeId, seconds: $seconds, listenedSeconds: $listenedSeconds) {
+      id
+      playbackPosition {
+        seconds
+        completed
+      }
+    }
+  }
+`;
+
+export const RATE_EPISODE = gql`
+  mutation RateEpisode($id: ID!, $stars: Int!, $comment: String) {
+    rateEpisode(id: $id, stars: $stars, comment: $comment) {
+      episodeId
+      stars
+      comment
+    }
+  }
+`;
+
+export const RATE_STORY = gql`
+  mutation RateStory($episodeId: ID!, $storyIndex: Int!, $thumbs: Thumbs!) {
+    rateStory(episodeId: $episodeId, storyIndex: $storyIndex, thumbs: $thumbs) {
+      episodeId
+      storyIndex
+      thumbs
+    }
+  }
+`;
+
+export const CONTINUE_LISTENING = gql`
+  query ContinueListening($first: Int) {
+    continueListening(first: $first) {
+      id
+      date
       country
       topic
+      title
+      description
+      durationSeconds
+      audioUrl
+      playbackPosition {
+        seconds
+        completed
+      }
+    }
+  }
+`;
+
+export const LISTENING_HISTORY = gql`
+  query ListeningHistory($first: Int, $after: String) {
+    listeningHistory(first: $first, after: $after) {
+      edges {
+        cursor
+        node {
+          id
+          listenedSeconds
+          positionSeconds
+          startedAt
+          episode {
+            id
+            title
+            durationSeconds
+          }
+        }
+      }
+      pageInfo {
+        hasNextPage
+        endCursor
+      }
+    }
+  }
+`;
+
+export const MY_STATS = gql`
+  query MyStats {
+    myStats {
+      totalMinutes
+      streakDays
+      completionRate
+      favouriteTopics {
+        topic
+        minutes
+      }
     }
   }
-`;
\ No newline at end of file
+`;
+
+const EPISODE_JOB_FIELDS = `
+  id
+  status
+  progress
+  error
+  episode {
+    id
+    title
+    durationSeconds
+    audioUrl
+  }
+`;
+
+export const GENERATE_MY_EPISODE = gql`
+  mutation GenerateMyEpisode {
+    generateMyEpisode {
+      ${EPISODE_JOB_FIELDS}
+    }
+  }
+`;
+
+export const EPISODE_PROGRESS = gql`
+  subscription EpisodeProgress($jobId: ID!) {
+    episodeProgress(jobId: $jobId) {
+      ${EPISODE_JOB_FIELDS}
+    }
+  }
+`;
@@ -3,4 +3,10 @@ import { twMerge } from "tailwind-merge"
 
 export function cn(...inputs) {
   return twMerge(clsx(inputs))
-}
\ No newline at end of file
+}
+export function formatDuration(seconds) {
+  if (!seconds) return "Unknown"
+  const minutes = Math.floor(seconds / 60)
+  const rest = String(seconds % 60).padStart(2, "0")
+  return `${minutes}:${rest}`
+}
@@ -0,0 +1,148 @@
+import { useEffect, useState } from "react";
+import { useNavigate } from "react-router-dom";
+import { CombinedGraphQLErrors } from "@apollo/client";
+import { useLazyQuery, useMutation } from "@apollo/client/react";
+import NavBar from "@/components/NavBar";
+import ListeningActivity from "@/components/ListeningActivity";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+import { Card, CardContent, CardDescription, CardFooter, CardHeader, CardTitle } from "@/components/ui/card";
+import {
+  AlertDialog,
+  AlertDialogAction,
+  AlertDialogCancel,
+  AlertDialogContent,
+  AlertDialogDescription,
+  AlertDialogFooter,
+  AlertDialogHeader,
+  AlertDialogTitle,
+  AlertDialogTrigger,
+} from "@/components/ui/alert-dialog";
+import { isAuthenticated, removeToken } from "@/lib/auth";
+import { useToast } from "@/hooks/use-toast";
+import { EXPORT_MY_DATA, DELETE_ACCOUNT } from "@/lib/mutations";
+
+const Account = () => {
+  const navigate = useNavigate();
+  const { toast } = useToast();
+  const [password, setPassword] = useState("");
+
+  useEffect(() => {
+    if (!isAuthenticated()) {
+      navigate("/login");
+    }
+  }, [navigate]);
+
+  const [exportMyData, { loading: exporting }] = useLazyQuery(EXPORT_MY_DATA, { fetchPolicy: "no-cache" });
+  const [deleteAccount, { loading: deleting }] = useMutation(DELETE_ACCOUNT);
+
+  const handleExport = async () => {
+    try {
+      const { data } = await exportMyData();
+      const blob = new Blob([JSON.stringify(JSON.parse(data.exportMyData), null, 2)], { type: "application/json" });
+      const link = document.createElement("a");
+      link.href = URL.createObjectURL(blob);
+      link.download = `dailynewspodcast-export-${new Date().toISOString().split("T")[0]}.json`;
+      link.click();
+      URL.revokeObjectURL(link.href);
+    } catch (err) {
+      console.error("Export error:", err);
+      toast({
+        title: "Error",
+        description: "Failed to export your data",
+        variant: "destructive",
+      });
+    }
+  };
+
+  const handleDelete = async () => {
+    try {
+      await deleteAccount({ variables: { password: password || null } });
+      removeToken();
+      navigate("/");
+      toast({
+        title: "Account deleted",
+        description: "Your account and all of its data have been removed.",
+      });
+    } catch (err) {
+      const code = CombinedGraphQLErrors.is(err) ? err.errors[0]?.extensions?.code : null;
+      toast({
+        title: "Error",
+        description: code === "INVALID_CREDENTIALS" ? "Password is incorrect." : "Failed to delete your account",
+        variant: "destructive",
+      });
+    }
+  };
+
+  return (
+    <div className="min-h-screen bg-gradient-hero">
+      <NavBar />
+
+      <main className="pt-20 pb-8">
+        <div className="container mx-auto px-4 max-w-2xl space-y-8">
+          <h1 className="text-3xl font-bold text-foreground">Account</h1>
+
+          <ListeningActivity />
+
+          <Card className="bg-glass-bg border-glass-border backdrop-blur-sm">
+            <CardHeader>
+              <CardTitle>Your Data</CardTitle>
+              <CardDescription>
+                Download a copy of your profile, preferences and activity as a JSON file.
+              </CardDescription>
+            </CardHeader>
+            <CardFooter>
+              <Button variant="outline" onClick={handleExport} disabled={exporting}>
+                {exporting ? "Preparing..." : "Download my data"}
+              </Button>
+            </CardFooter>
+          </Card>
+
+          <Card className="bg-glass-bg border-glass-border backdrop-blur-sm">
+            <CardHeader>
+              <CardTitle>Delete Account</CardTitle>
+              <CardDescription>
+                Permanently delete your account. Your sessions, feed links and API keys stop working immediately.
+              </CardDescription>
+            </CardHeader>
+            <CardContent className="space-y-2">
+              <Label htmlFor="password">Password</Label>
+              <Input
+                id="password"
+                type="password"
+                placeholder="Leave empty if you only sign in with a provider"
+                value={password}
+                onChange={(e) => setPassword(e.target.value)}
+                className="bg-background/50 border-border"
+              />
+            </CardContent>
+            <CardFooter>
+              <AlertDialog>
+                <AlertDialogTrigger asChild>
+                  <Button variant="destructive" disabled={deleting}>
+                    {deleting ? "Deleting..." : "Delete my account"}
+                  </Button>
+                </AlertDialogTrigger>
+                <AlertDialogContent>
+                  <AlertDialogHeader>
+                    <AlertDialogTitle>Delete your account?</AlertDialogTitle>
+                    <AlertDialogDescription>
+                      This can't be undone. Consider downloading your data first.
+                    </AlertDialogDescription>
+                  </AlertDialogHeader>
+                  <AlertDialogFooter>
+                    <AlertDialogCancel>Cancel</AlertDialogCancel>
+                    <AlertDialogAction onClick={handleDelete}>Delete</AlertDialogAction>
+                  </AlertDialogFooter>
+                </AlertDialogContent>
+              </AlertDialog>
+            </CardFooter>
+          </Card>
+        </div>
+      </main>
+    </div>
+  );
+};
+
+export default Account;
@@ -1,20 +1,34 @@
 import { useState, useEffect } from "react";
 import { useNavigate } from "react-router-dom";
 import NavBar from "@/components/NavBar";
-import { useQuery, useLazyQuery, useMutation } from "@apollo/client/react";
+import { CombinedGraphQLErrors } from "@apollo/client";
+import { useQuery, useLazyQuery, useMutation, useSubscription } from "@apollo/client/react";
 import PreferenceSelector from "@/components/PreferenceSelector";
+import ArticleFiltersForm from "@/components/ArticleFiltersForm";
+import DeliveryScheduleForm from "@/components/DeliveryScheduleForm";
 import PodcastCard from "@/components/PodcastCard";
+import AudioPlayer from "@/components/AudioPlayer";
+import EpisodeFeedback from "@/components/EpisodeFeedback";
+import { Button } from "@/components/ui/button";
 import { isAuthenticated } from "@/lib/auth";
 import { useToast } from "@/hooks/use-toast";
-import { ME_QUERY, PODCAST_QUERY, UPDATE_PREFS } from "@/lib/mutations";
+import { ME_QUERY, PODCAST_QUERY, UPDATE_PREFS, EPISODES_QUERY, ROTATE_FEED_TOKEN, SEND_VERIFICATION_EMAIL, GENERATE_MY_EPISODE, EPISODE_PROGRESS, CONTINUE_LISTENING } from "@/lib/mutations";
+import { formatDuration } from "@/lib/utils";
+
+// resumeAt is where to start playing an episode: where the user left off,
+// unless they already finished it.
+const resumeAt = (episode) =>
+  episode.playbackPosition && !episode.playbackPosition.completed ? episode.playbackPosition.seconds : 0;
 
 const Dashboard = () => {
   const navigate = useNavigate();
   const { toast } = useToast();
 
-  const [country, setCountry] = useState("us");
-  const [topic, setTopic] = useState("sports");
+  const [countries, setCountries] = useState([{ country: "us", weight: 1 }]);
+  const [topics, setTopics] = useState([{ topic: "general", weight: 1 }]);
+  const [language, setLanguage] = useState("en");
   const [currentPodcast, setCurrentPodcast] = useState(null);
+  const [jobId, setJobId] = useState(null);
 
   // Check authentication
   useEffect(() => {
@@ -27,8 +41,9 @@ const Dashboard = () => {
   const { data: meData, loading: meLoading } = useQuery(ME_QUERY, {
     onCompleted: (data) => {
       if (data?.me?.preferences) {
-        setCountry(data.me.preferences.country);
-        setTopic(data.me.preferences.topic);
+        setCountries(data.me.preferences.countries.map(({ country, weight }) => ({ country, weight })));
+        setTopics(data.me.preferences.topics.map(({ topic, weight }) => ({ topic, weight })));
+        setLanguage(data.me.preferences.language);
       }
     },
     onError: () => {
@@ -41,41 +56,177 @@ const Dashboard = () => {
   });
 
  // Lazy query for podcast
-  const [fetchPodcast, { data: podcastData, loading: podcastLoading }] =
+  const [fetchPodcast, { data: podcastData, loading: podcastLoading, error: podcastError }] =
     useLazyQuery(PODCAST_QUERY);
 
+  // Explain missing episodes, pointing at the nearest day that has one
+  useEffect(() => {
+    if (!CombinedGraphQLErrors.is(podcastError)) return;
+    const extensions = podcastError.errors[0]?.extensions;
+    if (extensions?.code === "EPISODE_NOT_FOUND") {
+      toast({
+        title: "No podcast for this day",
+        description: extensions.nearestDate
+          ? `The closest available episode is from ${extensions.nearestDate}.`
+          : "No episodes have been published yet.",
+      });
+    }
+  }, [podcastError, toast]);
+
+  // Archive of published episodes
+  const { data: episodesData, fetchMore: fetchMoreEpisodes } = useQuery(EPISODES_QUERY, {
+    variables: { first: 12 },
+  });
+  const episodes = episodesData?.episodes?.edges?.map((edge) => edge.node) ?? [];
+  const episodesPageInfo = episodesData?.episodes?.pageInfo;
+
+  const handleLoadMoreEpisodes = () => {
+    fetchMoreEpisodes({
+      variables: { after: episodesPageInfo.endCursor },
+      updateQuery: (previous, { fetchMoreResult }) => ({
+        episodes: {
+          ...fetchMoreResult.episodes,
+          edges: [...previous.episodes.edges, ...fetchMoreResult.episodes.edges],
+        },
+      }),
+    });
+  };
+
+  // Episodes started but not finished
+  const { data: continueData } = useQuery(CONTINUE_LISTENING, {
+    variables: { first: 6 },
+  });
+  const inProgress = continueData?.continueListening ?? [];
+
+  const handlePlayEpisode = (episode) => {
+    setCurrentPodcast({
+      id: episode.id,
+      startAt: resumeAt(episode),
+      title: episode.title,
+      duration: formatDuration(episode.durationSeconds),
+      audioUrl: episode.audioUrl,
+    });
+  };
+
   // Mutation for preferences
   const [updatePreferences] = useMutation(UPDATE_PREFS, {
     onCompleted: (data) => {
       toast({
         title: "Preferences Saved",
-        description: `Now showing podcasts about ${data.updatePreferences.topic} in ${data.updatePreferences.country.toUpperCase()}`,
+        description: `Your episodes will cover ${data.updatePreferences.topics.map((t) => t.topic).join(", ")}`,
+      });
+      // Today's date is worked out in the user's timezone
+      fetchPodcast();
+    },
+    onError: (error) => {
+      const extensions = CombinedGraphQLErrors.is(error) ? error.errors[0]?.extensions : null;
+      toast({
+        title: "Error",
+        description: extensions?.code === "BAD_USER_INPUT"
+          ? `Check your ${extensions.field}: ${error.errors[0].message}`
+          : "Failed to save preferences",
+        variant: "destructive",
+      });
+    },
+  });
+
+  // Private RSS feed for podcast apps
+  const [rotateFeedToken, { data: feedData }] = useMutation(ROTATE_FEED_TOKEN, {
+    onCompleted: (data) => {
+      navigator.clipboard?.writeText(data.rotateFeedToken);
+      toast({
+        title: "Feed URL created",
+        description: "Copied to your clipboard. Any previous feed URL no longer works.",
+      });
+    },
+    onError: (error) => {
+      const code = CombinedGraphQLErrors.is(error) ? error.errors[0]?.extensions?.code : null;
+      toast({
+        title: "Error",
+        description: code === "EMAIL_NOT_VERIFIED"
+          ? "Confirm your email address before creating a feed URL."
+          : "Failed to create feed URL",
+        variant: "destructive",
+      });
+    },
+  });
+
+  const [sendVerificationEmail, { loading: verificationSending }] = useMutation(SEND_VERIFICATION_EMAIL, {
+    onCompleted: () => {
+      toast({
+        title: "Verification email sent",
+        description: `Check ${meData?.me?.email} for a confirmation link.`,
       });
-      fetchPodcast({ variables: { date: new Date().toISOString().split("T")[0] } });
     },
     onError: () => {
       toast({
         title: "Error",
-        description: "Failed to save preferences",
+        description: "Failed to send verification email",
+        variant: "destructive",
+      });
+    },
+  });
+
+  // Personal episodes are generated in the background; follow the job's
+  // progress until it finishes
+  const [generateMyEpisode, { loading: generateStarting }] = useMutation(GENERATE_MY_EPISODE, {
+    onCompleted: (data) => setJobId(data.generateMyEpisode.id),
+    onError: (error) => {
+      const code = CombinedGraphQLErrors.is(error) ? error.errors[0]?.extensions?.code : null;
+      toast({
+        title: "Error",
+        description: code === "RATE_LIMITED"
+          ? "You've generated several episodes today. Try again tomorrow."
+          : "Failed to start your episode",
         variant: "destructive",
       });
     },
   });
 
+  const { data: jobData } = useSubscription(EPISODE_PROGRESS, {
+    variables: { jobId },
+    skip: !jobId,
+  });
+  const job = jobData?.episodeProgress;
+
+  useEffect(() => {
+    if (job?.status === "PUBLISHED") {
+      setJobId(null);
+      setCurrentPodcast({
+        id: job.episode.id,
+        title: job.episode.title,
+        duration: formatDuration(job.episode.durationSeconds),
+        audioUrl: job.episode.audioUrl,
+      });
+      toast({ title: "Your episode is ready", description: job.episode.title });
+    } else if (job?.status === "FAILED") {
+      setJobId(null);
+      toast({
+        title: "Error",
+        description: job.error || "Your episode could not be generated",
+        variant: "destructive",
+      });
+    }
+  }, [job, toast]);
+
+  const generating = generateStarting || Boolean(jobId);
+
   const handleApplyPreferences = () => {
-    updatePreferences({ variables: { country, topic } });
+    updatePreferences({ variables: { topics, countries, language } });
   };
 
   const handlePlayPodcast = () => {
     if (podcastData?.podcast) {
       setCurrentPodcast({
-        title: `AI Podcast for ${topic} in ${country}`,
-        duration: "Unknown",
+        id: podcastData.podcast.episode.id,
+        startAt: resumeAt(podcastData.podcast.episode),
+        title: podcastData.podcast.episode.title,
+        duration: formatDuration(podcastData.podcast.episode.durationSeconds),
         audioUrl: podcastData.podcast.url,
       });
       toast({
         title: "Now Playing",
-        description: `Podcast for ${topic} in ${country}`,
+        description: podcastData.podcast.episode.title,
       });
     } else {
       toast({
@@ -102,15 +253,55 @@ const Dashboard = () => {
           
           <div className="mb-8">
             <PreferenceSelector
-              country={country}
-              topic={topic}
-              onCountryChange={setCountry}
-              onTopicChange={setTopic}
+              countries={countries}
+              topics={topics}
+              onCountriesChange={setCountries}
+              language={language}
+              onTopicsChange={setTopics}
+              onLanguageChange={setLanguage}
               onApply={handleApplyPreferences}
               loading={podcastLoading}
             />
           </div>
+
+          <div className="mb-8">
+            <ArticleFiltersForm filters={meData?.me?.preferences?.filters} />
+          </div>
+
+          <div className="mb-8">
+            <DeliveryScheduleForm preferences={meData?.me?.preferences} />
+          </div>
           
+          {meData?.me && !meData.me.emailVerified && (
+            <div className="mb-8 flex flex-wrap items-center gap-4 rounded-md border border-border bg-background/50 p-4">
+              <p className="text-sm text-muted-foreground">
+                Confirm your email address to unlock podcast app feeds.
+              </p>
+              <Button variant="outline" size="sm" onClick={() => sendVerificationEmail()} disabled={verificationSending}>
+                {verificationSending ? "Sending..." : "Resend verification email"}
+              </Button>
+            </div>
+          )}
+
+          <div className="mb-8 flex flex-wrap items-center gap-4">
+            <Button onClick={() => generateMyEpisode()} disabled={generating}>
+              {generating
+                ? `Generating${job ? ` (${job.status.toLowerCase()}, ${job.progress}%)` : ""}...`
+                : "Generate my episode"}
+            </Button>
+          </div>
+
+          <div className="mb-8 flex flex-wrap items-center gap-4">
+            <Button variant="outline" onClick={() => rotateFeedToken()}>
+              Get podcast app feed URL
+            </Button>
+            {feedData?.rotateFeedToken && (
+              <code className="text-sm text-muted-foreground break-all">
+                {feedData.rotateFeedToken}
+              </code>
+            )}
+          </div>
+
           {podcastData?.podcast && (
             <div className="mb-8">
               <h2 className="text-2xl font-semibold text-foreground mb-4">
@@ -118,20 +309,73 @@ const Dashboard = () => {
               </h2>
               <PodcastCard
                 podcast={{
-                  id: "today",
-                  title: `AI Podcast for ${topic}`,
-                  description: "Generated just for you.",
-                  duration: "Unknown",
-                  country,
-                  topic,
+                  id: podcastData.podcast.episode.id,
+                  title: podcastData.podcast.episode.title,
+                  description: podcastData.podcast.episode.summary,
+                  duration: formatDuration(podcastData.podcast.episode.durationSeconds),
+                  thumbnailUrl: podcastData.podcast.episode.coverUrl,
+                  country: podcastData.podcast.episode.country,
+                  topic: podcastData.podcast.episode.topic,
                   audioUrl: podcastData.podcast.url,
                   createdAt: podcastData.podcast.date,
                 }}
                 onPlay={handlePlayPodcast}
               />
+              <div className="mt-4">
+                <EpisodeFeedback key={podcastData.podcast.episode.id} episode={podcastData.podcast.episode} />
+              </div>
             </div>
           )}
           
+          {inProgress.length > 0 && (
+            <div className="mb-8">
+              <h2 className="text-2xl font-semibold text-foreground mb-4">
+                Continue Listening
+              </h2>
+              <div className="grid md:grid-cols-2 lg:grid-cols-3 gap-4">
+                {inProgress.map((episode) => (
+                  <PodcastCard
+                    key={episode.id}
+                    podcast={{
+                      ...episode,
+                      duration: `${formatDuration(episode.durationSeconds - resumeAt(episode))} left`,
+                      createdAt: episode.date,
+                    }}
+                    onPlay={() => handlePlayEpisode(episode)}
+                  />
+                ))}
+              </div>
+            </div>
+          )}
+
+          {episodes.length > 0 && (
+            <div className="mb-8">
+              <h2 className="text-2xl font-semibold text-foreground mb-4">
+                Past Episodes
+              </h2>
+              <div className="grid md:grid-cols-2 lg:grid-cols-3 gap-4">
+                {episodes.map((episode) => (
+                  <PodcastCard
+                    key={episode.id}
+                    podcast={{
+                      ...episode,
+                      duration: formatDuration(episode.durationSeconds),
+                      createdAt: episode.date,
+                    }}
+                    onPlay={() => handlePlayEpisode(episode)}
+                  />
+                ))}
+              </div>
+              {episodesPageInfo?.hasNextPage && (
+                <div className="mt-4 flex justify-center">
+                  <Button variant="outline" onClick={handleLoadMoreEpisodes}>
+                    Load more
+                  </Button>
+                </div>
+              )}
+            </div>
+          )}
+
           {currentPodcast && (
             <div className="fixed bottom-0 left-0 right-0 bg-glass-bg/95 backdrop-blur-md border-t border-glass-border p-4">
               <div className="container mx-auto flex items-center justify-between">
@@ -144,7 +388,13 @@ const Dashboard = () => {
                     <p className="text-sm text-muted-foreground">{currentPodcast.duration}</p>
                   </div>
                 </div>
-                <audio controls src={currentPodcast.audioUrl} className="w-1/3" />
+                <AudioPlayer
+                  key={currentPodcast.id}
+                  episodeId={currentPodcast.id}
+                  startAt={currentPodcast.startAt}
+                  src={currentPodcast.audioUrl}
+                  className="w-1/3"
+                />
               </div>
             </div>
           )}
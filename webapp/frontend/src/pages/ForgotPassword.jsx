@@ -0,0 +1,69 @@
+import { useState } from "react";
+import { useNavigate } from "react-router-dom";
+import { useMutation } from "@apollo/client/react";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+import { Card, CardContent, CardDescription, CardFooter, CardHeader, CardTitle } from "@/components/ui/card";
+import { REQUEST_PASSWORD_RESET } from "@/lib/mutations";
+
+const ForgotPassword = () => {
+  const navigate = useNavigate();
+  const [email, setEmail] = useState("");
+  const [requestPasswordReset, { data, loading, error }] = useMutation(REQUEST_PASSWORD_RESET);
+
+  const handleSubmit = (e) => {
+    e.preventDefault();
+    requestPasswordReset({ variables: { email } }).catch((err) => console.error("Reset error:", err));
+  };
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gradient-hero p-4">
+      <Card className="w-full max-w-md bg-glass-bg border-glass-border backdrop-blur-sm">
+        <CardHeader className="text-center">
+          <CardTitle className="text-2xl font-bold text-foreground">Reset Password</CardTitle>
+          <CardDescription className="text-muted-foreground">
+            {data?.requestPasswordReset
+              ? "If an account exists for that email, we've sent a link to reset your password."
+              : "Enter your email and we'll send you a link to choose a new password"}
+          </CardDescription>
+        </CardHeader>
+
+        <form onSubmit={handleSubmit}>
+          <CardContent className="space-y-4">
+            <div className="space-y-2">
+              <Label htmlFor="email">Email</Label>
+              <Input
+                id="email"
+                type="email"
+                placeholder="Enter your email"
+                value={email}
+                onChange={(e) => setEmail(e.target.value)}
+                required
+                className="bg-background/50 border-border"
+              />
+            </div>
+            {error && (
+              <p className="text-sm text-destructive">Something went wrong, please try again.</p>
+            )}
+          </CardContent>
+
+          <CardFooter className="flex flex-col space-y-4">
+            <Button type="submit" variant="hero" className="w-full" disabled={loading}>
+              {loading ? "Please wait..." : "Send Reset Link"}
+            </Button>
+            <button
+              type="button"
+              onClick={() => navigate("/login")}
+              className="text-sm text-primary hover:underline"
+            >
+              Back to sign in
+            </button>
+          </CardFooter>
+        </form>
+      </Card>
+    </div>
+  );
+};
+
+export default ForgotPassword;
@@ -0,0 +1,34 @@
+import { useEffect } from "react";
+import { useNavigate } from "react-router-dom";
+import { setSession } from "@/lib/auth";
+
+// The backend redirects here after a provider sign in, with the session
+// tokens in the URL fragment.
+const OAuthCallback = () => {
+  const navigate = useNavigate();
+
+  useEffect(() => {
+    const params = new URLSearchParams(window.location.hash.slice(1));
+    const accessToken = params.get("accessToken");
+    const refreshToken = params.get("refreshToken");
+    const expiresAt = params.get("expiresAt");
+
+    // Drop the tokens from the address bar and history
+    window.history.replaceState(null, "", window.location.pathname);
+
+    if (accessToken && refreshToken && expiresAt) {
+      setSession({ accessToken, refreshToken, expiresAt });
+      navigate("/dashboard", { replace: true });
+    } else {
+      navigate("/login?error=INVALID_TOKEN", { replace: true });
+    }
+  }, [navigate]);
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gradient-hero p-4">
+      <p className="text-muted-foreground">Signing you in...</p>
+    </div>
+  );
+};
+
+export default OAuthCallback;
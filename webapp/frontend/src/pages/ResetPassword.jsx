@@ -0,0 +1,91 @@
+import { useState } from "react";
+import { useNavigate, useSearchParams } from "react-router-dom";
+import { CombinedGraphQLErrors } from "@apollo/client";
+import { useMutation } from "@apollo/client/react";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+import { Card, CardContent, CardDescription, CardFooter, CardHeader, CardTitle } from "@/components/ui/card";
+import { useToast } from "@/hooks/use-toast";
+import { RESET_PASSWORD } from "@/lib/mutations";
+
+const ResetPassword = () => {
+  const navigate = useNavigate();
+  const { toast } = useToast();
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get("token") ?? "";
+  const [password, setPassword] = useState("");
+
+  const [resetPassword, { loading, error }] = useMutation(RESET_PASSWORD, {
+    onCompleted: () => {
+      toast({
+        title: "Password updated",
+        description: "Sign in with your new password.",
+      });
+      navigate("/login");
+    },
+  });
+
+  const apiError = CombinedGraphQLErrors.is(error) ? error.errors[0] : null;
+  let errorMessage = "Something went wrong, please try again.";
+  if (apiError?.extensions?.code === "INVALID_TOKEN") {
+    errorMessage = "This reset link is invalid or has expired. Request a new one.";
+  } else if (apiError?.extensions?.code === "BAD_USER_INPUT") {
+    errorMessage = apiError.message.charAt(0).toUpperCase() + apiError.message.slice(1) + ".";
+  }
+
+  const handleSubmit = (e) => {
+    e.preventDefault();
+    resetPassword({ variables: { token, newPassword: password } }).catch((err) =>
+      console.error("Reset error:", err)
+    );
+  };
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gradient-hero p-4">
+      <Card className="w-full max-w-md bg-glass-bg border-glass-border backdrop-blur-sm">
+        <CardHeader className="text-center">
+          <CardTitle className="text-2xl font-bold text-foreground">Choose a New Password</CardTitle>
+          <CardDescription className="text-muted-foreground">
+            You'll be signed out of all your devices
+          </CardDescription>
+        </CardHeader>
+
+        <form onSubmit={handleSubmit}>
+          <CardContent className="space-y-4">
+            <div className="space-y-2">
+              <Label htmlFor="password">New Password</Label>
+              <Input
+                id="password"
+                type="password"
+                placeholder="At least 8 characters, with letters and numbers"
+                value={password}
+                onChange={(e) => setPassword(e.target.value)}
+                required
+                className="bg-background/50 border-border"
+              />
+            </div>
+            {error && (
+              <p className="text-sm text-destructive">{errorMessage}</p>
+            )}
+          </CardContent>
+
+          <CardFooter className="flex flex-col space-y-4">
+            <Button type="submit" variant="hero" className="w-full" disabled={loading || !token}>
+              {loading ? "Please wait..." : "Update Password"}
+            </Button>
+            <button
+              type="button"
+              onClick={() => navigate("/forgot-password")}
+              className="text-sm text-primary hover:underline"
+            >
+              Request a new link
+            </button>
+          </CardFooter>
+        </form>
+      </Card>
+    </div>
+  );
+};
+
+export default ResetPassword;
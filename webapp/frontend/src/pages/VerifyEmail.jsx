@@ -0,0 +1,51 @@
+import { useEffect, useRef } from "react";
+import { useNavigate, useSearchParams } from "react-router-dom";
+import { useMutation } from "@apollo/client/react";
+import { Button } from "@/components/ui/button";
+import { Card, CardDescription, CardFooter, CardHeader, CardTitle } from "@/components/ui/card";
+import { isAuthenticated } from "@/lib/auth";
+import { VERIFY_EMAIL } from "@/lib/mutations";
+
+const VerifyEmail = () => {
+  const navigate = useNavigate();
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get("token");
+  const [verifyEmail, { data, error }] = useMutation(VERIFY_EMAIL);
+
+  // Tokens are single use, so make sure the request is only sent once
+  const sent = useRef(false);
+  useEffect(() => {
+    if (!token || sent.current) return;
+    sent.current = true;
+    verifyEmail({ variables: { token } }).catch((err) => console.error("Verify error:", err));
+  }, [token, verifyEmail]);
+
+  let message = "Confirming your email address...";
+  if (data?.verifyEmail) {
+    message = "Your email address is confirmed. Thanks!";
+  } else if (error || !token) {
+    message = "This confirmation link is invalid or has expired. Request a new one from your dashboard.";
+  }
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gradient-hero p-4">
+      <Card className="w-full max-w-md bg-glass-bg border-glass-border backdrop-blur-sm">
+        <CardHeader className="text-center">
+          <CardTitle className="text-2xl font-bold text-foreground">Verify Email</CardTitle>
+          <CardDescription className="text-muted-foreground">{message}</CardDescription>
+        </CardHeader>
+        <CardFooter>
+          <Button
+            variant="hero"
+            className="w-full"
+            onClick={() => navigate(isAuthenticated() ? "/dashboard" : "/login")}
+          >
+            Continue
+          </Button>
+        </CardFooter>
+      </Card>
+    </div>
+  );
+};
+
+export default VerifyEmail;